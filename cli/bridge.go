@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+
+	"cabinchat/core"
+	"cabinchat/logger"
+)
+
+// bridgeNick is the bridge's nick in both rooms when -nick isn't given
+const bridgeNick = "bridge"
+
+// bridgeSide is one of the two rooms a bridge joins
+type bridgeSide struct {
+	label  string // room name, prefixed to messages relayed out of it
+	client *core.ChatClient
+}
+
+// RunBridge joins two rooms, given as cabinchat:// links or host:port, and
+// relays public chat between them until either connection is lost. Relayed
+// messages carry their origin, e.g. "[Cabin A] alice: hi". Private
+// messages, system notices and files stay in their room, and the bridge's
+// own messages are never relayed back, so bridging can't loop.
+func RunBridge(a string, b string) error {
+	nick := core.Settings.Nick
+	if nick == "" {
+		nick = bridgeNick
+	}
+
+	var sides [2]*bridgeSide
+	lost := make(chan string, 2)
+	for i, link := range []string{a, b} {
+		room, err := core.ParseJoinAddress(link)
+		if err != nil {
+			return err
+		}
+		side := &bridgeSide{label: roomLabel(room)}
+		other := 1 - i
+		side.client, err = core.NewChatClient(room, nick, nil, core.ClientCallbacks{
+			OnMessageReceived: func(msg core.Message) {
+				if target := sides[other]; target != nil {
+					relay(msg, nick, side.label, target)
+				}
+			},
+			OnSystemMessage: func(text string) {
+				printSystem(fmt.Sprintf("[%s] %s", side.label, text))
+			},
+			OnFileReceived: func(filename string, data []byte, sender string, err error) {
+				logger.Debugf("Bridge ignored %s from %s", filename, sender)
+			},
+			OnConnectionLost: func() {
+				lost <- side.label
+			},
+		}, nil)
+		if err != nil {
+			if sides[0] != nil {
+				sides[0].client.Close()
+			}
+			return fmt.Errorf("joining %s: %w", link, err)
+		}
+		sides[i] = side
+	}
+
+	for _, side := range sides {
+		side.client.Start()
+	}
+	printSystem(fmt.Sprintf("Bridging %s and %s as %s", sides[0].label, sides[1].label, nick))
+
+	label := <-lost
+	for _, side := range sides {
+		side.client.Close()
+	}
+	return fmt.Errorf("lost the connection to %s", label)
+}
+
+// relay forwards a public chat message from one room to the other, leaving
+// out the bridge's own messages so nothing is relayed twice
+func relay(msg core.Message, nick string, from string, to *bridgeSide) {
+	if msg.Type != core.MsgTypeMsg || msg.Nick == nick {
+		return
+	}
+	text := fmt.Sprintf("[%s] %s: %s", from, msg.Nick, msg.Text)
+	if msg.Nick == "*" {
+		text = fmt.Sprintf("[%s] %s", from, msg.Text) // action, the text names who did it
+	}
+	if _, err := to.client.SendText(text); err != nil {
+		logger.Warnf("Relaying to %s: %v", to.label, err)
+	}
+}
+
+// roomLabel names a room for relayed messages: its advertised name, or its
+// address when joined by address
+func roomLabel(room core.DiscoveredRoom) string {
+	if room.Name != "" {
+		return room.Name
+	}
+	return room.Address()
+}
@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cabinchat/core"
+)
+
+// stdin is shared by all prompts so buffered input isn't lost between reads
+var stdin = bufio.NewReader(os.Stdin)
+
+// transcript records what was printed, for /export
+var (
+	transcript   []core.TranscriptEntry
+	transcriptMu sync.Mutex
+)
+
+// ownNick is our nick as /nick changes it, for telling our own messages
+// and mentions of us apart
+var (
+	ownNick   string
+	ownNickMu sync.Mutex
+)
+
+// session is what the input loop talks to: a core.Host or core.ChatClient
+type session interface {
+	core.AwayStatus
+	SendText(text string) (string, error)
+	Stats() core.SessionStats
+}
+
+// Run starts CabinChat in the terminal: it lists nearby rooms to join,
+// or hosts a new one, then reads chat input from stdin until /quit
+func Run() {
+	nick := core.Settings.Nick
+	for nick == "" {
+		var err error
+		if nick, err = promptInput("Nickname: "); err != nil && nick == "" {
+			fmt.Println()
+			return // end of input, nothing more will be typed
+		}
+	}
+	setNick(nick)
+
+	var room core.DiscoveredRoom
+	var ok bool
+	if core.Settings.Join != "" {
+		var err error
+		if room, err = core.ParseJoinAddress(core.Settings.Join); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		ok = true
+	} else {
+		fmt.Println("🔍 Searching for nearby rooms...")
+		room, ok = pickRoom(core.FindRooms(core.Settings.Port))
+	}
+
+	var s session
+	var err error
+	if ok {
+		s, err = joinRoom(room, nick)
+	} else if promptYesNo("Host a new room?") {
+		s, err = hostRoom(nick)
+	} else {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	inputLoop(s)
+}
+
+// pickRoom lists discovered rooms and asks which one to join, by number or
+// by typing an address or cabinchat:// link
+func pickRoom(rooms []core.DiscoveredRoom) (core.DiscoveredRoom, bool) {
+	if len(rooms) == 0 {
+		fmt.Println("No rooms found.")
+	}
+
+	for i, r := range rooms {
+		fmt.Printf("  %d) %s (%s:%d)\n", i+1, r.Name, r.Host, r.Port)
+	}
+	answer, _ := promptInput("Join which room? (number or address, enter to host): ")
+	if answer == "" {
+		return core.DiscoveredRoom{}, false
+	}
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n < 1 || n > len(rooms) {
+			return core.DiscoveredRoom{}, false
+		}
+		return rooms[n-1], true
+	}
+	room, err := core.ParseJoinAddress(answer)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return core.DiscoveredRoom{}, false
+	}
+	return room, true
+}
+
+// hostRoom starts hosting with terminal callbacks
+func hostRoom(nick string) (session, error) {
+	host := core.NewHost(nick, nil, core.HostCallbacks{
+		OnMessageReceived: printMessage,
+		OnNickChanged:     setNick,
+		OnSystemMessage:   printSystem,
+		OnUserList:        printUsers,
+		OnPoll:            printPoll,
+		OnReaction:        printReaction,
+		OnTopic:           printTopic,
+		OnMessageEdited:   printEdit,
+		OnMessageDeleted:  printDelete,
+		OnFileOffer: func(offer core.PendingOffer) {
+			core.PlayBell(core.BellFile)
+			printSystem(fmt.Sprintf("%s wants to send %s. Type /accept or /reject", offer.SenderNick, offer.Filename))
+		},
+		OnFileReceived: saveFile,
+		OnExport:       exportTranscript,
+		OnClosed: func(reason string) {
+			printSystem(reason)
+			os.Exit(0)
+		},
+	}, nil)
+	if err := host.Start(); err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// joinRoom connects to a room with terminal callbacks
+func joinRoom(room core.DiscoveredRoom, nick string) (session, error) {
+	client, err := core.NewChatClient(room, nick, nil, core.ClientCallbacks{
+		OnMessageReceived: printMessage,
+		OnNickChanged:     setNick,
+		OnSystemMessage:   printSystem,
+		OnUserList:        printUsers,
+		OnPoll:            printPoll,
+		OnReaction:        printReaction,
+		OnTopic:           printTopic,
+		OnMessageEdited:   printEdit,
+		OnMessageDeleted:  printDelete,
+		OnFileOffer: func(offer core.PendingFile) {
+			core.PlayBell(core.BellFile)
+			printSystem(fmt.Sprintf("%s wants to send %s (%s). Type /accept or /reject", offer.From, offer.Filename, offer.Size))
+		},
+		OnFileAccepted: func(sender string) {
+			printSystem(fmt.Sprintf("File accepted by %s, sending...", sender))
+		},
+		OnFileRejected: func(sender string) {
+			printSystem(fmt.Sprintf("File rejected by %s", sender))
+		},
+		OnFileReceived: saveFile,
+		OnExport:       exportTranscript,
+		OnReconnecting: func(room string) {
+			printSystem(fmt.Sprintf("Connection lost, looking for %s...", room))
+		},
+		OnReconnected: func(addr string) {
+			printSystem(fmt.Sprintf("Reconnected to %s", addr))
+		},
+		OnConnectionLost: func() {
+			printSystem("Disconnected")
+			os.Exit(0)
+		},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Start()
+	return client, nil
+}
+
+// inputLoop sends each line of stdin until /quit or EOF
+func inputLoop(s session) {
+	idle := core.NewIdleTimer(s)
+	defer idle.Stop()
+	for {
+		line, err := stdin.ReadString('\n')
+		idle.Touch()
+		text := strings.TrimSpace(line)
+		if err != nil {
+			text = "/quit"
+		}
+		if text == "" {
+			continue
+		}
+
+		if cmd := strings.ToLower(strings.Fields(text)[0]); cmd == "/call" || cmd == "/share" {
+			fmt.Println("Calls and screen sharing need the GUI")
+			continue
+		}
+
+		if core.IsQuitCommand(text) {
+			// Before leaving, since a client's disconnect exits the process
+			fmt.Println(s.Stats())
+		}
+		output, sendErr := s.SendText(text)
+		if sendErr != nil {
+			fmt.Printf("Error: %v\n", sendErr)
+		}
+		if output != "" {
+			fmt.Print(strings.TrimRight(output, "\n") + "\n")
+		}
+		if err != nil || core.IsQuitCommand(text) {
+			return
+		}
+	}
+}
+
+func printMessage(msg core.Message) {
+	core.PlayBell(bellEvent(msg, currentNick()))
+	if msg.ReplyTo != "" {
+		fmt.Printf("        ↪ %s: %s\n", msg.ReplyNick, msg.ReplyText)
+	}
+	if msg.Type == core.MsgTypePrivate {
+		fmt.Printf("[%s] *%s* %s\n", time.Now().Format("15:04"), msg.Nick, msg.Text)
+	} else {
+		fmt.Printf("[%s] <%s> %s\n", time.Now().Format("15:04"), msg.Nick, msg.Text)
+	}
+	record(msg.ID, msg.Nick, msg.Text)
+}
+
+// setNick records our nick, at the start and after /nick
+func setNick(nick string) {
+	ownNickMu.Lock()
+	defer ownNickMu.Unlock()
+	ownNick = nick
+}
+
+// currentNick returns our nick as of the last /nick
+func currentNick() string {
+	ownNickMu.Lock()
+	defer ownNickMu.Unlock()
+	return ownNick
+}
+
+// bellEvent picks which bell a message rings
+func bellEvent(msg core.Message, nick string) core.BellEvent {
+	switch {
+	case msg.Nick == nick:
+		return core.BellOwn
+	case msg.Type == core.MsgTypePrivate:
+		return core.BellPrivate
+	case core.IsMention(msg.Text, nick):
+		return core.BellMention
+	}
+	return core.BellMessage
+}
+
+func printSystem(text string) {
+	fmt.Printf("*** %s\n", text)
+	record("", "", text)
+}
+
+// record adds a printed line to the transcript
+func record(id string, nick string, text string) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	transcript = append(transcript, core.TranscriptEntry{Time: time.Now(), ID: id, Nick: nick, Text: text})
+}
+
+// printEdit shows the new text of an edited message; earlier lines can't be rewritten
+func printEdit(id string, text string) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	if i := slices.IndexFunc(transcript, func(e core.TranscriptEntry) bool { return e.ID == id }); i >= 0 {
+		fmt.Printf("*** %s edited %q: %s\n", transcript[i].Nick, transcript[i].Text, text)
+	}
+	core.EditEntry(transcript, id, text)
+}
+
+func printDelete(id string) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	if i := slices.IndexFunc(transcript, func(e core.TranscriptEntry) bool { return e.ID == id }); i >= 0 {
+		fmt.Printf("*** %s deleted %q\n", transcript[i].Nick, transcript[i].Text)
+	}
+	transcript = core.DeleteEntry(transcript, id)
+}
+
+// exportTranscript saves the transcript for /export
+func exportTranscript(path string) {
+	transcriptMu.Lock()
+	entries := slices.Clone(transcript)
+	transcriptMu.Unlock()
+
+	saved, err := core.ExportTranscript(path, entries)
+	if err != nil {
+		printSystem(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	printSystem("Saved transcript to " + saved)
+}
+
+func printUsers(users []string) {
+	printSystem("Online: " + strings.Join(users, ", "))
+}
+
+func printPoll(poll core.Poll) {
+	if poll.Closed {
+		return // the host announces results as a system message
+	}
+	printSystem("Poll: " + poll.Question)
+	for i, opt := range poll.Options {
+		fmt.Printf("      %d) %s\n", i+1, opt)
+	}
+	printSystem("Vote with /vote <n>")
+}
+
+func printReaction(id string, emoji string, count int) {
+	printSystem(fmt.Sprintf("Message #%s: %s x%d", id, emoji, count))
+}
+
+func printTopic(topic string) {
+	if topic != "" {
+		printSystem("Topic: " + topic)
+	}
+}
+
+// saveFile writes received files to Settings.DownloadDir
+func saveFile(filename string, data []byte, sender string, err error) {
+	if err != nil {
+		printSystem(fmt.Sprintf("File %s from %s failed: %v", filename, sender, err))
+		return
+	}
+	path, err := core.SaveFile(filename, data)
+	if err != nil {
+		printSystem(fmt.Sprintf("Error saving %s: %v", filename, err))
+		return
+	}
+	printSystem(fmt.Sprintf("Received %s from %s (%s)", path, sender, core.FormatSize(int64(len(data)))))
+}
+
+// promptInput reads a line from stdin with a prompt. At the end of input it
+// returns whatever was typed and the error.
+func promptInput(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := stdin.ReadString('\n')
+	return strings.TrimSpace(line), err
+}
+
+// promptYesNo asks a yes/no question and returns true for yes
+func promptYesNo(prompt string) bool {
+	answer, _ := promptInput(prompt + " (y/n): ")
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"cabinchat/core"
+)
+
+func TestRunEndsWhenInputEndsAtTheNickPrompt(t *testing.T) {
+	old := stdin
+	stdin = bufio.NewReader(strings.NewReader(""))
+	t.Cleanup(func() { stdin = old })
+	nick := core.Settings.Nick
+	core.Settings.Nick = ""
+	t.Cleanup(func() { core.Settings.Nick = nick })
+
+	done := make(chan struct{})
+	go func() {
+		Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run kept asking for a nickname after the end of input")
+	}
+}
+
+func TestBellFollowsNickChange(t *testing.T) {
+	setNick("alice")
+	setNick("alicia")
+	t.Cleanup(func() { setNick("") })
+
+	if got := bellEvent(core.Message{Nick: "alicia", Text: "hi"}, currentNick()); got != core.BellOwn {
+		t.Errorf("own message after /nick rings %v, want BellOwn", got)
+	}
+	if got := bellEvent(core.Message{Nick: "bob", Text: "hey @alicia"}, currentNick()); got != core.BellMention {
+		t.Errorf("mention of the new nick rings %v, want BellMention", got)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// Alias is a local display name and/or nick color for another user. It only
+// changes how their messages are shown here, never the nick on the wire.
+type Alias struct {
+	Nick  string
+	Name  string // shown instead of Nick, "" = Nick
+	Color string // #rgb or #rrggbb, "" = the theme's nick color
+}
+
+// AliasFor returns the alias set for nick, if any
+func AliasFor(nick string) (Alias, bool) {
+	i := slices.IndexFunc(Settings.Aliases, func(a Alias) bool { return strings.EqualFold(a.Nick, nick) })
+	if i < 0 {
+		return Alias{}, false
+	}
+	return Settings.Aliases[i], true
+}
+
+// DisplayNick returns the name to show for nick: its alias, or nick itself
+func DisplayNick(nick string) string {
+	if alias, ok := AliasFor(nick); ok && alias.Name != "" {
+		return alias.Name
+	}
+	return nick
+}
+
+// ParseColor parses a #rgb or #rrggbb color
+func ParseColor(s string) (color.RGBA, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if ok && len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if !ok || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("color %q is not #rgb or #rrggbb", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("color %q is not #rgb or #rrggbb", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// parseAlias reads /alias arguments: a nick, then an optional name (quotes
+// around it are dropped) and an optional trailing #color. A nick alone
+// clears its alias.
+func parseAlias(args string) (Alias, error) {
+	nick, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	alias := Alias{Nick: nick}
+	rest = strings.TrimSpace(rest)
+	if i := strings.LastIndex(rest, " "); strings.HasPrefix(rest[i+1:], "#") {
+		alias.Color = rest[i+1:]
+		rest = strings.TrimSpace(rest[:max(i, 0)])
+		if _, err := ParseColor(alias.Color); err != nil {
+			return Alias{}, err
+		}
+	}
+	alias.Name = strings.Trim(rest, `"`)
+	return alias, nil
+}
+
+// setAlias stores alias in Settings.Aliases, replacing any earlier one for
+// the nick, or removes it when it has neither name nor color. onChanged, if
+// set, is given the new list so it can be kept across restarts. It returns
+// local output.
+func setAlias(alias Alias, onChanged func(aliases []Alias)) string {
+	Settings.Aliases = slices.DeleteFunc(Settings.Aliases, func(a Alias) bool { return strings.EqualFold(a.Nick, alias.Nick) })
+	output := i18n.T("alias.cleared", alias.Nick) + "\n"
+	if alias.Name != "" || alias.Color != "" {
+		Settings.Aliases = append(Settings.Aliases, alias)
+		output = i18n.T("alias.set", alias.Nick, describeAlias(alias)) + "\n"
+	}
+	if onChanged != nil {
+		onChanged(slices.Clone(Settings.Aliases))
+	}
+	return output
+}
+
+// aliasList lists the local aliases for /alias
+func aliasList() string {
+	if len(Settings.Aliases) == 0 {
+		return i18n.T("alias.none") + "\n"
+	}
+	var b strings.Builder
+	for _, alias := range Settings.Aliases {
+		fmt.Fprintln(&b, i18n.T("alias.entry", alias.Nick, describeAlias(alias)))
+	}
+	return b.String()
+}
+
+// describeAlias shows an alias' name and color
+func describeAlias(alias Alias) string {
+	name := alias.Nick
+	if alias.Name != "" {
+		name = fmt.Sprintf("%q", alias.Name)
+	}
+	if alias.Color != "" {
+		name = i18n.T("alias.inColor", name, alias.Color)
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package core
+
+import "strings"
+
+// maxBannerLen caps /ascii input so a banner stays readable in the chat
+const maxBannerLen = 10
+
+// bannerHeight is the number of lines in every bannerFont glyph
+const bannerHeight = 5
+
+// bannerFont is a 5-line block font for /ascii. Glyphs only use '#' and
+// spaces, so markdown rendering leaves them alone.
+var bannerFont = map[rune][bannerHeight]string{
+	'A': {" ### ", "#   #", "#####", "#   #", "#   #"},
+	'B': {"#### ", "#   #", "#### ", "#   #", "#### "},
+	'C': {" ####", "#    ", "#    ", "#    ", " ####"},
+	'D': {"#### ", "#   #", "#   #", "#   #", "#### "},
+	'E': {"#####", "#    ", "#### ", "#    ", "#####"},
+	'F': {"#####", "#    ", "#### ", "#    ", "#    "},
+	'G': {" ####", "#    ", "#  ##", "#   #", " ####"},
+	'H': {"#   #", "#   #", "#####", "#   #", "#   #"},
+	'I': {"#####", "  #  ", "  #  ", "  #  ", "#####"},
+	'J': {"#####", "   # ", "   # ", "#  # ", " ##  "},
+	'K': {"#   #", "#  # ", "###  ", "#  # ", "#   #"},
+	'L': {"#    ", "#    ", "#    ", "#    ", "#####"},
+	'M': {"#   #", "## ##", "# # #", "#   #", "#   #"},
+	'N': {"#   #", "##  #", "# # #", "#  ##", "#   #"},
+	'O': {" ### ", "#   #", "#   #", "#   #", " ### "},
+	'P': {"#### ", "#   #", "#### ", "#    ", "#    "},
+	'Q': {" ### ", "#   #", "# # #", "#  # ", " ## #"},
+	'R': {"#### ", "#   #", "#### ", "#  # ", "#   #"},
+	'S': {" ####", "#    ", " ### ", "    #", "#### "},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#   #", "#   #", "#   #", "#   #", " ### "},
+	'V': {"#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W': {"#   #", "#   #", "# # #", "## ##", "#   #"},
+	'X': {"#   #", " # # ", "  #  ", " # # ", "#   #"},
+	'Y': {"#   #", " # # ", "  #  ", "  #  ", "  #  "},
+	'Z': {"#####", "   # ", "  #  ", " #   ", "#####"},
+	'0': {" ### ", "#  ##", "# # #", "##  #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "  ## ", " #   ", "#####"},
+	'3': {"#### ", "    #", " ### ", "    #", "#### "},
+	'4': {"#   #", "#   #", "#####", "    #", "    #"},
+	'5': {"#####", "#    ", "#### ", "    #", "#### "},
+	'6': {" ### ", "#    ", "#### ", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", "  #  "},
+	'8': {" ### ", "#   #", " ### ", "#   #", " ### "},
+	'9': {" ### ", "#   #", " ####", "    #", " ### "},
+	' ': {"   ", "   ", "   ", "   ", "   "},
+	'!': {"#", "#", "#", " ", "#"},
+	'?': {" ### ", "#   #", "  ## ", "     ", "  #  "},
+	'.': {" ", " ", " ", " ", "#"},
+	'-': {"    ", "    ", "####", "    ", "    "},
+}
+
+// renderBanner draws text in bannerFont, upper-casing letters and showing
+// characters the font lacks as '?'. Lines are padded to the same width so the
+// banner stays intact when the chat aligns messages to the right.
+func renderBanner(text string) string {
+	var lines [bannerHeight]strings.Builder
+	for i, r := range []rune(strings.ToUpper(text)) {
+		glyph, ok := bannerFont[r]
+		if !ok {
+			glyph = bannerFont['?']
+		}
+		for row := range lines {
+			if i > 0 {
+				lines[row].WriteByte(' ')
+			}
+			lines[row].WriteString(glyph[row])
+		}
+	}
+
+	rows := make([]string, bannerHeight)
+	for row := range lines {
+		rows[row] = lines[row].String()
+	}
+	return strings.Join(rows, "\n")
+}
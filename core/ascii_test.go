@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderBanner(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hi!", []string{
+			"#   # ##### #",
+			"#   #   #   #",
+			"#####   #   #",
+			"#   #   #    ",
+			"#   # ##### #",
+		}},
+		{"a~", []string{ // unknown characters become '?'
+			" ###   ### ",
+			"#   # #   #",
+			"#####   ## ",
+			"#   #      ",
+			"#   #   #  ",
+		}},
+	}
+	for _, tt := range tests {
+		if got := renderBanner(tt.text); got != strings.Join(tt.want, "\n") {
+			t.Errorf("renderBanner(%q) =\n%s\nwant\n%s", tt.text, got, strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestBannerGlyphsAreEvenlySized(t *testing.T) {
+	for r, glyph := range bannerFont {
+		for row, line := range glyph {
+			if len(line) != len(glyph[0]) || strings.Trim(line, "# ") != "" {
+				t.Errorf("glyph %q row %d = %q: rows must be the same width and only '#' and spaces", r, row, line)
+			}
+		}
+	}
+}
+
+func TestAsciiLimitsLength(t *testing.T) {
+	if result := ProcessCommand("/ascii "+strings.Repeat("x", maxBannerLen+1), "alice"); result.Message != nil {
+		t.Error("/ascii sent a banner over the length limit")
+	}
+	result := ProcessCommand("/ascii ok", "alice")
+	if result.Message == nil || result.Message.Text != renderBanner("ok") || result.Message.Nick != "alice" {
+		t.Errorf("/ascii ok = %+v, want alice's banner", result.Message)
+	}
+}
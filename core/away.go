@@ -0,0 +1,189 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// awayFlag is MsgTypeAway's Data while away
+const awayFlag = "1"
+
+// awayNotice is the system message announcing an away status change
+func awayNotice(nick string, away bool, text string) string {
+	if !away {
+		return i18n.T("away.back", nick)
+	}
+	return i18n.T("away.away", nick, text)
+}
+
+// awayReplyText is the automatic answer to a private message while away
+func awayReplyText(text string) string {
+	return i18n.T("away.reply", text)
+}
+
+// awayMessage builds the status message a client sends the host
+func awayMessage(nick string, away bool, text string) Message {
+	msg := Message{Type: MsgTypeAway, Nick: nick}
+	if away {
+		msg.Text, msg.Data = text, awayFlag
+	}
+	return msg
+}
+
+// announceAway tells the host UI and the room about an away status change.
+// An observer's change is for the host only, as the room doesn't know them.
+func (h *Host) announceAway(nick string, away bool, text string, observer bool) {
+	notice := awayNotice(nick, away, text)
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(notice)
+	}
+	if h.callbacks.OnUserList != nil {
+		h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
+	}
+	if observer {
+		return
+	}
+	h.broadcast(Message{Type: MsgTypeSystem, Text: notice}, nil)
+	h.broadcast(awayMessage(nick, away, text), nil)
+}
+
+// setClientAway records a client's away status and announces changes
+func (h *Host) setClientAway(c *Client, msg Message) {
+	away := msg.Data == awayFlag
+	h.mutex.Lock()
+	changed := c.away != away || c.awayText != msg.Text
+	c.away, c.awayText = away, msg.Text
+	h.mutex.Unlock()
+	if changed {
+		h.announceAway(c.nick, away, msg.Text, c.observer)
+	}
+}
+
+// SetAway marks the host away with text, or back
+func (h *Host) SetAway(away bool, text string) {
+	h.mutex.Lock()
+	h.away, h.awayText = away, text
+	if !away {
+		clear(h.awayReplied)
+	}
+	h.mutex.Unlock()
+	h.announceAway(h.nick, away, text, false)
+}
+
+// replyAway answers a private message to the host with its away message,
+// once per sender until the host is back
+func (h *Host) replyAway(from string) {
+	h.mutex.Lock()
+	reply := h.away && !h.awayReplied[from]
+	if reply {
+		h.awayReplied[from] = true
+	}
+	text := h.awayText
+	h.mutex.Unlock()
+	if reply {
+		h.sendToNick(from, Message{Type: MsgTypePrivate, Nick: h.nick, Target: from, Text: awayReplyText(text)})
+	}
+}
+
+// Away reports whether the host is marked away
+func (h *Host) Away() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.away
+}
+
+// SetAway marks us away with text, or back
+func (c *ChatClient) SetAway(away bool, text string) {
+	c.awayMu.Lock()
+	c.away, c.awayText = away, text
+	if !away {
+		clear(c.awayReplied)
+	}
+	c.awayMu.Unlock()
+	SendMessage(c.hostConn(), awayMessage(c.nick, away, text))
+}
+
+// replyAway answers a private message with our away message, once per
+// sender until we are back
+func (c *ChatClient) replyAway(from string) {
+	c.awayMu.Lock()
+	reply := c.away && from != c.nick && !c.awayReplied[from]
+	if reply {
+		c.awayReplied[from] = true
+	}
+	text := c.awayText
+	c.awayMu.Unlock()
+	if reply {
+		SendMessage(c.hostConn(), Message{Type: MsgTypePrivate, Nick: c.nick, Target: from, Text: awayReplyText(text)})
+	}
+}
+
+// Away reports whether we are marked away
+func (c *ChatClient) Away() bool {
+	c.awayMu.Lock()
+	defer c.awayMu.Unlock()
+	return c.away
+}
+
+// AwayStatus is a Host or ChatClient, as watched by IdleTimer
+type AwayStatus interface {
+	Away() bool
+	SetAway(away bool, text string)
+}
+
+// idleAwayText is the away message IdleTimer sets
+const idleAwayText = "idle"
+
+// IdleTimer marks the user away after Settings.IdleAway without activity,
+// and back on the next activity. Away set by hand with /afk is left alone.
+type IdleTimer struct {
+	mutex   sync.Mutex
+	session AwayStatus
+	timer   *time.Timer
+	auto    bool // away was set by the timer
+}
+
+// NewIdleTimer starts watching for inactivity; it does nothing when Settings.IdleAway is 0
+func NewIdleTimer(session AwayStatus) *IdleTimer {
+	t := &IdleTimer{session: session}
+	if Settings.IdleAway > 0 {
+		t.timer = time.AfterFunc(Settings.IdleAway, t.idle)
+	}
+	return t
+}
+
+// idle marks the user away unless they already are
+func (t *IdleTimer) idle() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.session.Away() {
+		t.session.SetAway(true, idleAwayText)
+		t.auto = true
+	}
+}
+
+// Touch records activity, clearing an away status the timer set
+func (t *IdleTimer) Touch() {
+	if t.timer == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.timer.Reset(Settings.IdleAway)
+	if t.auto {
+		t.auto = false
+		if t.session.Away() {
+			t.session.SetAway(false, "")
+		}
+	}
+}
+
+// Stop stops watching for inactivity
+func (t *IdleTimer) Stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// Ban keeps a banned address out of the room. Nick is who was using the
+// address when it was banned, or who last tried to join from it since.
+type Ban struct {
+	IP   string
+	Nick string
+}
+
+// remoteIP returns the IP address a connection comes from
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// Bans returns the host's ban list
+func (h *Host) Bans() []Ban {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return slices.Clone(h.bans)
+}
+
+// checkBanned reports whether conn's address is banned, noting nick as the
+// ban's last-known nick when it is
+func (h *Host) checkBanned(conn net.Conn, nick string) bool {
+	ip := remoteIP(conn)
+	h.mutex.Lock()
+	i := slices.IndexFunc(h.bans, func(b Ban) bool { return b.IP == ip })
+	changed := i >= 0 && h.bans[i].Nick != nick
+	if changed {
+		h.bans[i].Nick = nick
+	}
+	h.mutex.Unlock()
+
+	if changed {
+		h.bansChanged()
+	}
+	return i >= 0
+}
+
+// bansChanged stores the ban list in Settings.Bans and tells the UI, so it
+// can be kept across restarts
+func (h *Host) bansChanged() {
+	bans := h.Bans()
+	Settings.Bans = bans
+	if h.callbacks.OnBansChanged != nil {
+		h.callbacks.OnBansChanged(bans)
+	}
+}
+
+// ban disconnects nick and keeps their address out, returning local output
+func (h *Host) ban(nick string) string {
+	if nick == h.nick {
+		return i18n.T("ban.self") + "\n"
+	}
+
+	h.mutex.Lock()
+	var target *Client
+	for _, client := range h.clients {
+		if client.nick == nick {
+			target = client
+			break
+		}
+	}
+	if target == nil {
+		h.mutex.Unlock()
+		return i18n.T("user.notFound", nick) + "\n"
+	}
+	ip := remoteIP(target.conn)
+	h.bans = slices.DeleteFunc(h.bans, func(b Ban) bool { return b.IP == ip })
+	h.bans = append(h.bans, Ban{IP: ip, Nick: nick})
+	h.mutex.Unlock()
+	h.bansChanged()
+
+	target.kicked.Store(true)
+	SendMessage(target.conn, Message{Type: MsgTypeRefused, Text: i18n.T("ban.banned")})
+	target.conn.Close()
+	return i18n.T("ban.done", nick, ip) + "\n"
+}
+
+// banList describes the host's bans for /banlist
+func (h *Host) banList() string {
+	bans := h.Bans()
+	if len(bans) == 0 {
+		return i18n.T("ban.none") + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(i18n.T("ban.list") + "\n")
+	for _, ban := range bans {
+		fmt.Fprintf(&b, "  %s (%s)\n", ban.IP, ban.Nick)
+	}
+	return b.String()
+}
+
+// unban lifts the bans matching an IP or last-known nick, returning local output
+func (h *Host) unban(who string) string {
+	h.mutex.Lock()
+	var lifted []string
+	h.bans = slices.DeleteFunc(h.bans, func(b Ban) bool {
+		if b.IP == who || strings.EqualFold(b.Nick, who) {
+			lifted = append(lifted, fmt.Sprintf("%s (%s)", b.IP, b.Nick))
+			return true
+		}
+		return false
+	})
+	h.mutex.Unlock()
+
+	if len(lifted) == 0 {
+		return i18n.T("ban.noMatch", who) + "\n"
+	}
+	h.bansChanged()
+	return i18n.T("ban.lifted", strings.Join(lifted, ", ")) + "\n"
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"cabinchat/logger"
+	"cabinchat/media"
+)
+
+// BellEvent is something the terminal client can ring for
+type BellEvent string
+
+const (
+	BellMessage BellEvent = "message" // someone else's chat message
+	BellMention BellEvent = "mention" // a message with an @mention of us
+	BellPrivate BellEvent = "private" // a private message to us
+	BellOwn     BellEvent = "own"     // our own message coming back
+	BellFile    BellEvent = "file"    // a file offer
+)
+
+// bellEvents lists the events in the order -bell documents them
+var bellEvents = []BellEvent{BellMessage, BellMention, BellPrivate, BellOwn, BellFile}
+
+// Bell cues, besides a number of beeps or a command
+const (
+	bellOff   = "off"   // stay silent
+	bellSound = "sound" // the message or mention sound, or one beep without audio
+)
+
+// beepGap separates the beeps of a pattern, as terminals merge bells rung
+// back to back
+const beepGap = 150 * time.Millisecond
+
+// maxBeeps caps a bell pattern
+const maxBeeps = 9
+
+// ParseBells reads a -bell value: comma-separated event=cue pairs, such as
+// "mention=2,own=off,private=paplay ping.wav". A cue is "off", "sound", a
+// number of beeps, or a command to run.
+func ParseBells(value string) (map[BellEvent]string, error) {
+	bells := make(map[BellEvent]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		event, cue, ok := strings.Cut(pair, "=")
+		event, cue = strings.TrimSpace(event), strings.TrimSpace(cue)
+		if !ok || cue == "" {
+			return nil, fmt.Errorf("invalid bell %q, want event=cue", pair)
+		}
+		if !slices.Contains(bellEvents, BellEvent(event)) {
+			return nil, fmt.Errorf("unknown bell event %q, want one of %s", event, joinBellEvents())
+		}
+		if n, err := strconv.Atoi(cue); err == nil && (n < 1 || n > maxBeeps) {
+			return nil, fmt.Errorf("bell %q: beeps must be 1-%d", pair, maxBeeps)
+		}
+		bells[BellEvent(event)] = cue
+	}
+	return bells, nil
+}
+
+func joinBellEvents() string {
+	names := make([]string, len(bellEvents))
+	for i, event := range bellEvents {
+		names[i] = string(event)
+	}
+	return strings.Join(names, ", ")
+}
+
+// bellCue returns the cue set for event, or its default: silence for our
+// own messages and, with Settings.MentionsOnly, for plain messages; the
+// message sound for the rest
+func bellCue(event BellEvent) string {
+	if cue, ok := Settings.Bells[event]; ok {
+		return cue
+	}
+	if event == BellOwn || event == BellMessage && Settings.MentionsOnly {
+		return bellOff
+	}
+	return bellSound
+}
+
+// PlayBell rings the terminal client's cue for event if Settings.Sound is
+// on. Like sounds, a cue repeated within soundInterval is dropped.
+func PlayBell(event BellEvent) {
+	cue := bellCue(event)
+	switch {
+	case !Settings.Sound || cue == bellOff:
+	case cue == bellSound && event == BellMention:
+		PlaySound(media.SoundMention)
+	case cue == bellSound:
+		PlaySound(media.SoundMessage)
+	case !soundDue(event):
+	default:
+		if n, err := strconv.Atoi(cue); err == nil {
+			go beep(n)
+		} else {
+			go runBell(cue)
+		}
+	}
+}
+
+// beep rings the terminal bell n times
+func beep(n int) {
+	for i := range n {
+		if i > 0 {
+			time.Sleep(beepGap)
+		}
+		fmt.Print("\a")
+	}
+}
+
+// runBell runs a bell command through the shell, e.g. to play a sound file
+func runBell(command string) {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	if err := cmd.Run(); err != nil {
+		logger.Warnf("Bell command %q: %v", command, err)
+	}
+}
+
+// IsMention reports whether text addresses nick with an @mention. The
+// mention has to stand on its own: "@bob" in "mail@bob" or "@bobby" isn't one.
+func IsMention(text, nick string) bool {
+	if nick == "" {
+		return false
+	}
+	text, mention := strings.ToLower(text), "@"+strings.ToLower(nick)
+	for i := 0; ; {
+		at := strings.Index(text[i:], mention)
+		if at < 0 {
+			return false
+		}
+		start, end := i+at, i+at+len(mention)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(text) || !isNickRune(after)) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isWordRune reports whether r can be part of a word, so an @ after it
+// belongs to something like an email address
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isNickRune reports whether r can continue a nickname
+func isNickRune(r rune) bool {
+	return r == '-' || isWordRune(r)
+}
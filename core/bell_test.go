@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestIsMention(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"@bob", true},
+		{"hi @bob", true},
+		{"hi @Bob!", true},
+		{"@bob, look", true},
+		{"(@bob)", true},
+		{"@bobby", false},
+		{"@bob_", false},
+		{"@bob-2", false},
+		{"@bob2", false},
+		{"mail@bob", false},
+		{"x_@bob", false},
+		{"@bobby and @bob", true},
+		{"bob", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsMention(tt.text, "bob"); got != tt.want {
+			t.Errorf("IsMention(%q, \"bob\") = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+	if IsMention("@bob", "") {
+		t.Error("an empty nick is never mentioned")
+	}
+}
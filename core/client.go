@@ -2,22 +2,29 @@ package core
 
 import (
 	"bufio"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
 
+	"cabinchat/i18n"
+	"cabinchat/logger"
 	"cabinchat/media"
 )
 
 // PendingFile represents a file offer waiting for acceptance
 type PendingFile struct {
 	From     string
+	Addr     string // sender's IP as the host saw it, "" from older hosts
 	Filename string
 	Size     string
 }
@@ -27,61 +34,167 @@ type ClientCallbacks struct {
 	OnMessageReceived func(msg Message)
 	OnSystemMessage   func(text string)
 	OnUserList        func(users []string)
+	OnPresence        func(nick string, joined bool) // Someone's arrival or departure was announced
 	OnFileOffer       func(offer PendingFile)
 	OnFileAccepted    func(sender string)
 	OnFileRejected    func(sender string)
-	OnFileReceived    func(filename string, data string, sender string)
+	OnFileReceived    func(filename string, data []byte, sender string, err error) // nil = auto-save to Settings.DownloadDir
 	OnConnectionLost  func()
+	OnReconnecting    func(room string) // Connection dropped, rediscovering the room
+	OnReconnected     func(addr string) // Room found again and rejoined
+	OnPoll            func(poll Poll)   // Poll opened or closed by the host
+	OnReaction        func(id string, emoji string, count int)
+	OnClear           func()            // /clear; nil = ANSI clear in output
+	OnExport          func(path string) // /export; path "" = let the user choose
+	OnNickChanged     func(nick string)
+	OnTopic           func(topic string) // Room topic set or cleared by the host
+	OnMessageEdited   func(id string, text string)
+	OnMessageDeleted  func(id string)
+	OnHostHandoff     func(from string) (link string, err error) // Start hosting for the host handing over, nil = refuse
+	OnAliasesChanged  func(aliases []Alias)                      // /alias changed Settings.Aliases, for keeping them across restarts
+	OnSaveDirChanged  func(dir string)                           // /savedir changed Settings.DownloadDir, for keeping it across restarts
 }
 
 // ChatClient represents a chat client connection
 type ChatClient struct {
 	conn            net.Conn
+	room            DiscoveredRoom // where we are connected, Name is used to rediscover it
+	connMu          sync.RWMutex   // guards conn and room: reconnecting replaces them while the UI sends
 	nick            string
 	reader          *bufio.Reader
+	closed          atomic.Bool // set by Close, so a deliberate disconnect isn't retried
+	refused         bool        // the host won't have us, so reconnecting is pointless
 	pingStart       time.Time
-	pendingFile     *PendingFile // incoming offer
-	lastOfferedFile string       // path of file we offered
-	lastOfferedTo   string       // who we offered to
+	statsAsked      time.Time // when /stats was sent, for the latency in its answer
+	lastMsgID       string    // ID of the latest chat message, for /react
+	lastOwnMsgID    string    // ID of our latest chat message, for /edit and /delete
+	stats           sessionCounters
+	handoff         *DiscoveredRoom // where the host handed the room over to
+	pendingFile     *PendingFile    // incoming offer
+	lastOfferedFile string          // name of file we offered
+	lastOfferedData []byte          // its contents, sent once accepted
+	lastOfferedTo   string          // who we offered to
+	offerMu         sync.Mutex      // guards pendingFile and the lastOffered fields: offers are made and answered on both the UI and the receive loop
+	gzip            bool            // host accepts gzipped file data
+	away            bool            // set by /afk or the idle timer
+	awayText        string
+	awayReplied     map[string]bool // who got the away message since going away
+	lastPrivateFrom string          // who /r replies to
+	awayMu          sync.Mutex      // guards the away fields and lastPrivateFrom, shared by the receive loop, idle timer and UI
 	mediaManager    *media.MediaManager
 	callbacks       ClientCallbacks
+	transport       Transport
+	slots           transferSlots // files being sent, see Settings.MaxTransfers
+	quit            chan struct{} // closed by Close, so queued sends give up
+	out             *outbox       // chat messages until the host echoes them back
+	users           userList      // who is in the room, kept from the host's updates
 }
 
-// NewChatClient creates a new client and connects to the host
-func NewChatClient(host string, port int, nick string, app fyne.App, callbacks ClientCallbacks) (*ChatClient, error) {
-	addr := fmt.Sprintf("%s:%d", host, port)
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
+// NewChatClient creates a new client and connects to the host. A nil
+// transport means TCP.
+func NewChatClient(room DiscoveredRoom, nick string, app fyne.App, callbacks ClientCallbacks, transport Transport) (*ChatClient, error) {
+	client := &ChatClient{
+		nick:        nick,
+		callbacks:   callbacks,
+		transport:   transportOrDefault(transport),
+		slots:       newTransferSlots(Settings.MaxTransfers),
+		quit:        make(chan struct{}),
+		out:         newOutbox(),
+		awayReplied: make(map[string]bool),
+		stats:       sessionCounters{started: time.Now()},
+	}
+	if isOwnRoom(room) {
+		return nil, ErrOwnRoom
+	}
+	if err := client.connect(room); err != nil {
+		return nil, err
 	}
 
-	client := &ChatClient{
-		conn:      conn,
-		nick:      nick,
-		reader:    bufio.NewReader(conn),
-		callbacks: callbacks,
-	}
-
-	// Initialize Media Manager
-	client.mediaManager = media.NewMediaManager(app, func(target string, data string) {
-		msg := Message{
-			Type:   MsgTypeWebRTC,
-			Nick:   nick,
-			Text:   "signal",
-			Data:   data,
-			Target: target,
-		}
-		SendMessage(conn, msg)
-	})
+	// Initialize Media Manager; without an app (the terminal client) there is
+	// nowhere to show a call, so calls are refused instead
+	if app != nil {
+		client.mediaManager = media.NewMediaManager(app, client.sendSignal)
+		client.mediaManager.OnRing = func(string) { PlaySound(media.SoundCall) }
+	}
+
+	return client, nil
+}
+
+// connect dials the room and sends the join message
+func (c *ChatClient) connect(room DiscoveredRoom) error {
+	conn, err := dialRoom(c.transport, room)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
 
 	// Send join message
-	err = SendMessage(conn, Message{Type: MsgTypeJoin, Nick: nick})
+	join := Message{Type: MsgTypeJoin, Nick: c.nick, Enc: strings.Join([]string{EncGzip, EncFramed, EncBinary}, ",")}
+	if Settings.Observe {
+		join.Data = observeFlag
+	}
+	err = SendMessage(conn, join)
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to join: %w", err)
+		return fmt.Errorf("failed to join: %w", err)
 	}
 
-	return client, nil
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.closed.Load() {
+		conn.Close() // closed while we were dialling
+		return net.ErrClosed
+	}
+	c.conn = framed(conn, Message{}) // nothing agreed until the host echoes the join
+	c.reader = bufio.NewReader(conn)
+	c.room = room
+	c.gzip = false // until the host acknowledges
+	return nil
+}
+
+// hostConn returns the connection to the host, which changes on reconnects
+func (c *ChatClient) hostConn() net.Conn {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.conn
+}
+
+// currentRoom returns the room we are connected to
+func (c *ChatClient) currentRoom() DiscoveredRoom {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.room
+}
+
+// rediscover looks the room up again by its mDNS name and reconnects to
+// wherever it is now advertised, e.g. after the host changed networks.
+// It gives up after Settings.RediscoverTimeout.
+func (c *ChatClient) rediscover() bool {
+	if c.room.Name == "" {
+		return false
+	}
+	if c.callbacks.OnReconnecting != nil {
+		c.callbacks.OnReconnecting(c.room.Name)
+	}
+
+	deadline := time.Now().Add(Settings.RediscoverTimeout)
+	for time.Now().Before(deadline) && !c.closed.Load() {
+		room, err := FindRoomByName(c.room.Name)
+		if err != nil {
+			return false // mDNS unavailable
+		}
+		if room == nil {
+			continue
+		}
+		room.Fingerprint = c.room.Fingerprint // keep trusting the same certificate
+		if err := c.connect(*room); err != nil {
+			continue
+		}
+		if c.callbacks.OnReconnected != nil {
+			c.callbacks.OnReconnected(room.Address())
+		}
+		return true
+	}
+	return false
 }
 
 // Start begins the chat client listener
@@ -94,64 +207,173 @@ func (c *ChatClient) Start() {
 func (c *ChatClient) receiveLoop() {
 	for {
 		msg, err := ReadMessage(c.reader)
+		if errors.Is(err, ErrBadMessage) {
+			logger.Warnf("Skipping message from host: %v", err)
+			continue
+		}
 		if err != nil {
+			c.hostConn().Close()
+			if !c.closed.Load() && !c.refused && (c.followHandoff() || c.rediscover()) {
+				continue
+			}
+			c.reportUndelivered()
 			if c.callbacks.OnConnectionLost != nil {
 				c.callbacks.OnConnectionLost()
 			}
 			return
 		}
 
-		switch msg.Type {
-		case MsgTypeMsg:
-			if c.callbacks.OnMessageReceived != nil {
-				c.callbacks.OnMessageReceived(msg)
-			}
-		case MsgTypeSystem:
-			if c.callbacks.OnSystemMessage != nil {
-				c.callbacks.OnSystemMessage(msg.Text)
-			}
-		case MsgTypePong:
-			// Just log locally or update UI status if we had one for ping
-			elapsed := time.Since(c.pingStart)
+		if c.handleMessage(msg) {
+			return
+		}
+	}
+}
+
+// handleMessage acts on one message from the host, reporting whether the
+// connection is done with. A panic is logged and the message skipped, so a
+// bug in handling one message doesn't take the app down.
+func (c *ChatClient) handleMessage(msg Message) (done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic("handling "+msg.Type+" from host", r)
+		}
+	}()
+
+	switch msg.Type {
+	case MsgTypeJoin:
+		// Host acknowledged the join and the encodings it accepts
+		c.gzip = acceptsGzip(msg)
+		framed(c.hostConn(), msg)
+		if err := c.out.flush(c.hostConn()); err != nil {
+			logger.Warnf("Resending queued messages: %v", err)
+		}
+	case MsgTypeMsg:
+		c.lastMsgID = msg.ID
+		if msg.Nick == c.nick {
+			c.lastOwnMsgID = msg.ID
+			c.out.ack(msg.Nonce)
+		}
+		c.stats.message(msg.Nick == c.nick)
+		if c.callbacks.OnMessageReceived != nil {
+			c.callbacks.OnMessageReceived(msg)
+		}
+	case MsgTypeDropped:
+		c.out.ack(msg.Nonce) // the host's flood warning already said so
+	case MsgTypePrivate:
+		c.awayMu.Lock()
+		c.lastPrivateFrom = msg.Nick
+		c.awayMu.Unlock()
+		c.stats.message(false)
+		if c.callbacks.OnMessageReceived != nil {
+			c.callbacks.OnMessageReceived(msg)
+		}
+		c.replyAway(msg.Nick)
+	case MsgTypeSystem:
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(msg.Text)
+		}
+		if c.callbacks.OnPresence != nil && (msg.Data == PresenceJoined || msg.Data == PresenceLeft) {
+			c.callbacks.OnPresence(msg.Nick, msg.Data == PresenceJoined)
+		}
+	case MsgTypeRefused:
+		c.refused = true
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(msg.Text)
+		}
+	case MsgTypeStats:
+		c.showStats(msg)
+	case MsgTypePong:
+		// Just log locally or update UI status if we had one for ping
+		elapsed := time.Since(c.pingStart)
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(i18n.T("cmd.pong", elapsed.Milliseconds()))
+		}
+	case MsgTypeUserList, MsgTypeUserJoined, MsgTypeUserLeft, MsgTypeUserRenamed, MsgTypeAway:
+		c.updateUsers(msg)
+	case MsgTypeReaction:
+		count, _ := strconv.Atoi(msg.Data)
+		if c.callbacks.OnReaction != nil {
+			c.callbacks.OnReaction(msg.ID, msg.Text, count)
+		}
+	case MsgTypeEdit:
+		if c.callbacks.OnMessageEdited != nil {
+			c.callbacks.OnMessageEdited(msg.ID, msg.Text)
+		}
+	case MsgTypeDelete:
+		if c.callbacks.OnMessageDeleted != nil {
+			c.callbacks.OnMessageDeleted(msg.ID)
+		}
+	case MsgTypeHandoff:
+		if c.handleHandoff(msg) {
+			return true
+		}
+	case MsgTypeTopic:
+		if c.callbacks.OnTopic != nil {
+			c.callbacks.OnTopic(msg.Text)
+		}
+	case MsgTypePoll:
+		var poll Poll
+		if err := json.Unmarshal([]byte(msg.Data), &poll); err == nil && c.callbacks.OnPoll != nil {
+			c.callbacks.OnPoll(poll)
+		}
+	case MsgTypeFileOffer:
+		if IsTrusted(msg.Nick, msg.Addr) {
+			SendMessage(c.hostConn(), Message{Type: MsgTypeFileAcc, Nick: c.nick, Text: msg.Nick})
 			if c.callbacks.OnSystemMessage != nil {
-				c.callbacks.OnSystemMessage(fmt.Sprintf("Pong! %dms", elapsed.Milliseconds()))
-			}
-		case MsgTypeUserList:
-			if c.callbacks.OnUserList != nil {
-				users := strings.Split(msg.Text, ", ")
-				c.callbacks.OnUserList(users)
-			}
-		case MsgTypeFileOffer:
-			c.pendingFile = &PendingFile{From: msg.Nick, Filename: msg.Text, Size: msg.Data}
-			if c.callbacks.OnFileOffer != nil {
-				c.callbacks.OnFileOffer(*c.pendingFile)
+				c.callbacks.OnSystemMessage(i18n.T("file.autoAcceptedSized", msg.Text, msg.Data, msg.Nick))
 			}
-		case MsgTypeFileAcc:
-			if c.lastOfferedFile != "" {
-				c.sendActualFile(c.lastOfferedFile, msg.Nick)
-				c.lastOfferedFile = ""
-				c.lastOfferedTo = ""
-				if c.callbacks.OnFileAccepted != nil {
-					c.callbacks.OnFileAccepted(msg.Nick)
-				}
-			}
-		case MsgTypeFileRej:
-			c.lastOfferedFile = ""
-			c.lastOfferedTo = ""
-			if c.callbacks.OnFileRejected != nil {
-				c.callbacks.OnFileRejected(msg.Nick)
-			}
-		case MsgTypeFile:
-			// Actual file data received
-			// For now, auto-save to current dir, but UI notification is important
-			saveFile(msg.Text, msg.Data, msg.Nick)
-			if c.callbacks.OnFileReceived != nil {
-				c.callbacks.OnFileReceived(msg.Text, msg.Data, msg.Nick)
+			return false
+		}
+		offer := PendingFile{From: msg.Nick, Addr: msg.Addr, Filename: msg.Text, Size: msg.Data}
+		c.offerMu.Lock()
+		c.pendingFile = &offer
+		c.offerMu.Unlock()
+		if c.callbacks.OnFileOffer != nil {
+			c.callbacks.OnFileOffer(offer)
+		}
+	case MsgTypeFileAcc:
+		if name, data, ok := c.takeOffer(); ok {
+			c.sendActualFile(name, data, msg.Nick)
+			if c.callbacks.OnFileAccepted != nil {
+				c.callbacks.OnFileAccepted(msg.Nick)
 			}
-		case MsgTypeWebRTC:
-			c.mediaManager.HandleSignal(msg.Nick, msg.Data)
 		}
+	case MsgTypeFileCancel:
+		c.offerWithdrawn(msg)
+	case MsgTypeFileRej:
+		c.takeOffer()
+		if c.callbacks.OnFileRejected != nil {
+			c.callbacks.OnFileRejected(msg.Nick)
+		}
+	case MsgTypeFile:
+		// Actual file data received
+		// For now, auto-save to current dir, but UI notification is important.
+		// The connection delivers files one at a time, so receiving takes no
+		// transfer slot: waiting for one would only stall this loop.
+		data, err := decodeFile(msg)
+		if errors.Is(err, ErrChecksumMismatch) {
+			SendMessage(c.hostConn(), Message{Type: MsgTypeFileBad, Nick: c.nick, Text: msg.Text, Target: msg.Nick})
+		}
+		if err == nil {
+			c.stats.filesReceived.Add(1)
+		}
+		if c.callbacks.OnFileReceived != nil {
+			c.callbacks.OnFileReceived(msg.Text, data, msg.Nick, err)
+		} else if err == nil {
+			SaveFile(msg.Text, data)
+		}
+	case MsgTypeFileBad:
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(i18n.T("file.corrupted", msg.Nick, msg.Text))
+		}
+	case MsgTypeWebRTC:
+		if c.mediaManager == nil {
+			c.refuseCall(msg.Nick, msg.Data)
+			break
+		}
+		c.mediaManager.HandleSignal(msg.Nick, msg.Data)
 	}
+	return false
 }
 
 // SendText processes input from UI (commands or regular text)
@@ -175,59 +397,174 @@ func (c *ChatClient) SendText(text string) (string, error) {
 		if result.NickChange != "" {
 			oldNick := c.nick
 			c.nick = result.NickChange
-			SendMessage(c.conn, Message{Type: MsgTypeNick, Nick: oldNick, Text: result.NickChange})
-			// UI should update nick display via return value or callback if needed
+			SendMessage(c.hostConn(), Message{Type: MsgTypeNick, Nick: oldNick, Text: result.NickChange})
+			if c.callbacks.OnNickChanged != nil {
+				c.callbacks.OnNickChanged(c.nick)
+			}
+		}
+		if result.ClearScreen {
+			if c.callbacks.OnClear != nil {
+				c.callbacks.OnClear()
+			} else {
+				output += clearScreenANSI
+			}
+		}
+		if result.Export {
+			if c.callbacks.OnExport != nil {
+				c.callbacks.OnExport(result.ExportPath)
+			} else {
+				output += i18n.T("cmd.noExport") + "\n"
+			}
 		}
 		if result.RequestUsers {
-			SendMessage(c.conn, Message{Type: MsgTypeUserList})
+			SendMessage(c.hostConn(), Message{Type: MsgTypeUserList})
+		}
+		if result.Whois != "" {
+			output += i18n.T("cmd.hostOnlyWhois") + "\n"
+		}
+		if result.Away {
+			c.SetAway(true, result.AwayText)
+		}
+		if result.Back {
+			if c.Away() {
+				c.SetAway(false, "")
+			} else {
+				output += i18n.T("cmd.notAway") + "\n"
+			}
+		}
+		if result.PrivateTo != "" {
+			output += c.sendPrivate(result.PrivateTo, result.PrivateText)
+		}
+		if result.Reply != "" {
+			output += c.replyPrivate(result.Reply)
+		}
+		if result.Invite != "" {
+			invite(result.Invite, c.nick, JoinURL(c.currentRoom()), c.callbacks.OnSystemMessage)
+			output += i18n.T("invite.looking", result.Invite) + "\n"
+		}
+		if result.StartPoll != nil || result.ClosePoll {
+			output += i18n.T("cmd.hostOnlyPoll") + "\n"
+		}
+		if result.SetTopic {
+			output += i18n.T("cmd.hostOnlyTopic") + "\n"
+		}
+		if result.Motd != "" || result.ShowMotd {
+			output += i18n.T("cmd.hostOnlyMotd") + "\n"
+		}
+		if result.Handoff != "" {
+			output += i18n.T("cmd.hostOnlyHandoff") + "\n"
+		}
+		if result.Ban != "" || result.BanList || result.Unban != "" {
+			output += i18n.T("cmd.hostOnlyBans") + "\n"
+		}
+		if result.KickAll {
+			output += i18n.T("cmd.hostOnlyKickAll") + "\n"
+		}
+		if result.Alias != nil {
+			output += setAlias(*result.Alias, c.callbacks.OnAliasesChanged)
+		}
+		if result.AliasList {
+			output += aliasList()
+		}
+		if result.SaveDir != "" {
+			output += setSaveDir(result.SaveDir, c.callbacks.OnSaveDirChanged)
+		}
+		if result.ShowSaveDir {
+			output += saveDirInfo()
+		}
+		if result.Edit != "" || result.Delete {
+			id, err := c.lastOwnMessage()
+			if err == nil && result.Delete {
+				err = c.DeleteMessage(id)
+			} else if err == nil {
+				err = c.EditMessage(id, result.Edit)
+			}
+			if err != nil {
+				output += i18n.T("error", err) + "\n"
+			}
+		}
+		if result.React != "" {
+			if c.lastMsgID != "" {
+				c.React(c.lastMsgID, result.React)
+			} else {
+				output += i18n.T("cmd.noReactTarget") + "\n"
+			}
+		}
+		if result.Vote > 0 {
+			SendMessage(c.hostConn(), Message{Type: MsgTypeVote, Nick: c.nick, Text: strconv.Itoa(result.Vote)})
 		}
 		if result.SendPing {
 			c.pingStart = time.Now()
-			SendMessage(c.conn, Message{Type: MsgTypePing})
+			SendMessage(c.hostConn(), Message{Type: MsgTypePing})
+		}
+		if result.Stats {
+			if err := c.requestStats(); err != nil {
+				output += i18n.T("cmd.statsFailed", err) + "\n"
+			}
 		}
 		// FileSend and FilePicker need rework for UI.
 		// For now we assume UI handles file picking separately.
 		// If user types /send <file>, we might support it if path is valid.
 		if result.FileSend != nil {
 			c.sendFileOffer(result.FileSend.Path, result.FileSend.Target)
-			output += fmt.Sprintf("Offering file: %s\n", result.FileSend.Path)
+			output += i18n.T("file.offering", result.FileSend.Path) + "\n"
 		}
 
 		if result.AcceptFile {
-			if c.pendingFile != nil {
-				SendMessage(c.conn, Message{Type: MsgTypeFileAcc, Nick: c.nick, Text: c.pendingFile.From})
-				output += fmt.Sprintf("Accepted file from %s\n", c.pendingFile.From)
-				c.pendingFile = nil
+			if offer := c.takePendingFile(result.FileFrom); offer != nil {
+				SendMessage(c.hostConn(), Message{Type: MsgTypeFileAcc, Nick: c.nick, Text: offer.From})
+				output += i18n.T("file.acceptedFrom", offer.From) + "\n"
 			} else {
-				output += "No pending file to accept\n"
+				output += i18n.T("file.noneToAccept") + "\n"
 			}
 		}
+		if result.Transfers {
+			output += transferList(c.Transfers())
+		}
+		if result.CancelTransfer > 0 {
+			output += c.cancelTransfer(result.CancelTransfer)
+		}
 		if result.RejectFile {
-			if c.pendingFile != nil {
-				SendMessage(c.conn, Message{Type: MsgTypeFileRej, Nick: c.nick, Text: c.pendingFile.From})
-				output += fmt.Sprintf("Rejected file from %s\n", c.pendingFile.From)
-				c.pendingFile = nil
+			if offer := c.takePendingFile(result.FileFrom); offer != nil {
+				SendMessage(c.hostConn(), Message{Type: MsgTypeFileRej, Nick: c.nick, Text: offer.From})
+				output += i18n.T("file.rejectedFrom", offer.From) + "\n"
 			} else {
-				output += "No pending file to reject\n"
+				output += i18n.T("file.noneToReject") + "\n"
 			}
 		}
 		if result.Message != nil {
-			SendMessage(c.conn, *result.Message)
+			SendMessage(c.hostConn(), *result.Message)
+		}
+		if result.Replay {
+			output += saveReplay()
+		}
+		if result.Record != "" {
+			output += record(c.mediaManager, result.Record)
 		}
 		if result.StartCall != "" {
-			c.mediaManager.StartCall(result.StartCall)
-			output += fmt.Sprintf("Calling %s...\n", result.StartCall)
+			if err := c.mediaManager.StartCall(result.StartCall); err != nil {
+				output += i18n.T("call.failed", result.StartCall, err) + "\n"
+			} else {
+				output += i18n.T("call.calling", result.StartCall) + "\n"
+			}
 		}
 		if result.StartShare != "" {
-			c.mediaManager.StartShare(result.StartShare)
-			output += fmt.Sprintf("Sharing screen with %s...\n", result.StartShare)
+			if err := c.mediaManager.StartShare(result.StartShare, 0); err != nil {
+				output += i18n.T("share.failed", result.StartShare, err) + "\n"
+			} else {
+				output += i18n.T("share.sharing", result.StartShare) + "\n"
+			}
 		}
 		return output, nil
 	}
 
 	// Regular message
-	err := SendMessage(c.conn, Message{Type: MsgTypeMsg, Nick: c.nick, Text: text})
-	return "", err
+	text, cut := TruncateMessage(text)
+	if cut {
+		output = truncatedWarning() + "\n"
+	}
+	err := c.post(Message{Type: MsgTypeMsg, Nick: c.nick, Text: text})
+	return output, err
 }
 
 // OfferFile is called by UI when user drags a file or picks one
@@ -237,103 +574,218 @@ func (c *ChatClient) OfferFile(path string, target string) {
 
 // sendFileOffer sends a file offer (not the actual file yet)
 func (c *ChatClient) sendFileOffer(path string, target string) {
-	info, err := os.Stat(path)
+	name, data, err := readForSending(path)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		logger.Errorf("Offering file: %v", err)
 		return
 	}
+	c.OfferBytes(name, data, target)
+}
 
-	if info.Size() > 5*1024*1024 {
-		fmt.Println("File too large (max 5MB)")
+// OfferBytes offers in-memory data, such as a pasted image, as a file named
+// name. The data is kept until the recipient accepts or rejects.
+func (c *ChatClient) OfferBytes(name string, data []byte, target string) {
+	if len(data) > MaxFileSize {
+		logger.Errorf("File too large (max %s)", FormatSize(MaxFileSize))
 		return
 	}
 
-	// Format size for display
-	size := ""
-	if info.Size() < 1024 {
-		size = fmt.Sprintf("%dB", info.Size())
-	} else if info.Size() < 1024*1024 {
-		size = fmt.Sprintf("%.1fKB", float64(info.Size())/1024)
-	} else {
-		size = fmt.Sprintf("%.1fMB", float64(info.Size())/(1024*1024))
-	}
-
-	filename := filepath.Base(path)
+	size := FormatSize(int64(len(data)))
 	msg := Message{
 		Type:   MsgTypeFileOffer,
 		Nick:   c.nick,
-		Text:   filename,
+		Text:   name,
 		Data:   size,
 		Target: target,
 	}
-	SendMessage(c.conn, msg)
-
-	// Track what we offered for when accept comes back
-	c.lastOfferedFile = path
+	// Track what we offered before sending, as the accept can come straight back
+	c.offerMu.Lock()
+	c.lastOfferedFile = name
+	c.lastOfferedData = data
 	c.lastOfferedTo = target
+	c.offerMu.Unlock()
+	SendMessage(c.hostConn(), msg)
 
 	if target != "" {
 		if c.callbacks.OnSystemMessage != nil {
-			c.callbacks.OnSystemMessage(fmt.Sprintf("Offered %s (%s) to %s", filename, size, target))
+			c.callbacks.OnSystemMessage(i18n.T("file.offeredTo", name, size, target))
 		}
 	} else {
 		if c.callbacks.OnSystemMessage != nil {
-			c.callbacks.OnSystemMessage(fmt.Sprintf("Offered %s (%s) to everyone", filename, size))
+			c.callbacks.OnSystemMessage(i18n.T("file.offeredAll", name, size))
 		}
 	}
 }
 
-// sendActualFile reads and sends the actual file data
-func (c *ChatClient) sendActualFile(path string, target string) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if c.callbacks.OnSystemMessage != nil {
-			c.callbacks.OnSystemMessage(fmt.Sprintf("Error reading file: %v", err))
-		}
-		return
-	}
+// takeOffer returns our file offer awaiting an answer, forgetting it
+func (c *ChatClient) takeOffer() (name string, data []byte, ok bool) {
+	c.offerMu.Lock()
+	defer c.offerMu.Unlock()
+	name, data = c.lastOfferedFile, c.lastOfferedData
+	c.lastOfferedFile = ""
+	c.lastOfferedData = nil
+	c.lastOfferedTo = ""
+	return name, data, name != ""
+}
 
-	encoded := base64.StdEncoding.EncodeToString(data)
-	filename := filepath.Base(path)
+// takePendingFile returns the incoming offer awaiting our answer, forgetting
+// it, if it came from "from" or from is empty
+func (c *ChatClient) takePendingFile(from string) *PendingFile {
+	c.offerMu.Lock()
+	defer c.offerMu.Unlock()
+	offer := c.pendingFile
+	if offer == nil || (from != "" && offer.From != from) {
+		return nil
+	}
+	c.pendingFile = nil
+	return offer
+}
 
+// sendActualFile sends the data of an accepted offer. It is sent in the
+// background once a transfer slot is free, so the receive loop carries on
+// while files go out side by side.
+func (c *ChatClient) sendActualFile(filename string, data []byte, target string) {
 	msg := Message{
 		Type:   MsgTypeFile,
 		Nick:   c.nick,
 		Text:   filename,
-		Data:   encoded,
+		Raw:    data,
 		Target: target,
+		Sum:    fileChecksum(data),
 	}
-	SendMessage(c.conn, msg)
-	if c.callbacks.OnSystemMessage != nil {
-		c.callbacks.OnSystemMessage(fmt.Sprintf("File sent (%d bytes)", len(data)))
-	}
+	gzip := c.gzip
+	conn := c.hostConn()
+	go func() {
+		if !c.acquireTransfer() {
+			return
+		}
+		defer c.slots.release()
+		if gzip {
+			msg = compressFile(msg)
+		}
+		if err := SendMessage(conn, msg); err == nil {
+			c.stats.filesSent.Add(1)
+		}
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(i18n.T("file.sent", FormatSize(int64(len(data)))))
+		}
+	}()
 }
 
-// saveFile saves a received file to the current directory
-func saveFile(filename string, data string, from string) {
-	decoded, err := base64.StdEncoding.DecodeString(data)
+// acquireTransfer waits for a free transfer slot, saying so if it has to. It
+// returns false if the client is closed first.
+func (c *ChatClient) acquireTransfer() bool {
+	return c.slots.acquire(c.quit, func() {
+		if c.callbacks.OnSystemMessage != nil {
+			c.callbacks.OnSystemMessage(i18n.T("file.queued"))
+		}
+	})
+}
+
+// decodeFile decodes a received file message's contents and verifies its checksum
+func decodeFile(msg Message) ([]byte, error) {
+	decoded, err := fileBytes(msg)
 	if err != nil {
-		fmt.Printf("Error decoding file: %v\n", err)
-		return
+		return nil, fmt.Errorf("decoding file: %w", err)
 	}
+	if len(decoded) > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max %s)", FormatSize(MaxFileSize))
+	}
+	if err := verifyChecksum(decoded, msg.Sum); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
 
+// SaveFile writes a received file into Settings.DownloadDir and returns its
+// path. With Settings.Unzip a .zip is unpacked into a folder of its name; one
+// that won't unpack is saved as it came instead, so it isn't lost.
+func SaveFile(filename string, data []byte) (string, error) {
 	// Sanitize filename
-	safeName := filepath.Base(filename)
-	err = os.WriteFile(safeName, decoded, 0644)
+	safeName := filepath.Join(Settings.DownloadDir, filepath.Base(filename))
+	if dir, ok := strings.CutSuffix(safeName, ".zip"); ok && Settings.Unzip {
+		_, statErr := os.Stat(dir)
+		err := unzip(data, dir)
+		if err == nil {
+			return dir, nil
+		}
+		logger.Warnf("Unpacking %s, saving the archive instead: %v", filepath.Base(filename), err)
+		if errors.Is(statErr, os.ErrNotExist) {
+			os.RemoveAll(dir) // only what this attempt created
+		}
+	}
+	if err := os.WriteFile(safeName, data, 0644); err != nil {
+		return "", fmt.Errorf("saving file: %w", err)
+	}
+	return safeName, nil
+}
+
+// saveReplay writes the last call's audio into Settings.DownloadDir and
+// describes the outcome for the local output
+func saveReplay() string {
+	data, err := media.ReplayWAV()
 	if err != nil {
-		fmt.Printf("Error saving file: %v\n", err)
-		return
+		return i18n.T("replay.failed", err) + "\n"
+	}
+	path, err := SaveFile(fmt.Sprintf("replay-%s.wav", time.Now().Format("20060102-150405")), data)
+	if err != nil {
+		return i18n.T("replay.failed", err) + "\n"
+	}
+	return i18n.T("replay.saved", path) + "\n"
+}
+
+// record starts or stops recording the current call into Settings.DownloadDir
+// and describes the outcome for the local output
+func record(m *media.MediaManager, action string) string {
+	if action == "stop" {
+		path, err := m.StopRecording()
+		if err != nil {
+			return i18n.T("record.stopFailed", err) + "\n"
+		}
+		return i18n.T("record.saved", path) + "\n"
 	}
 
-	// We'll let the callback handle the notification
+	name := fmt.Sprintf("call-%s.wav", time.Now().Format("20060102-150405"))
+	if err := m.StartRecording(filepath.Join(Settings.DownloadDir, name)); err != nil {
+		return i18n.T("record.failed", err) + "\n"
+	}
+	return i18n.T("record.started") + "\n"
+}
+
+// sendSignal sends WebRTC signaling data to target through the host
+func (c *ChatClient) sendSignal(target string, data string) {
+	msg := Message{
+		Type:   MsgTypeWebRTC,
+		Nick:   c.nick,
+		Text:   "signal",
+		Data:   data,
+		Target: target,
+	}
+	SendMessage(c.hostConn(), msg)
+}
+
+// refuseCall declines a call offer when there is no media manager
+func (c *ChatClient) refuseCall(from string, data string) {
+	reply := media.RefuseSignal(data)
+	if reply == "" {
+		return
+	}
+	c.sendSignal(from, reply)
+	if c.callbacks.OnSystemMessage != nil {
+		c.callbacks.OnSystemMessage(i18n.T("call.missed", from, media.ErrUnavailable))
+	}
 }
 
 // Close disconnects the client
 func (c *ChatClient) Close() {
+	if !c.closed.Swap(true) {
+		close(c.quit)
+	}
 	if c.mediaManager != nil {
 		c.mediaManager.Stop()
 	}
-	if c.conn != nil {
-		c.conn.Close()
+	if conn := c.hostConn(); conn != nil {
+		SendMessage(conn, Message{Type: MsgTypeLeave, Nick: c.nick}) // so the host announces it straight away
+		conn.Close()
 	}
 }
@@ -3,37 +3,125 @@ package core
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"cabinchat/i18n"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// lockedRand is a math/rand generator that is safe to share between the
+// goroutines serving each client.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+// commandRand drives the fun commands. Replace it with a fixed seed to get
+// repeatable rolls.
+var commandRand = newLockedRand(time.Now().UnixNano())
+
+// eightBallAnswers are the message IDs of the classic Magic 8-Ball replies.
+var eightBallAnswers = []string{
+	"eightball.1",
+	"eightball.2",
+	"eightball.3",
+	"eightball.4",
+	"eightball.5",
+	"eightball.6",
+	"eightball.7",
+	"eightball.8",
+	"eightball.9",
+	"eightball.10",
+	"eightball.11",
+	"eightball.12",
+	"eightball.13",
+	"eightball.14",
+	"eightball.15",
+	"eightball.16",
+	"eightball.17",
+	"eightball.18",
+	"eightball.19",
+	"eightball.20",
 }
 
 // CommandResult represents the result of processing a slash command
 type CommandResult struct {
-	Handled      bool
-	Message      *Message // nil if command was local-only (like /help)
-	LocalOutput  string   // Text to print locally
-	ShouldQuit   bool
-	NickChange   string           // New nickname if changing
-	RequestUsers bool             // Request user list from host
-	SendPing     bool             // Send ping to host
-	FileSend     *FileSendRequest // File to send
-	FilePicker   bool             // Show interactive file picker
-	AcceptFile   bool             // Accept pending file transfer
-	RejectFile   bool             // Reject pending file transfer
-	StartCall    string           // Target nick for VOIP call
-	StartShare   string           // Target nick for Screen Share
+	Handled        bool
+	Message        *Message // nil if command was local-only (like /help)
+	LocalOutput    string   // Text to print locally
+	ShouldQuit     bool
+	NickChange     string           // New nickname if changing
+	RequestUsers   bool             // Request user list from host
+	SendPing       bool             // Send ping to host
+	Stats          bool             // Show room statistics
+	FileSend       *FileSendRequest // File to send
+	FilePicker     bool             // Show interactive file picker
+	AcceptFile     bool             // Accept pending file transfer
+	RejectFile     bool             // Reject pending file transfer
+	FileFrom       string           // Sender whose offer to accept/reject; empty = oldest
+	Transfers      bool             // List file offers waiting on an answer
+	CancelTransfer int              // Withdraw or decline this offer from the /transfers list, 1-based
+	StartCall      string           // Target nick for VOIP call
+	StartShare     string           // Target nick for Screen Share
+	PrivateTo      string           // Nick to send PrivateText to
+	PrivateText    string           // Private message text
+	Reply          string           // Private reply to whoever last messaged us privately
+	Whois          string           // Nick to look up connection info for (host only)
+	Invite         string           // Nick or machine name of an idle instance to invite
+	Away           bool             // Mark yourself away with AwayText
+	AwayText       string           // Away message
+	Back           bool             // Clear the away status
+	StartPoll      *Poll            // Poll to open (host only)
+	ClosePoll      bool             // Close the open poll (host only)
+	SetTopic       bool             // Set the room topic to Topic (host only)
+	Topic          string           // New topic, "" clears it
+	Motd           string           // New welcome message for joiners, "-" clears it (host only)
+	ShowMotd       bool             // Show the welcome message (host only)
+	Handoff        string           // Nick to hand the room over to (host only)
+	Ban            string           // Nick to disconnect and ban by address (host only)
+	BanList        bool             // List bans (host only)
+	KickAll        bool             // Disconnect every client, keeping the room open (host only)
+	Unban          string           // IP or nick to lift a ban for (host only)
+	Alias          *Alias           // Local alias to set, or clear when it has no Name or Color
+	AliasList      bool             // List local aliases
+	SaveDir        string           // Directory to save received files to, "-" = none
+	ShowSaveDir    bool             // Show where received files are saved
+	Vote           int              // Option number to vote for, 1-based
+	React          string           // Emoji to react to the latest message with
+	Edit           string           // New text for your latest message
+	Delete         bool             // Delete your latest message
+	ClearScreen    bool             // Clear the local chat history
+	Export         bool             // Save the chat history to ExportPath
+	ExportPath     string           // "" = let the UI choose
+	Replay         bool             // Save the last call's audio to a WAV file
+	Record         string           // "start" or "stop" recording the current call
 }
 
+// clearScreenANSI clears a terminal, used for /clear when the UI has no OnClear
+const clearScreenANSI = "\033[2J\033[H"
+
 // FileSendRequest holds file transfer info
 type FileSendRequest struct {
 	Path   string
 	Target string // empty = broadcast to all
 }
 
+// maxNickLen is the longest nickname /nick accepts
+const maxNickLen = 20
+
 // ProcessCommand handles slash commands, returns true if handled
 func ProcessCommand(input string, nick string) CommandResult {
 	if !strings.HasPrefix(input, "/") {
@@ -56,7 +144,7 @@ func ProcessCommand(input string, nick string) CommandResult {
 
 	case "/me":
 		if args == "" {
-			return CommandResult{Handled: true, LocalOutput: "Usage: /me <action>"}
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.me")}
 		}
 		return CommandResult{
 			Handled: true,
@@ -66,9 +154,9 @@ func ProcessCommand(input string, nick string) CommandResult {
 	case "/slap":
 		target := args
 		if target == "" {
-			target = "themselves"
+			target = i18n.T("fun.slapSelf")
 		}
-		text := fmt.Sprintf("%s slaps %s around a bit with a large trout 🐟", nick, target)
+		text := i18n.T("fun.slap", nick, target)
 		return CommandResult{
 			Handled: true,
 			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: text},
@@ -102,24 +190,46 @@ func ProcessCommand(input string, nick string) CommandResult {
 		}
 		return CommandResult{
 			Handled: true,
-			Message: &Message{Type: MsgTypeMsg, Nick: nick, Text: rages[rand.Intn(len(rages))]},
+			Message: &Message{Type: MsgTypeMsg, Nick: nick, Text: rages[commandRand.Intn(len(rages))]},
 		}
 
 	case "/dice", "/roll":
-		n := rand.Intn(6) + 1
+		n := commandRand.Intn(6) + 1
 		return CommandResult{
 			Handled: true,
-			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: fmt.Sprintf("%s rolls a dice and gets %d", nick, n)},
+			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: i18n.T("fun.dice", nick, n)},
 		}
 
 	case "/coin", "/flip-coin":
-		result := "heads"
-		if rand.Intn(2) == 1 {
-			result = "tails"
+		result := i18n.T("fun.heads")
+		if commandRand.Intn(2) == 1 {
+			result = i18n.T("fun.tails")
 		}
 		return CommandResult{
 			Handled: true,
-			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: fmt.Sprintf("%s flips a coin: %s!", nick, result)},
+			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: i18n.T("fun.coin", nick, result)},
+		}
+
+	case "/8ball", "/eightball":
+		if args == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.eightball")}
+		}
+		answer := i18n.T(eightBallAnswers[commandRand.Intn(len(eightBallAnswers))])
+		return CommandResult{
+			Handled: true,
+			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: i18n.T("fun.eightBall", nick, args, answer)},
+		}
+
+	case "/ascii":
+		if args == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.ascii")}
+		}
+		if utf8.RuneCountInString(args) > maxBannerLen {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("ascii.tooLong", maxBannerLen) + "\n"}
+		}
+		return CommandResult{
+			Handled: true,
+			Message: &Message{Type: MsgTypeMsg, Nick: nick, Text: renderBanner(args)},
 		}
 
 	case "/lenny":
@@ -137,25 +247,25 @@ func ProcessCommand(input string, nick string) CommandResult {
 	case "/fight":
 		target := args
 		if target == "" {
-			target = "the air"
+			target = i18n.T("fun.fightAir")
 		}
 		moves := []string{
-			fmt.Sprintf("%s throws a punch at %s!", nick, target),
-			fmt.Sprintf("%s challenges %s to mortal combat!", nick, target),
-			fmt.Sprintf("%s summons a mass of wild ferrets to attack %s!", nick, target),
+			i18n.T("fun.fightPunch", nick, target),
+			i18n.T("fun.fightCombat", nick, target),
+			i18n.T("fun.fightFerrets", nick, target),
 		}
 		return CommandResult{
 			Handled: true,
-			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: moves[rand.Intn(len(moves))]},
+			Message: &Message{Type: MsgTypeMsg, Nick: "*", Text: moves[commandRand.Intn(len(moves))]},
 		}
 
 	case "/nick":
 		if args == "" {
-			return CommandResult{Handled: true, LocalOutput: "Usage: /nick <newnickname>"}
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.nick")}
 		}
 		newNick := strings.TrimSpace(args)
-		if len(newNick) > 20 {
-			return CommandResult{Handled: true, LocalOutput: "Nickname too long (max 20 chars)"}
+		if len(newNick) > maxNickLen {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("nick.tooLong", maxNickLen)}
 		}
 		return CommandResult{
 			Handled:    true,
@@ -168,20 +278,182 @@ func ProcessCommand(input string, nick string) CommandResult {
 			RequestUsers: true,
 		}
 
+	case "/msg":
+		parts := strings.SplitN(strings.TrimSpace(args), " ", 2)
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.msg")}
+		}
+		return CommandResult{
+			Handled:     true,
+			PrivateTo:   parts[0],
+			PrivateText: strings.TrimSpace(parts[1]),
+		}
+
+	case "/r", "/reply":
+		if strings.TrimSpace(args) == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.r")}
+		}
+		return CommandResult{
+			Handled: true,
+			Reply:   strings.TrimSpace(args),
+		}
+
+	case "/whois":
+		if args == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.whois")}
+		}
+		return CommandResult{
+			Handled: true,
+			Whois:   strings.TrimSpace(args),
+		}
+
+	case "/afk", "/away":
+		text := strings.TrimSpace(args)
+		if text == "" {
+			text = i18n.T("away.default")
+		}
+		return CommandResult{Handled: true, Away: true, AwayText: text}
+
+	case "/back":
+		return CommandResult{Handled: true, Back: true}
+
+	case "/invite":
+		if args == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.invite")}
+		}
+		return CommandResult{
+			Handled: true,
+			Invite:  strings.TrimSpace(args),
+		}
+
+	case "/topic":
+		return CommandResult{
+			Handled:  true,
+			SetTopic: true,
+			Topic:    strings.TrimSpace(args),
+		}
+
+	case "/motd":
+		if text := strings.TrimSpace(args); text != "" {
+			return CommandResult{Handled: true, Motd: text}
+		}
+		return CommandResult{Handled: true, ShowMotd: true}
+
+	case "/host":
+		nick := strings.TrimSpace(args)
+		if nick == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.host")}
+		}
+		return CommandResult{Handled: true, Handoff: nick}
+
+	case "/ban":
+		nick := strings.TrimSpace(args)
+		if nick == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.ban")}
+		}
+		return CommandResult{Handled: true, Ban: nick}
+
+	case "/alias":
+		if strings.TrimSpace(args) == "" {
+			return CommandResult{Handled: true, AliasList: true}
+		}
+		alias, err := parseAlias(args)
+		if err != nil {
+			return CommandResult{Handled: true, LocalOutput: fmt.Sprintf("%v\n", err)}
+		}
+		return CommandResult{Handled: true, Alias: &alias}
+
+	case "/savedir":
+		if dir := strings.TrimSpace(args); dir != "" {
+			return CommandResult{Handled: true, SaveDir: dir}
+		}
+		return CommandResult{Handled: true, ShowSaveDir: true}
+
+	case "/kickall":
+		return CommandResult{Handled: true, KickAll: true}
+
+	case "/banlist":
+		return CommandResult{Handled: true, BanList: true}
+
+	case "/unban":
+		who := strings.TrimSpace(args)
+		if who == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.unban")}
+		}
+		return CommandResult{Handled: true, Unban: who}
+
+	case "/poll":
+		if strings.TrimSpace(args) == "close" {
+			return CommandResult{Handled: true, ClosePoll: true}
+		}
+		poll, ok := parsePoll(args)
+		if !ok {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.poll")}
+		}
+		return CommandResult{Handled: true, StartPoll: poll}
+
+	case "/vote":
+		n, err := strconv.Atoi(strings.TrimSpace(args))
+		if err != nil || n < 1 {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.vote")}
+		}
+		return CommandResult{Handled: true, Vote: n}
+
+	case "/react":
+		emoji := strings.TrimSpace(args)
+		if emoji == "" {
+			emoji = "👍"
+		}
+		return CommandResult{Handled: true, React: emoji}
+
+	case "/edit":
+		text := strings.TrimSpace(args)
+		if text == "" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.edit")}
+		}
+		return CommandResult{Handled: true, Edit: text}
+
+	case "/delete":
+		return CommandResult{Handled: true, Delete: true}
+
 	case "/time":
 		now := time.Now().Format("Mon Jan 2 15:04:05 2006")
 		return CommandResult{
 			Handled:     true,
-			LocalOutput: fmt.Sprintf("Current time: %s", now),
+			LocalOutput: i18n.T("cmd.time", now),
+		}
+
+	case "/record":
+		if args != "start" && args != "stop" {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.record") + "\n"}
+		}
+		return CommandResult{
+			Handled: true,
+			Record:  args,
+		}
+
+	case "/replay":
+		return CommandResult{
+			Handled: true,
+			Replay:  true,
+		}
+
+	case "/export":
+		return CommandResult{
+			Handled:    true,
+			Export:     true,
+			ExportPath: strings.TrimSpace(args),
 		}
 
 	case "/clear", "/cls":
-		// ANSI escape to clear screen
 		return CommandResult{
 			Handled:     true,
-			LocalOutput: "\033[2J\033[H",
+			ClearScreen: true,
 		}
 
+	case "/stats":
+		return CommandResult{Handled: true, Stats: true}
+
 	case "/ping":
 		return CommandResult{
 			Handled:  true,
@@ -191,7 +463,7 @@ func ProcessCommand(input string, nick string) CommandResult {
 	case "/send":
 		// Usage: /send <file> [nick] or /send @ for picker
 		if args == "" {
-			return CommandResult{Handled: true, LocalOutput: "Usage: /send <filepath> [nick] or /send @ to pick\n"}
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.send") + "\n"}
 		}
 		if args == "@" {
 			return CommandResult{
@@ -214,18 +486,30 @@ func ProcessCommand(input string, nick string) CommandResult {
 		return CommandResult{
 			Handled:    true,
 			AcceptFile: true,
+			FileFrom:   args,
 		}
 
 	case "/reject", "/n", "/no", "/decline":
 		return CommandResult{
 			Handled:    true,
 			RejectFile: true,
+			FileFrom:   args,
+		}
+
+	case "/transfers":
+		return CommandResult{Handled: true, Transfers: true}
+
+	case "/cancel":
+		n, err := strconv.Atoi(strings.TrimSpace(args))
+		if err != nil || n < 1 {
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.cancel")}
 		}
+		return CommandResult{Handled: true, CancelTransfer: n}
 
 	case "/call":
 		// Usage: /call <nick>
 		if args == "" {
-			return CommandResult{Handled: true, LocalOutput: "Usage: /call <nick>\n"}
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.call") + "\n"}
 		}
 		return CommandResult{
 			Handled:   true,
@@ -235,7 +519,7 @@ func ProcessCommand(input string, nick string) CommandResult {
 	case "/share":
 		// Usage: /share <nick>
 		if args == "" {
-			return CommandResult{Handled: true, LocalOutput: "Usage: /share <nick>\n"}
+			return CommandResult{Handled: true, LocalOutput: i18n.T("usage.share") + "\n"}
 		}
 		return CommandResult{
 			Handled:    true,
@@ -245,58 +529,40 @@ func ProcessCommand(input string, nick string) CommandResult {
 	case "/quit", "/exit", "/q":
 		return CommandResult{
 			Handled:     true,
-			LocalOutput: "Leaving...\n",
+			LocalOutput: i18n.T("cmd.leaving") + "\n",
 			ShouldQuit:  true,
 		}
 
 	default:
 		return CommandResult{
 			Handled:     true,
-			LocalOutput: fmt.Sprintf("Unknown command: %s (try /help)", cmd),
+			LocalOutput: i18n.T("cmd.unknown", cmd),
 		}
 	}
 }
 
+// IsQuitCommand reports whether input is one of the commands that leave the room
+func IsQuitCommand(input string) bool {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "/quit", "/exit", "/q":
+		return true
+	}
+	return false
+}
+
 func helpText() string {
-	return `
-+------------------------------------------+
-|           CabinChat Commands             |
-+------------------------------------------+
-| UTILITY                                  |
-|   /nick <name>    Change your nickname   |
-|   /users          List online users      |
-|   /send <file>    Send a file            |
-|   /send @         Pick from list         |
-|   /accept         Accept file transfer   |
-|   /reject         Reject file transfer   |
-|   /call <nick>    Call a user           |
-|   /share <nick>   Share screen          |
-|   /ping           Check connection       |
-|   /time           Show current time      |
-|   /clear          Clear screen           |
-|   /quit           Leave the room         |
-+------------------------------------------+
-| FUN                                      |
-|   /me <action>    Action message         |
-|   /slap <user>    Classic IRC slap       |
-|   /shrug          Shrug emoticon         |
-|   /flip           Flip a table           |
-|   /unflip         Put it back            |
-|   /rage           Express yourself       |
-|   /dice           Roll a d6              |
-|   /coin           Flip a coin            |
-|   /lenny          Lenny face             |
-|   /disapprove     Look of disapproval    |
-|   /fight <who>    Start a fight          |
-+------------------------------------------+
-`
+	return i18n.T("cmd.help")
 }
 
 func randomSmash() string {
 	chars := "ASDFJKL;QWERTY!@#$%^&*"
 	result := make([]byte, 15)
 	for i := range result {
-		result[i] = chars[rand.Intn(len(chars))]
+		result[i] = chars[commandRand.Intn(len(chars))]
 	}
 	return string(result)
 }
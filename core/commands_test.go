@@ -0,0 +1,75 @@
+package core
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"cabinchat/i18n"
+)
+
+// withSeed makes the fun commands repeatable for the rest of the test
+func withSeed(t *testing.T, seed int64) {
+	t.Helper()
+	old := commandRand
+	commandRand = newLockedRand(seed)
+	t.Cleanup(func() { commandRand = old })
+}
+
+// eightBall asks /8ball a question and returns the answer it broadcasts
+func eightBall(t *testing.T, question string) string {
+	t.Helper()
+	result := ProcessCommand("/8ball "+question, "alice")
+	if result.Message == nil {
+		t.Fatalf("/8ball %s sent nothing", question)
+	}
+	prefix := i18n.T("fun.eightBall", "alice", question, "")
+	if !strings.HasPrefix(result.Message.Text, prefix) {
+		t.Fatalf("/8ball said %q", result.Message.Text)
+	}
+	return strings.TrimPrefix(result.Message.Text, prefix)
+}
+
+func TestEightBallIsRepeatableWithASeed(t *testing.T) {
+	withSeed(t, 42)
+	var first []string
+	for range 5 {
+		answer := eightBall(t, "will it snow?")
+		if !slices.ContainsFunc(eightBallAnswers, func(id string) bool { return i18n.T(id) == answer }) {
+			t.Errorf("%q isn't an 8-ball answer", answer)
+		}
+		first = append(first, answer)
+	}
+
+	commandRand = newLockedRand(42)
+	for i, want := range first {
+		if got := eightBall(t, "will it snow?"); got != want {
+			t.Errorf("answer %d with the same seed = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestEightBallNeedsAQuestion(t *testing.T) {
+	result := ProcessCommand("/8ball", "alice")
+	if result.Message != nil || !strings.HasPrefix(result.LocalOutput, "Usage:") {
+		t.Errorf("/8ball without a question = %+v, want usage", result)
+	}
+}
+
+func TestCommandRandIsSharedSafely(t *testing.T) {
+	withSeed(t, 1)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if n := commandRand.Intn(6); n < 0 || n >= 6 {
+					t.Errorf("Intn(6) = %d", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// rejoinWindow is how long the "left" notice of a dropped client is held
+// back. Rejoining within it suppresses both notices, so flaky WiFi doesn't
+// fill the transcript with leave/join pairs.
+const rejoinWindow = 10 * time.Second
+
+// announceLeave tells the host and the room that nick left
+func (h *Host) announceLeave(nick string) {
+	sysMsg := i18n.T("room.left", nick)
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(sysMsg)
+	}
+	if h.callbacks.OnPresence != nil {
+		h.callbacks.OnPresence(nick, false)
+	}
+	h.broadcast(Message{Type: MsgTypeSystem, Nick: nick, Text: sysMsg, Data: PresenceLeft}, nil)
+	h.broadcast(Message{Type: MsgTypeUserLeft, Nick: nick}, nil)
+	h.notifyWebhook(WebhookLeave, nick, "", "")
+}
+
+// deferLeave announces a dropped client's departure after rejoinWindow,
+// unless rejoined cancels it first
+func (h *Host) deferLeave(nick string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if pending := h.departures[nick]; pending != nil {
+		pending.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(rejoinWindow, func() {
+		h.mutex.Lock()
+		mine := h.departures[nick] == timer
+		if mine {
+			delete(h.departures, nick)
+		}
+		h.mutex.Unlock()
+		// Unless nick rejoined, or dropped again, while this was firing
+		if mine && h.ctx.Err() == nil {
+			h.announceLeave(nick)
+		}
+	})
+	h.departures[nick] = timer
+}
+
+// rejoined reports whether nick dropped recently enough that its "left"
+// notice is still pending, cancelling the notice. Must be called with
+// h.mutex held, together with registering the rejoining client, so the
+// notice either goes out before the client is back or not at all.
+func (h *Host) rejoined(nick string) bool {
+	timer := h.departures[nick]
+	if timer == nil {
+		return false
+	}
+	delete(h.departures, nick)
+	timer.Stop()
+	return true // a firing timer finds its entry gone and stays quiet
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+// presence is an arrival or departure as handed to OnPresence
+type presence struct {
+	nick   string
+	joined bool
+}
+
+func TestPresenceAnnounced(t *testing.T) {
+	hostSeen := make(chan presence, 10)
+	h, transport := startTestRoom(t, "host", HostCallbacks{
+		OnPresence: func(nick string, joined bool) { hostSeen <- presence{nick, joined} },
+	})
+	aliceSeen := make(chan presence, 10)
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{
+		OnPresence: func(nick string, joined bool) { aliceSeen <- presence{nick, joined} },
+	})
+	if got := receive(t, hostSeen); got != (presence{"alice", true}) {
+		t.Errorf("host saw %+v, want alice joining", got)
+	}
+
+	bob := joinTestRoom(t, transport, h, "bob", ClientCallbacks{})
+	if got := receive(t, aliceSeen); got != (presence{"bob", true}) {
+		t.Errorf("alice saw %+v, want bob joining", got)
+	}
+	receive(t, hostSeen)
+
+	bob.Close()
+	if got := receive(t, aliceSeen); got != (presence{"bob", false}) {
+		t.Errorf("alice saw %+v, want bob leaving", got)
+	}
+	if got := receive(t, hostSeen); got != (presence{"bob", false}) {
+		t.Errorf("host saw %+v, want bob leaving", got)
+	}
+}
+
+func TestQuickReconnectIsNotAnnounced(t *testing.T) {
+	hostUsers, onHostUsers := collect[[]string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnUserList: onHostUsers})
+	aliceSeen := make(chan presence, 10)
+	aliceMsgs, onAliceMsg := collect[Message]()
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{
+		OnPresence:        func(nick string, joined bool) { aliceSeen <- presence{nick, joined} },
+		OnMessageReceived: onAliceMsg,
+		OnUserList:        onUsers,
+	})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+	bob := joinTestRoom(t, transport, h, "bob", ClientCallbacks{})
+	receive(t, aliceSeen)
+	receiveUntil(t, hostUsers, func(users []string) bool { return len(users) == 3 })
+
+	bob.conn.Close() // dropped, without saying goodbye
+	eventually(t, "the host holds back bob's departure", func() bool {
+		h.mutex.RLock()
+		defer h.mutex.RUnlock()
+		return h.departures["bob"] != nil
+	})
+	receiveUntil(t, hostUsers, func(users []string) bool { return !slices.Contains(users, "bob") })
+	joinTestRoom(t, transport, h, "bob", ClientCallbacks{})
+	receiveUntil(t, hostUsers, func(users []string) bool { return slices.Contains(users, "bob") })
+	h.mutex.RLock()
+	pending := len(h.departures)
+	h.mutex.RUnlock()
+	if pending != 0 {
+		t.Errorf("%d departures still pending after bob came back", pending)
+	}
+
+	h.SendText("still here?")
+	receive(t, aliceMsgs)
+	select {
+	case got := <-aliceSeen:
+		t.Errorf("alice saw %+v, want bob's reconnect to pass quietly", got)
+	default:
+	}
+}
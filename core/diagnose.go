@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+
+	"cabinchat/i18n"
+)
+
+// diagnosticService is a throwaway mDNS service type, so the self-check
+// never shows up as a room in anyone's discovery
+const diagnosticService = "_cabinchat-diag._tcp"
+
+// Diagnostics reports whether hosting is likely to work on this network
+type Diagnostics struct {
+	LocalIP   string // "unknown" without a route off this machine
+	Port      int
+	BindErr   error // binding Settings.Port; ErrPortInUse if a room may already be running
+	DialErr   error // connecting to the port via LocalIP, as a client on the LAN would
+	MDNSErr   error // registering an mDNS service
+	BrowseErr error // seeing that registration by browsing, as discovery does
+}
+
+// RunDiagnostics checks the port, mDNS and LAN address hosting relies on.
+// It takes a few seconds, mostly waiting for mDNS.
+func RunDiagnostics() Diagnostics {
+	d := Diagnostics{LocalIP: getLocalIP(), Port: Settings.Port}
+
+	// The port may already be held by our own room, so a busy port is still
+	// worth dialing: whatever answers is what clients would reach
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", d.Port))
+	if err != nil {
+		d.BindErr = err
+		if isAddrInUse(err) {
+			d.BindErr = ErrPortInUse
+		}
+	} else {
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+	}
+
+	if d.LocalIP == "unknown" {
+		d.DialErr = errors.New("no LAN address found")
+	} else if conn, err := net.DialTimeout("tcp", net.JoinHostPort(d.LocalIP, strconv.Itoa(d.Port)), 2*time.Second); err != nil {
+		d.DialErr = err
+	} else {
+		conn.Close()
+	}
+
+	d.MDNSErr, d.BrowseErr = checkMDNS()
+	return d
+}
+
+// checkMDNS registers a test service and browses for it
+func checkMDNS() (registerErr error, browseErr error) {
+	instance := fmt.Sprintf("diag-%d", os.Getpid())
+	server, err := zeroconf.Register(instance, diagnosticService, Domain, Settings.Port, nil, nil)
+	if err != nil {
+		return err, errors.New("skipped")
+	}
+	defer server.Shutdown()
+
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		for entry := range entries {
+			if entry.Instance == instance {
+				close(found)
+				cancel()
+				for range entries {
+				}
+				return
+			}
+		}
+	}()
+	if err := resolver.Browse(ctx, diagnosticService, Domain, entries); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-found:
+		return nil, nil
+	case <-ctx.Done():
+		select {
+		case <-found:
+			return nil, nil
+		default:
+			return nil, errors.New("own advertisement not seen; multicast may be blocked")
+		}
+	}
+}
+
+// String formats the results as a checklist, for the UI or a bug report
+func (d Diagnostics) String() string {
+	var b strings.Builder
+	line := func(name string, err error, ok string) {
+		if err != nil {
+			fmt.Fprintf(&b, "✗ %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&b, "✓ %s: %s\n", name, ok)
+		}
+	}
+	fmt.Fprintln(&b, i18n.T("diagnose.lanAddress", d.LocalIP))
+	if errors.Is(d.BindErr, ErrPortInUse) {
+		fmt.Fprintln(&b, i18n.T("diagnose.portInUse", d.Port))
+	} else {
+		line(i18n.T("diagnose.port", d.Port), d.BindErr, i18n.T("diagnose.free"))
+	}
+	line(i18n.T("diagnose.reachable"), d.DialErr, i18n.T("diagnose.yes"))
+	line(i18n.T("diagnose.advertising"), d.MDNSErr, i18n.T("diagnose.ok"))
+	line(i18n.T("diagnose.discovery"), d.BrowseErr, i18n.T("diagnose.ok"))
+	return b.String()
+}
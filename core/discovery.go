@@ -2,39 +2,187 @@ package core
 
 import (
 	"context"
-	"fmt"
 	"net"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/grandcat/zeroconf"
+
+	"cabinchat/logger"
 )
 
 const (
-	ServiceName = "_cabinchat._tcp"
-	Domain      = "local."
+	ServiceName     = "_cabinchat._tcp"
+	PresenceService = "_cabinchat-idle._tcp" // instances outside a room that accept invites
+	Domain          = "local."
 )
 
 // DiscoveredRoom represents a found chatroom
 type DiscoveredRoom struct {
-	Host string
-	Port int
+	Name        string // mDNS instance name, empty if the room was entered by address
+	Host        string
+	Port        int
+	TLS         bool   // host only accepts TLS connections
+	Fingerprint string // pinned TLS certificate fingerprint, from a join link
+
+	LastSeen time.Time // when a scan last found the room, set by RoomRegistry
+	Stale    bool      // missing from the latest scan but not yet dropped
+}
+
+// Address returns the room's host:port
+func (r DiscoveredRoom) Address() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
 }
 
-// FindRooms searches for rooms on the network
-// FindRooms searches for rooms on the network
+// tlsRecord is the mDNS TXT record flagging a room that requires TLS
+const tlsRecord = "tls=1"
+
+// FindRooms searches for rooms on the network, other than those this
+// process hosts
 func FindRooms(port int) []DiscoveredRoom {
 	rooms, err := discoverMDNS()
 	if err == nil {
-		return rooms
+		return dropOwnRooms(dropStaleRooms(rooms))
 	}
 	return []DiscoveredRoom{}
 }
 
+// roomMissLimit is how many scans in a row a room may be missing before
+// RoomRegistry drops it
+const roomMissLimit = 3
+
+// RoomRegistry merges successive FindRooms results into a stable list, so a
+// room that misses one scan is marked stale instead of vanishing
+type RoomRegistry struct {
+	mu     sync.Mutex
+	rooms  map[string]DiscoveredRoom
+	misses map[string]int
+}
+
+// NewRoomRegistry creates an empty registry
+func NewRoomRegistry() *RoomRegistry {
+	return &RoomRegistry{
+		rooms:  make(map[string]DiscoveredRoom),
+		misses: make(map[string]int),
+	}
+}
+
+// roomKey identifies a room across scans by its instance name
+func roomKey(room DiscoveredRoom) string {
+	if room.Name != "" {
+		return room.Name
+	}
+	return room.Address()
+}
+
+// Merge records the rooms found by a scan and returns the updated list.
+// Rooms missing from the scan are marked stale, and dropped once they have
+// missed roomMissLimit scans in a row.
+func (r *RoomRegistry) Merge(found []DiscoveredRoom) []DiscoveredRoom {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, room := range found {
+		key := roomKey(room)
+		room.LastSeen = now
+		room.Stale = false
+		r.rooms[key] = room
+		r.misses[key] = 0
+		seen[key] = true
+	}
+	for key, room := range r.rooms {
+		if seen[key] {
+			continue
+		}
+		r.misses[key]++
+		if r.misses[key] >= roomMissLimit {
+			delete(r.rooms, key)
+			delete(r.misses, key)
+			continue
+		}
+		room.Stale = true
+		r.rooms[key] = room
+	}
+	return r.list()
+}
+
+// Rooms returns the known rooms, sorted by name
+func (r *RoomRegistry) Rooms() []DiscoveredRoom {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.list()
+}
+
+// list snapshots the rooms in a stable order; r.mu must be held
+func (r *RoomRegistry) list() []DiscoveredRoom {
+	rooms := make([]DiscoveredRoom, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		rooms = append(rooms, room)
+	}
+	slices.SortFunc(rooms, func(a, b DiscoveredRoom) int {
+		return strings.Compare(roomKey(a), roomKey(b))
+	})
+	return rooms
+}
+
+// FindRoomByName searches for a room advertised under the given mDNS instance name
+func FindRoomByName(name string) (*DiscoveredRoom, error) {
+	rooms, err := discoverMDNS()
+	if err != nil {
+		return nil, err
+	}
+	for _, room := range rooms {
+		if room.Name == name && probeRoom(room) {
+			return &room, nil
+		}
+	}
+	return nil, nil
+}
+
+// dropStaleRooms removes duplicate advertisements. When a host restarts quickly
+// the previous instance's record can linger in resolver caches under the same
+// name, so rooms advertised more than once keep only the addresses that answer.
+func dropStaleRooms(rooms []DiscoveredRoom) []DiscoveredRoom {
+	seen := make(map[DiscoveredRoom]bool)
+	count := make(map[string]int)
+	var unique []DiscoveredRoom
+	for _, room := range rooms {
+		if !seen[room] {
+			seen[room] = true
+			count[room.Name]++
+			unique = append(unique, room)
+		}
+	}
+
+	result := []DiscoveredRoom{}
+	for _, room := range unique {
+		if count[room.Name] > 1 && !probeRoom(room) {
+			continue
+		}
+		result = append(result, room)
+	}
+	return result
+}
+
+// probeRoom reports whether the room's address accepts connections
+func probeRoom(room DiscoveredRoom) bool {
+	conn, err := net.DialTimeout("tcp", room.Address(), 300*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
 // DiscoverRoom looks for an existing CabinChat room on the network
 func DiscoverRoom() (*DiscoveredRoom, error) {
-	fmt.Println("🔍 Searching for nearby rooms...")
+	logger.Infof("Searching for nearby rooms...")
 
 	// Try mDNS first
 	rooms, err := discoverMDNS()
@@ -54,29 +202,43 @@ func discoverMDNS() ([]DiscoveredRoom, error) {
 	}
 
 	entries := make(chan *zeroconf.ServiceEntry)
-	var foundRooms []DiscoveredRoom
+	found := collectEntries(entries, func(entry *zeroconf.ServiceEntry) (DiscoveredRoom, bool) {
+		if len(entry.AddrIPv4) == 0 {
+			return DiscoveredRoom{}, false
+		}
+		return DiscoveredRoom{
+			Name: entry.Instance,
+			Host: entry.AddrIPv4[0].String(),
+			Port: entry.Port,
+			TLS:  slices.Contains(entry.Text, tlsRecord),
+		}, true
+	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second) // reduced timeout for snappier loops
 	defer cancel()
 
-	go func() {
-		for entry := range entries {
-			if len(entry.AddrIPv4) > 0 {
-				foundRooms = append(foundRooms, DiscoveredRoom{
-					Host: entry.AddrIPv4[0].String(),
-					Port: entry.Port,
-				})
-			}
-		}
-	}()
-
 	err = resolver.Browse(ctx, ServiceName, Domain, entries)
 	if err != nil {
 		return nil, err
 	}
+	return <-found, nil
+}
 
-	<-ctx.Done()
-	return foundRooms, nil
+// collectEntries converts the entries of a browse as they arrive, skipping
+// those convert rejects. The resolver closes entries when the browse times
+// out, and only then is the result sent, so it is never read half-built.
+func collectEntries[T any](entries <-chan *zeroconf.ServiceEntry, convert func(*zeroconf.ServiceEntry) (T, bool)) <-chan []T {
+	result := make(chan []T, 1)
+	go func() {
+		var found []T
+		for entry := range entries {
+			if v, ok := convert(entry); ok {
+				found = append(found, v)
+			}
+		}
+		result <- found
+	}()
+	return result
 }
 
 // discoverFallback scans local subnet for the chat port (Windows fallback)
@@ -99,7 +261,7 @@ func discoverFallback() (*DiscoveredRoom, error) {
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			addr := fmt.Sprintf("%s:%d", ip, Settings.Port)
+			addr := net.JoinHostPort(ip, strconv.Itoa(Settings.Port))
 			conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
 			if err == nil {
 				conn.Close()
@@ -128,16 +290,91 @@ func discoverFallback() (*DiscoveredRoom, error) {
 	}
 }
 
-// StartMDNSAdvertisement advertises the room via mDNS
-func StartMDNSAdvertisement() (*zeroconf.Server, error) {
-	hostname, _ := os.Hostname()
+// Name returns the name the host's room is advertised under
+func (h *Host) Name() string {
+	if h.config.Name != "" {
+		return h.config.Name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// StartMDNSAdvertisement advertises a room on port via mDNS under name
+func StartMDNSAdvertisement(name string, port int) (*zeroconf.Server, error) {
+	txt := []string{"CabinChat room"}
+	if Settings.TLS {
+		txt = append(txt, tlsRecord)
+	}
 	server, err := zeroconf.Register(
-		hostname,
+		name,
 		ServiceName,
 		Domain,
-		Settings.Port,
-		[]string{"CabinChat room"},
+		port,
+		txt,
 		nil,
 	)
 	return server, err
 }
+
+// IdlePeer is a CabinChat instance outside any room, advertising that it
+// accepts invites
+type IdlePeer struct {
+	Nick     string // mDNS instance name
+	Hostname string // machine name, from the TXT record
+	Host     string
+	Port     int // invite listener
+}
+
+// Address returns the peer's invite listener host:port
+func (p IdlePeer) Address() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// hostRecordPrefix starts the presence TXT record carrying the machine name
+const hostRecordPrefix = "host="
+
+// FindIdlePeers browses for instances advertising presence
+func FindIdlePeers() ([]IdlePeer, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := collectEntries(entries, idlePeer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := resolver.Browse(ctx, PresenceService, Domain, entries); err != nil {
+		return nil, err
+	}
+	return <-found, nil
+}
+
+// idlePeer reads an idle instance's presence entry
+func idlePeer(entry *zeroconf.ServiceEntry) (IdlePeer, bool) {
+	if len(entry.AddrIPv4) == 0 {
+		return IdlePeer{}, false
+	}
+	peer := IdlePeer{Nick: entry.Instance, Host: entry.AddrIPv4[0].String(), Port: entry.Port}
+	for _, record := range entry.Text {
+		if name, ok := strings.CutPrefix(record, hostRecordPrefix); ok {
+			peer.Hostname = name
+		}
+	}
+	return peer, true
+}
+
+// advertisePresence announces an idle instance under nick, with its invite listener's port
+func advertisePresence(nick string, port int) (*zeroconf.Server, error) {
+	hostname, _ := os.Hostname()
+	return zeroconf.Register(
+		nick,
+		PresenceService,
+		Domain,
+		port,
+		[]string{hostRecordPrefix + hostname},
+		nil,
+	)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/grandcat/zeroconf"
+)
+
+func TestCollectIdlePeers(t *testing.T) {
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := collectEntries(entries, idlePeer)
+
+	alice := zeroconf.NewServiceEntry("alice", PresenceService, Domain)
+	alice.AddrIPv4 = []net.IP{net.ParseIP("192.168.1.5")}
+	alice.Port = 4000
+	alice.Text = []string{hostRecordPrefix + "laptop"}
+	noAddress := zeroconf.NewServiceEntry("bob", PresenceService, Domain)
+
+	// As the resolver does: send from its own goroutine, close on timeout
+	go func() {
+		entries <- alice
+		entries <- noAddress
+		close(entries)
+	}()
+
+	peers := <-found
+	want := IdlePeer{Nick: "alice", Hostname: "laptop", Host: "192.168.1.5", Port: 4000}
+	if len(peers) != 1 || peers[0] != want {
+		t.Errorf("found %+v, want only %+v", peers, want)
+	}
+}
+
+func TestDropStaleRooms(t *testing.T) {
+	live, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	gone, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone.Close()
+
+	current := DiscoveredRoom{Name: "cabin", Host: "127.0.0.1", Port: live.Addr().(*net.TCPAddr).Port}
+	previous := DiscoveredRoom{Name: "cabin", Host: "127.0.0.1", Port: gone.Addr().(*net.TCPAddr).Port}
+	other := DiscoveredRoom{Name: "lodge", Host: "127.0.0.1", Port: previous.Port}
+
+	got := dropStaleRooms([]DiscoveredRoom{previous, current, current, other})
+	want := []DiscoveredRoom{current, other} // lone rooms are kept without probing
+	if !slices.Equal(got, want) {
+		t.Errorf("dropStaleRooms = %+v, want %+v", got, want)
+	}
+}
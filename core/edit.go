@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotAuthor is returned when editing or deleting someone else's message,
+// or one too old for the host to remember
+var ErrNotAuthor = errors.New("you can only edit or delete your own recent messages")
+
+// ErrNoOwnMessage is returned by /edit and /delete before we sent anything
+var ErrNoOwnMessage = errors.New("you haven't sent a message yet")
+
+// editMessage applies an edit or delete of message msg.ID from author (nil =
+// the host) if they wrote it, then tells the host UI and the room
+func (h *Host) editMessage(author *Client, msg Message) error {
+	if msg.Type == MsgTypeEdit && strings.TrimSpace(msg.Text) == "" {
+		return errors.New("edited text is empty")
+	}
+
+	h.mutex.Lock()
+	owner, ok := h.authors[msg.ID]
+	if !ok || owner != author {
+		h.mutex.Unlock()
+		return ErrNotAuthor
+	}
+	if msg.Type == MsgTypeDelete {
+		delete(h.authors, msg.ID)
+		delete(h.reactions, msg.ID)
+	}
+	nick := h.nick
+	if author != nil {
+		nick = author.nick
+	}
+	h.mutex.Unlock()
+
+	update := Message{Type: msg.Type, Nick: nick, ID: msg.ID, Text: msg.Text}
+	if msg.Type == MsgTypeDelete {
+		update.Text = ""
+		if h.callbacks.OnMessageDeleted != nil {
+			h.callbacks.OnMessageDeleted(msg.ID)
+		}
+	} else if h.callbacks.OnMessageEdited != nil {
+		h.callbacks.OnMessageEdited(msg.ID, msg.Text)
+	}
+	h.broadcast(update, nil)
+	return nil
+}
+
+// EditMessage replaces the text of one of the host's own messages
+func (h *Host) EditMessage(id string, text string) error {
+	return h.editMessage(nil, Message{Type: MsgTypeEdit, ID: id, Text: text})
+}
+
+// DeleteMessage removes one of the host's own messages for everyone
+func (h *Host) DeleteMessage(id string) error {
+	return h.editMessage(nil, Message{Type: MsgTypeDelete, ID: id})
+}
+
+// lastOwnMessage is the ID of the host's latest message, for /edit and /delete
+func (h *Host) lastOwnMessage() (string, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.lastOwnMsgID == "" {
+		return "", ErrNoOwnMessage
+	}
+	return h.lastOwnMsgID, nil
+}
+
+// EditMessage asks the host to replace the text of one of our messages
+func (c *ChatClient) EditMessage(id string, text string) error {
+	return SendMessage(c.hostConn(), Message{Type: MsgTypeEdit, Nick: c.nick, ID: id, Text: text})
+}
+
+// DeleteMessage asks the host to remove one of our messages for everyone
+func (c *ChatClient) DeleteMessage(id string) error {
+	return SendMessage(c.hostConn(), Message{Type: MsgTypeDelete, Nick: c.nick, ID: id})
+}
+
+// lastOwnMessage is the ID of our latest message as echoed by the host
+func (c *ChatClient) lastOwnMessage() (string, error) {
+	if c.lastOwnMsgID == "" {
+		return "", ErrNoOwnMessage
+	}
+	return c.lastOwnMsgID, nil
+}
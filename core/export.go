@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TranscriptEntry is one line of chat history, as kept by the UIs for /export
+type TranscriptEntry struct {
+	Time   time.Time
+	ID     string // Chat message ID, "" for anything that can't be edited
+	Nick   string // "*" for actions like /me, "" for system events
+	Text   string
+	Edited bool
+}
+
+// FormatTranscript renders chat history as Markdown, or as plain text like the terminal shows it
+func FormatTranscript(entries []TranscriptEntry, markdown bool) string {
+	var b strings.Builder
+	if markdown {
+		b.WriteString("# CabinChat transcript\n\n")
+	}
+	for _, e := range entries {
+		stamp := e.Time.Format("2006-01-02 15:04")
+		if e.Edited {
+			e.Text += " (edited)"
+		}
+		switch {
+		case markdown && e.Nick == "":
+			fmt.Fprintf(&b, "*%s · %s*\n\n", stamp, e.Text)
+		case markdown && e.Nick == "*":
+			fmt.Fprintf(&b, "%s · _%s_\n\n", stamp, e.Text)
+		case markdown:
+			fmt.Fprintf(&b, "**%s** %s\n%s\n\n", e.Nick, stamp, e.Text)
+		case e.Nick == "":
+			fmt.Fprintf(&b, "[%s] *** %s\n", stamp, e.Text)
+		case e.Nick == "*":
+			fmt.Fprintf(&b, "[%s] * %s\n", stamp, e.Text)
+		default:
+			fmt.Fprintf(&b, "[%s] <%s> %s\n", stamp, e.Nick, e.Text)
+		}
+	}
+	return b.String()
+}
+
+// EditEntry replaces the text of message id in the history, marking it edited
+func EditEntry(entries []TranscriptEntry, id string, text string) {
+	for i := range entries {
+		if entries[i].ID == id {
+			entries[i].Text = text
+			entries[i].Edited = true
+			return
+		}
+	}
+}
+
+// DeleteEntry drops message id from the history
+func DeleteEntry(entries []TranscriptEntry, id string) []TranscriptEntry {
+	for i := range entries {
+		if entries[i].ID == id {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+// ExportTranscript writes chat history to path, as Markdown when it ends in
+// .md. An empty path picks a timestamped name in Settings.DownloadDir.
+// It returns the path written.
+func ExportTranscript(path string, entries []TranscriptEntry) (string, error) {
+	if path == "" {
+		path = filepath.Join(Settings.DownloadDir, DefaultTranscriptName())
+	}
+	markdown := strings.EqualFold(filepath.Ext(path), ".md")
+	if err := os.WriteFile(path, []byte(FormatTranscript(entries, markdown)), 0644); err != nil {
+		return "", fmt.Errorf("exporting transcript: %w", err)
+	}
+	return path, nil
+}
+
+// DefaultTranscriptName is the file name /export suggests
+func DefaultTranscriptName() string {
+	return fmt.Sprintf("cabinchat-%s.md", time.Now().Format("20060102-150405"))
+}
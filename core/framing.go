@@ -0,0 +1,195 @@
+package core
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// EncFramed is offered in join messages like a Data encoding, but agrees on
+// length-prefixed framing instead: messages to a peer that accepts it are
+// sent as frames rather than JSON lines. Either form can always be read, so
+// the switch needs no synchronisation; peers that don't offer it only ever
+// get JSON lines.
+const EncFramed = "framed"
+
+// EncBinary, offered along with EncFramed, has file contents sent raw
+// instead of base64 in the JSON: a MsgTypeFileBinary frame carrying the rest
+// of the file message, then a raw frame with the bytes. Base64 adds a third
+// to every transfer.
+const EncBinary = "binary"
+
+// A frame is frameMarker, a 4-byte big-endian payload length, then the JSON
+// payload. The marker can't begin a JSON line, which is how ReadMessage
+// tells the two apart. Raw frames are the same with rawFrameMarker.
+const (
+	frameMarker     = 0x00
+	rawFrameMarker  = 0x01
+	frameHeaderSize = 5
+)
+
+// maxFrameSize is the largest frame accepted: a base64 file at MaxFileSize
+// plus plenty of room for the rest of its message
+const maxFrameSize = 2 * MaxFileSize
+
+// ErrFrameTooLarge is returned by ReadMessage for a frame over maxFrameSize.
+// The stream can't be resynchronised afterwards.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// ErrFrameCorrupt is returned by ReadMessage when a frame isn't the kind the
+// stream calls for. Like ErrFrameTooLarge it leaves the stream unreadable.
+var ErrFrameCorrupt = errors.New("corrupt frame")
+
+// framedConn carries what a connection's peer agreed to. A client only
+// learns that from the host's join echo, when other goroutines may already
+// be writing to the connection, so the flags are updated in place.
+type framedConn struct {
+	net.Conn
+	framing atomic.Bool // peer accepts frames
+	binary  atomic.Bool // peer also accepts raw file frames
+}
+
+// CloseWrite half-closes the underlying connection, if it supports that
+func (c *framedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// isFramed reports whether messages to conn are sent as frames
+func isFramed(conn net.Conn) bool {
+	fc, ok := conn.(*framedConn)
+	return ok && fc.framing.Load()
+}
+
+// acceptsFraming reports whether a join message offers framing
+func acceptsFraming(join Message) bool {
+	return slices.Contains(strings.Split(join.Enc, ","), EncFramed)
+}
+
+// acceptsBinary reports whether a join message offers raw file frames
+func acceptsBinary(join Message) bool {
+	return acceptsFraming(join) && slices.Contains(strings.Split(join.Enc, ","), EncBinary)
+}
+
+// framed wraps conn, unless already wrapped, and takes up what a join (or
+// join echo) agreed to. Clients wrap their connection with an empty join
+// before the echo arrives.
+func framed(conn net.Conn, join Message) net.Conn {
+	fc, ok := conn.(*framedConn)
+	if !ok {
+		fc = &framedConn{Conn: conn}
+	}
+	fc.framing.Store(acceptsFraming(join))
+	fc.binary.Store(acceptsBinary(join))
+	return fc
+}
+
+// splitRaw prepares a file message for conn: for peers taking raw frames it
+// returns a MsgTypeFileBinary header and the bytes to follow it, for others
+// the message with its contents in base64 Data
+func splitRaw(conn net.Conn, msg Message) (Message, []byte, error) {
+	if msg.Type != MsgTypeFile {
+		return msg, nil, nil
+	}
+	if fc, ok := conn.(*framedConn); ok && fc.binary.Load() {
+		raw, err := fileBytes(msg)
+		if err != nil {
+			return msg, nil, err
+		}
+		msg.Type, msg.Data, msg.Raw = MsgTypeFileBinary, "", nil
+		return msg, raw, nil
+	}
+	if msg.Raw != nil {
+		msg.Data, msg.Raw = base64.StdEncoding.EncodeToString(msg.Raw), nil
+	}
+	return msg, nil, nil
+}
+
+// agreedEncodings lists what the host takes up from a join's offer, for
+// echoing back in its own join
+func agreedEncodings(join Message) string {
+	var agreed []string
+	if acceptsGzip(join) {
+		agreed = append(agreed, EncGzip)
+	}
+	if acceptsFraming(join) {
+		agreed = append(agreed, EncFramed)
+	}
+	if acceptsBinary(join) {
+		agreed = append(agreed, EncBinary)
+	}
+	return strings.Join(agreed, ",")
+}
+
+// appendFrame wraps a JSON payload in a frame
+func appendFrame(payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	frame[0] = frameMarker
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// appendRawFrame appends raw file contents to data as a raw frame
+func appendRawFrame(data []byte, raw []byte) []byte {
+	var header [frameHeaderSize]byte
+	header[0] = rawFrameMarker
+	binary.BigEndian.PutUint32(header[1:], uint32(len(raw)))
+	return append(append(data, header[:]...), raw...)
+}
+
+// atFrame reports whether the next message in reader is a frame
+func atFrame(reader *bufio.Reader) bool {
+	return atMarker(reader, frameMarker)
+}
+
+// atMarker reports whether the next byte in reader is marker
+func atMarker(reader *bufio.Reader, marker byte) bool {
+	b, err := reader.Peek(1)
+	return err == nil && b[0] == marker
+}
+
+// readFrame reads one frame's payload. Its length is known up front, so it
+// is read straight into a buffer of the right size.
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	return readMarkedFrame(reader, frameMarker)
+}
+
+// readRawFrame reads the raw frame following a MsgTypeFileBinary header
+func readRawFrame(reader *bufio.Reader) ([]byte, error) {
+	return readMarkedFrame(reader, rawFrameMarker)
+}
+
+// readMarkedFrame reads a frame that must start with marker
+func readMarkedFrame(reader *bufio.Reader, marker byte) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.EOF && marker == rawFrameMarker {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if header[0] != marker {
+		return nil, fmt.Errorf("%w: expected frame type %d, got %d", ErrFrameCorrupt, marker, header[0])
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
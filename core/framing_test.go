@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBadFileHeaderSkipsItsContents(t *testing.T) {
+	stream := appendRawFrame(appendFrame([]byte(`{"type":"filebin","text":`)), []byte(`{"type":"msg","text":"smuggled"}`))
+	stream = append(stream, appendFrame([]byte(`{"type":"msg","text":"next"}`))...)
+	reader := bufio.NewReader(bytes.NewReader(stream))
+
+	if _, err := ReadMessage(reader); !errors.Is(err, ErrBadMessage) {
+		t.Fatalf("garbled header: err = %v, want ErrBadMessage", err)
+	}
+	msg, err := ReadMessage(reader)
+	if err != nil {
+		t.Fatalf("message after the garbled file: %v", err)
+	}
+	if msg.Text != "next" {
+		t.Errorf("read %q after the garbled file, want the next message", msg.Text)
+	}
+}
+
+func TestBadFileHeaderWithTruncatedContents(t *testing.T) {
+	stream := appendRawFrame(appendFrame([]byte(`{"type":`)), []byte("contents"))
+	reader := bufio.NewReader(bytes.NewReader(stream[:len(stream)-2]))
+
+	if _, err := ReadMessage(reader); err == nil || errors.Is(err, ErrBadMessage) {
+		t.Errorf("err = %v, want a fatal read error", err)
+	}
+}
+
+func TestAgreedEncodings(t *testing.T) {
+	tests := []struct {
+		offer, want string
+	}{
+		{"", ""},
+		{EncGzip, EncGzip},
+		{EncBinary, ""}, // raw file frames need framing
+		{EncFramed + "," + EncBinary, EncFramed + "," + EncBinary},
+		{EncGzip + "," + EncFramed + "," + EncBinary + ",zstd", EncGzip + "," + EncFramed + "," + EncBinary},
+	}
+	for _, tt := range tests {
+		if got := agreedEncodings(Message{Type: MsgTypeJoin, Enc: tt.offer}); got != tt.want {
+			t.Errorf("agreedEncodings(%q) = %q, want %q", tt.offer, got, tt.want)
+		}
+	}
+}
+
+func TestFramedStreams(t *testing.T) {
+	contents := bytes.Repeat([]byte("cabin\n\x00\x01"), MaxFileSize/8)
+	tests := []struct {
+		name  string
+		offer string
+	}{
+		{"lines", ""},
+		{"frames", EncFramed},
+		{"raw files", EncFramed + "," + EncBinary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &trickleConn{max: math.MaxInt}
+			conn := framed(buf, Message{Type: MsgTypeJoin, Enc: tt.offer})
+			SendMessage(conn, Message{Type: MsgTypeMsg, Text: "multi\nline"})
+			SendMessage(conn, Message{Type: MsgTypeFile, Nick: "alice", Text: "big.bin", Raw: contents})
+			SendMessage(conn, Message{Type: MsgTypeMsg, Text: "after"})
+			if framing := buf.buf.Bytes()[0] == frameMarker; framing != (tt.offer != "") {
+				t.Errorf("sent as frames: %v", framing)
+			}
+
+			reader := bufio.NewReader(&buf.buf)
+			if msg, err := ReadMessage(reader); err != nil || msg.Text != "multi\nline" {
+				t.Fatalf("first message: %+v, %v", msg, err)
+			}
+			msg, err := ReadMessage(reader)
+			if err != nil {
+				t.Fatalf("file: %v", err)
+			}
+			got, err := fileBytes(msg)
+			if err != nil || msg.Type != MsgTypeFile || !bytes.Equal(got, contents) {
+				t.Errorf("file came back as %s with %d bytes (%v), want all %d", msg.Type, len(got), err, len(contents))
+			}
+			if msg, err := ReadMessage(reader); err != nil || msg.Text != "after" {
+				t.Errorf("message after the file: %+v, %v", msg, err)
+			}
+		})
+	}
+}
+
+func TestOversizedFrameIsRefused(t *testing.T) {
+	header := []byte{frameMarker, 0xff, 0xff, 0xff, 0xff}
+	if _, err := ReadMessage(bufio.NewReader(bytes.NewReader(header))); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("err = %v, want ErrFrameTooLarge", err)
+	}
+}
+
+func TestFramingIsNegotiatedAtJoin(t *testing.T) {
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+	if !isFramed(alice.conn) {
+		t.Error("client offering framing still sends lines after joining")
+	}
+
+	// A client from before framing only ever gets JSON lines
+	old, err := transport.Dial(h.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	old.SetDeadline(time.Now().Add(testTimeout))
+	SendMessage(old, Message{Type: MsgTypeJoin, Nick: "old"})
+	line, err := bufio.NewReader(old).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("reading a line: %v", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(line, &msg); err != nil || msg.Type != MsgTypeUserList {
+		t.Errorf("%q isn't the user list as a JSON line: %v", line, err)
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"cabinchat/i18n"
+	"cabinchat/logger"
+)
+
+// Handing the room over (/host <nick>) keeps it going when the host leaves.
+// The host asks the chosen client to start hosting (MsgTypeHandoff with
+// Target). That client starts its own room and answers with the join link
+// (Text), or with why it can't (Data). The host then passes the link to
+// everyone and closes; clients reconnect to the link instead of giving up.
+//
+// The new host must be reachable the same way the old one was: everyone on
+// one subnet, or at least able to reach its port past any NAT or firewall.
+// History, files in flight and calls don't carry over.
+
+// handoffTimeout is how long clients keep trying to reach the new host
+const handoffTimeout = 15 * time.Second
+
+// ErrHandoffUnsupported is sent back by clients that can't host, such as the
+// terminal client
+var ErrHandoffUnsupported = errors.New("can't host from this client")
+
+// startHandoff asks nick to take over the room, returning local output
+func (h *Host) startHandoff(nick string) string {
+	if nick == h.nick {
+		return i18n.T("handoff.self") + "\n"
+	}
+	if !h.sendToNick(nick, Message{Type: MsgTypeHandoff, Nick: h.nick, Target: nick}) {
+		return i18n.T("user.notFound", nick) + "\n"
+	}
+	h.mutex.Lock()
+	h.handoffTo = nick
+	h.mutex.Unlock()
+	return i18n.T("handoff.asking", nick) + "\n"
+}
+
+// finishHandoff handles the chosen client's answer: on success everyone is
+// sent the new room's link and this room closes
+func (h *Host) finishHandoff(client *Client, msg Message) {
+	h.mutex.Lock()
+	asked := h.handoffTo == client.nick
+	if asked {
+		h.handoffTo = ""
+	}
+	h.mutex.Unlock()
+	if !asked {
+		return
+	}
+
+	if msg.Text == "" {
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("handoff.failed", client.nick, msg.Data))
+		}
+		return
+	}
+
+	text := i18n.T("handoff.takingOver", client.nick)
+	h.broadcast(Message{Type: MsgTypeSystem, Text: text}, nil)
+	h.broadcast(Message{Type: MsgTypeHandoff, Nick: client.nick, Text: msg.Text}, nil)
+	// Shutdown waits for this client's goroutine, so it can't run here
+	go func() {
+		h.Shutdown()
+		if h.callbacks.OnClosed != nil {
+			h.callbacks.OnClosed(i18n.T("handoff.done", client.nick))
+		}
+	}()
+}
+
+// handleHandoff deals with the host asking us to take over (Target is us) or
+// announcing who did. It reports whether this connection is done because we
+// are the new host.
+func (c *ChatClient) handleHandoff(msg Message) bool {
+	if msg.Target == "" {
+		if msg.Nick == c.nick {
+			return false
+		}
+		room, err := ParseJoinAddress(msg.Text)
+		if err != nil {
+			logger.Warnf("Ignoring handoff to %s: %v", msg.Nick, err)
+			return false
+		}
+		c.handoff = &room
+		return false
+	}
+
+	reply := Message{Type: MsgTypeHandoff, Nick: c.nick}
+	link, err := "", ErrHandoffUnsupported
+	if c.callbacks.OnHostHandoff != nil {
+		link, err = c.callbacks.OnHostHandoff(msg.Nick)
+	}
+	if err != nil {
+		reply.Data = err.Error()
+		SendMessage(c.hostConn(), reply)
+		return false
+	}
+	reply.Text = link
+	SendMessage(c.hostConn(), reply)
+	if !c.closed.Swap(true) {
+		close(c.quit)
+	}
+	c.hostConn().Close()
+	return true
+}
+
+// followHandoff reconnects to the room the host handed over to, if it did,
+// retrying while the new host gets going
+func (c *ChatClient) followHandoff() bool {
+	if c.handoff == nil {
+		return false
+	}
+	room := *c.handoff
+	c.handoff = nil
+
+	deadline := time.Now().Add(handoffTimeout)
+	for time.Now().Before(deadline) && !c.closed.Load() {
+		if err := c.connect(room); err != nil {
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if c.callbacks.OnReconnected != nil {
+			c.callbacks.OnReconnected(room.Address())
+		}
+		return true
+	}
+	return false
+}
@@ -2,32 +2,50 @@ package core
 
 import (
 	"bufio"
-	"encoding/base64"
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"fyne.io/fyne/v2"
-	"github.com/grandcat/zeroconf"
 
+	"cabinchat/i18n"
+	"cabinchat/logger"
 	"cabinchat/media"
 )
 
 // Client represents a connected chat client
 type Client struct {
-	conn   net.Conn
-	nick   string
-	reader *bufio.Reader
+	conn         net.Conn
+	nick         string
+	reader       *bufio.Reader
+	msgLimiter   *rateLimiter
+	offerLimiter *rateLimiter
+	strikes      int // consecutive messages dropped for flooding
+	joinedAt     time.Time
+	lastActive   time.Time   // last chat message, for idle reporting
+	idleWarnedAt time.Time   // when warned about Settings.IdleKick
+	kicked       atomic.Bool // disconnected by the host: Settings.IdleKick or /ban
+	gzip         bool        // accepts gzipped file data
+	observer     bool        // joined quietly, see observeFlag
+	away         bool
+	awayText     string
 }
 
 // PendingOffer tracks a file offer awaiting acceptance
 type PendingOffer struct {
 	SenderNick    string
 	SenderConn    net.Conn
+	SenderAddr    string // SenderConn's IP, see IsTrusted
 	Filename      string
+	Size          string // formatted, as offered
 	RecipientNick string
 }
 
@@ -35,90 +53,248 @@ type PendingOffer struct {
 type HostCallbacks struct {
 	OnMessageReceived func(msg Message)
 	OnSystemMessage   func(text string)
-	OnUserList        func(users []string) // Triggered when someone joins/leaves
+	OnUserList        func(users []string)           // Triggered when someone joins/leaves
+	OnPresence        func(nick string, joined bool) // Someone's arrival or departure was announced
 	OnFileOffer       func(offer PendingOffer)
-	OnFileReceived    func(filename string, data string, sender string)
+	OnFileReceived    func(filename string, data []byte, sender string, err error) // nil = auto-save to Settings.DownloadDir
+	OnPoll            func(poll Poll)                                              // Poll opened or closed
+	OnReaction        func(id string, emoji string, count int)                     // Reaction count changed
+	OnClear           func()                                                       // /clear; nil = ANSI clear in output
+	OnExport          func(path string)                                            // /export; path "" = let the user choose
+	OnNickChanged     func(nick string)                                            // Host's own nick changed
+	OnAddressChanged  func(addr string)                                            // Address clients can connect to, e.g. after a network switch
+	OnTopic           func(topic string)                                           // Room topic set or cleared
+	OnMessageEdited   func(id string, text string)
+	OnMessageDeleted  func(id string)
+	OnClosed          func(reason string)   // Room shut itself down, e.g. after Settings.IdleTimeout
+	OnBansChanged     func(bans []Ban)      // Ban list changed, for keeping it across restarts
+	OnAliasesChanged  func(aliases []Alias) // /alias changed Settings.Aliases, for keeping them across restarts
+	OnSaveDirChanged  func(dir string)      // /savedir changed Settings.DownloadDir, for keeping it across restarts
 }
 
 // Host manages the chat room server
 type Host struct {
 	listener        net.Listener
+	transport       Transport
+	config          roomConfig // Port is the one actually bound once started
+	syncSettings    bool       // the app's own room: keep Settings.Port up to date, see NewHost
 	clients         map[net.Conn]*Client
 	mutex           sync.RWMutex
 	nick            string
 	pendingOffers   map[string]*PendingOffer // key: sender nick
-	hostPendingFile *PendingOffer            // incoming file offer for host
+	hostOffers      []*PendingOffer          // incoming file offers for host, oldest first
 	mediaManager    *media.MediaManager
 	callbacks       HostCallbacks
 	app             fyne.App
-	mdnsServer      *zeroconf.Server
+	unadvertise     func()          // withdraws the room's advert, nil = not advertised
+	webhook         *webhook        // nil = Settings.WebhookURL unset
+	ctx             context.Context // cancelled by Shutdown
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup // accept loop, address watcher and per-client goroutines
+	poll            *Poll          // current or last poll
+	pollVotes       map[string]int // voter nick -> option index
+	nextMsgID       int
+	address         string                                // ip:port clients can connect to
+	topic           string                                // sent to every joiner, "" = none
+	motd            string                                // welcome message for every joiner, "" = none
+	fingerprint     string                                // TLS certificate fingerprint, "" without TLS
+	reactions       map[string]map[string]map[string]bool // message ID -> emoji -> reacting nicks
+	authors         map[string]*Client                    // message ID -> author, nil = host; for edits and deletes
+	lastOwnMsgID    string                                // host's latest message, for /edit and /delete
+	stats           sessionCounters
+	room            roomCounters           // everyone's traffic, for /stats
+	lastActivity    time.Time              // last message or join, for Settings.IdleTimeout
+	idleWarnedAt    time.Time              // when the room was warned it's about to close
+	handoffTo       string                 // client asked to take over the room
+	bans            []Ban                  // banned addresses, see ban.go
+	departures      map[string]*time.Timer // dropped nick -> pending "left" notice
+	lastPrivateFrom string                 // who /r replies to
+	away            bool                   // host's own away status
+	awayText        string
+	awayReplied     map[string]bool      // who got the away message since going away
+	slots           transferSlots        // files in flight, see Settings.MaxTransfers
+	delivered       map[string]delivered // recent client messages by nonce, see outbox.go
 }
 
-// NewHost creates a new chat host
-func NewHost(nick string, app fyne.App, callbacks HostCallbacks) *Host {
+// addressCheckInterval is how often the host looks for a changed local IP
+const addressCheckInterval = 10 * time.Second
+
+// shutdownGrace is how long Shutdown waits for clients to read the closing notice
+const shutdownGrace = 500 * time.Millisecond
+
+// roomConfig is what sets one hosted room apart from another, see newRoom
+type roomConfig struct {
+	Name string // Advertised name, "" = the machine's hostname
+	Port int    // Port to listen on, 0 = any free port
+	Motd string // Welcome message sent to each joiner, "" = none
+}
+
+// NewHost creates a new chat host for the room described by Settings. The
+// port it ends up on is stored back in Settings.Port, so hosting again later
+// reuses it. A nil transport means TCP.
+func NewHost(nick string, app fyne.App, callbacks HostCallbacks, transport Transport) *Host {
+	h := newRoom(nick, app, callbacks, transport, roomConfig{Name: Settings.RoomName, Port: Settings.Port, Motd: Settings.Motd})
+	h.syncSettings = true
+	return h
+}
+
+// newRoom creates a host for the room in config, leaving Settings alone, so
+// tests can run rooms side by side. Other options still come from Settings.
+// A nil transport means TCP.
+func newRoom(nick string, app fyne.App, callbacks HostCallbacks, transport Transport, config roomConfig) *Host {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Host{
+		ctx:           ctx,
+		cancel:        cancel,
 		clients:       make(map[net.Conn]*Client),
 		nick:          nick,
 		pendingOffers: make(map[string]*PendingOffer),
+		reactions:     make(map[string]map[string]map[string]bool),
+		authors:       make(map[string]*Client),
+		departures:    make(map[string]*time.Timer),
+		delivered:     make(map[string]delivered),
+		awayReplied:   make(map[string]bool),
 		callbacks:     callbacks,
 		app:           app,
+		transport:     transportOrDefault(transport),
+		config:        config,
+		motd:          config.Motd,
+		slots:         newTransferSlots(Settings.MaxTransfers),
+		stats:         sessionCounters{started: time.Now()},
+		lastActivity:  time.Now(),
+		bans:          slices.Clone(Settings.Bans),
 	}
 }
 
 // Start begins hosting the chat room
 func (h *Host) Start() error {
-	// Start mDNS advertisement
-	// Start mDNS advertisement
-	server, err := StartMDNSAdvertisement()
-	if err != nil {
-		fmt.Printf("⚠️  mDNS advertisement failed: %v (room still accessible via IP)\n", err)
-	} else {
-		h.mdnsServer = server
-	}
-
 	// Start TCP listener
-	addr := fmt.Sprintf(":%d", Settings.Port)
-	listener, err := net.Listen("tcp", addr)
+	requested := h.config.Port
+	listener, port, err := listen(h.transport, requested)
 	if err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
-	h.listener = listener
+	h.config.Port = port
+	if h.syncSettings {
+		Settings.Port = port
+	}
+	if requested != 0 && port != requested && h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(i18n.T("host.portMoved", requested, port))
+	}
+	if Settings.TLS {
+		tlsListener, fingerprint, err := listenTLS(listener, h.Name())
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		listener = tlsListener
+		h.fingerprint = fingerprint
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("host.fingerprint", fingerprint))
+		}
+	}
 
-	// Initialize Media Manager for Host
-	h.mediaManager = media.NewMediaManager(h.app, func(target string, data string) {
-		msg := Message{
-			Type:   MsgTypeWebRTC,
-			Nick:   h.nick,
-			Text:   "signal",
-			Data:   data,
-			Target: target,
-		}
-		if target != "" {
-			h.sendToNick(target, msg)
+	// Advertise once bound, so the advert carries the real port and a
+	// failed start never leaves a dead room behind
+	if Settings.Advertise {
+		stop, err := h.transport.Advertise(h.Name(), port)
+		if err != nil {
+			logger.Warnf("mDNS advertisement failed: %v (room still accessible via IP)", err)
 		} else {
-			// Broadcast? Not really typical for signaling
+			h.unadvertise = stop
 		}
-	})
+	}
+	h.listener = listener
+	noteHosted(h.Name(), port)
+	if Settings.WebhookURL != "" {
+		h.webhook = startWebhook(h.ctx, Settings.WebhookURL)
+	}
 
-	localIP := getLocalIP()
-	if h.callbacks.OnSystemMessage != nil {
-		h.callbacks.OnSystemMessage(fmt.Sprintf("Hosting room on %s:%d", localIP, Settings.Port))
+	// Initialize Media Manager for Host; without an app (the terminal client)
+	// there is nowhere to show a call, so calls are refused instead
+	if h.app != nil {
+		h.mediaManager = media.NewMediaManager(h.app, h.sendSignal)
+		h.mediaManager.OnRing = func(string) { PlaySound(media.SoundCall) }
 	}
 
+	h.updateAddress()
+
 	// Start accepting connections
+	h.wg.Add(2)
 	go h.acceptConnections()
+	go h.watchAddress()
+	if Settings.IdleTimeout > 0 {
+		h.wg.Add(1)
+		go h.watchIdle()
+	}
 
 	return nil
 }
 
+// Address returns the ip:port clients can use to connect to the room
+func (h *Host) Address() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.address
+}
+
+// updateAddress recomputes the room's address and announces it if it changed
+func (h *Host) updateAddress() {
+	addr := net.JoinHostPort(h.transport.LocalIP(), strconv.Itoa(h.config.Port))
+	h.mutex.Lock()
+	changed := addr != h.address
+	h.address = addr
+	h.mutex.Unlock()
+	if !changed {
+		return
+	}
+
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(i18n.T("host.hosting", addr))
+	}
+	if h.callbacks.OnAddressChanged != nil {
+		h.callbacks.OnAddressChanged(addr)
+	}
+}
+
+// watchAddress keeps the address current when the host switches networks
+func (h *Host) watchAddress() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(addressCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			h.updateAddress()
+		}
+	}
+}
+
 // acceptConnections handles incoming client connections
 func (h *Host) acceptConnections() {
+	defer h.wg.Done()
 	for {
 		conn, err := h.listener.Accept()
 		if err != nil {
 			return // Listener closed
 		}
-		go h.handleClient(conn)
+		if h.ctx.Err() != nil {
+			conn.Close()
+			return
+		}
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logPanic("joining client", r)
+					conn.Close()
+				}
+			}()
+			h.handleClient(conn)
+		}()
 	}
 }
 
@@ -128,166 +304,353 @@ func (h *Host) handleClient(conn net.Conn) {
 
 	// Wait for join message
 	msg, err := ReadMessage(reader)
-	if err != nil || msg.Type != MsgTypeJoin {
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if msg.Type != MsgTypeJoin {
+		SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("join.notJoined")})
+		conn.Close()
+		return
+	}
+	conn = framed(conn, msg)
+	if h.checkBanned(conn, msg.Nick) {
+		SendMessage(conn, Message{Type: MsgTypeRefused, Text: i18n.T("join.banned")})
+		conn.Close()
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("join.bannedNotice", msg.Nick))
+		}
+		return
+	}
+	if wantsToObserve(msg) && !Settings.AllowObservers {
+		SendMessage(conn, Message{Type: MsgTypeRefused, Text: i18n.T("join.noObservers")})
 		conn.Close()
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("join.noObserversNotice", msg.Nick))
+		}
 		return
 	}
 
 	client := &Client{
-		conn:   conn,
-		nick:   msg.Nick,
-		reader: reader,
+		conn:         conn,
+		nick:         msg.Nick,
+		reader:       reader,
+		msgLimiter:   newRateLimiter(Settings.MsgRate, Settings.MsgBurst),
+		offerLimiter: newRateLimiter(float64(Settings.OffersPerMinute)/60, Settings.OffersPerMinute),
+		joinedAt:     time.Now(),
+		lastActive:   time.Now(),
+		gzip:         acceptsGzip(msg),
+		observer:     wantsToObserve(msg),
 	}
 
-	// Add client
+	// Add client, unless the room is at capacity
 	h.mutex.Lock()
+	if Settings.MaxClients > 0 && len(h.clients) >= Settings.MaxClients {
+		h.mutex.Unlock()
+		SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("join.full", Settings.MaxClients)})
+		conn.Close()
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("join.fullNotice", client.nick))
+		}
+		return
+	}
 	h.clients[conn] = client
+	h.lastActivity = time.Now()
+	topic, motd := h.topic, h.motd
+	returning := !client.observer && h.rejoined(client.nick)
 	h.mutex.Unlock()
 
-	// Announce join
-	// PlayBell() // UI should handle sound
-	if h.callbacks.OnSystemMessage != nil {
-		h.callbacks.OnSystemMessage(fmt.Sprintf("%s joined", client.nick))
+	if enc := agreedEncodings(msg); enc != "" {
+		SendMessage(conn, Message{Type: MsgTypeJoin, Nick: h.nick, Enc: enc})
+	}
+
+	// Greet once: a dropped client coming straight back already saw it
+	if motd != "" && !returning {
+		SendMessage(conn, Message{Type: MsgTypeSystem, Text: motd})
+	}
+	if topic != "" {
+		SendMessage(conn, Message{Type: MsgTypeTopic, Nick: h.nick, Text: topic})
+	}
+	SendMessage(conn, Message{Type: MsgTypeUserList, Text: h.getUserList()})
+
+	// Announce join, unless a dropped client came straight back or is observing
+	if client.observer {
+		h.noteObserver(client.nick, true)
+	} else if returning {
+		logger.Debugf("%s reconnected", client.nick)
+	} else {
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("room.joined", client.nick))
+		}
+		if h.callbacks.OnPresence != nil {
+			h.callbacks.OnPresence(client.nick, true)
+		}
+		h.broadcast(Message{Type: MsgTypeSystem, Nick: client.nick, Text: i18n.T("room.joined", client.nick), Data: PresenceJoined}, conn)
+		h.broadcast(Message{Type: MsgTypeUserJoined, Nick: client.nick}, conn)
+		h.notifyWebhook(WebhookJoin, client.nick, "", "")
 	}
-	h.broadcast(Message{Type: MsgTypeSystem, Text: fmt.Sprintf("%s joined", client.nick)}, conn)
 	if h.callbacks.OnUserList != nil {
 		h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
 	}
 
-	// Read messages from client
-	for {
+	dropped := h.readClient(client, reader)
+
+	// Client disconnected
+	h.mutex.Lock()
+	_, listed := h.clients[conn]
+	delete(h.clients, conn)
+	h.mutex.Unlock()
+	conn.Close()
+
+	if !listed {
+		return // removed by kickAll, which announces it once for everyone
+	}
+	if h.callbacks.OnUserList != nil {
+		h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
+	}
+	if client.observer {
+		h.noteObserver(client.nick, false)
+	} else if dropped {
+		h.deferLeave(client.nick)
+	} else {
+		h.announceLeave(client.nick)
+	}
+}
+
+// readClient handles a joined client's messages until it leaves, is kicked
+// or its connection drops, reporting whether it dropped. A panic while
+// handling a message is logged and treated as a dropped connection, so one
+// client's bad message can't take the room down.
+func (h *Host) readClient(client *Client, reader *bufio.Reader) (dropped bool) {
+	conn := client.conn
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic("handling "+client.nick, r)
+			dropped = true
+		}
+	}()
+
+	for h.ctx.Err() == nil {
 		msg, err := ReadMessage(reader)
+		if errors.Is(err, ErrBadMessage) {
+			logger.Warnf("Skipping message from %s: %v", client.nick, err)
+			continue
+		}
 		if err != nil {
+			dropped = !client.kicked.Load()
 			break
 		}
+		if msg.Type == MsgTypeLeave {
+			break
+		}
+
+		// Flood protection: drop over-limit messages, kick persistent offenders.
+		// The sender is told which chat message went, or its outbox would
+		// wait for the echo forever.
+		if !client.allow(msg.Type) {
+			if msg.Type == MsgTypeMsg && msg.Nonce != "" {
+				SendMessage(conn, Message{Type: MsgTypeDropped, Nonce: msg.Nonce})
+			}
+			client.strikes++
+			if client.strikes >= Settings.FloodStrikes {
+				SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("flood.kicked")})
+				if h.callbacks.OnSystemMessage != nil {
+					h.callbacks.OnSystemMessage(i18n.T("flood.kickedNotice", client.nick))
+				}
+				break
+			}
+			if client.strikes == 1 {
+				SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("flood.warning")})
+			}
+			continue
+		}
+		client.strikes = 0
+
+		switch msg.Type {
+		case MsgTypeMsg, MsgTypePrivate, MsgTypeEdit:
+			var cut bool
+			if msg.Text, cut = TruncateMessage(msg.Text); cut {
+				SendMessage(conn, Message{Type: MsgTypeSystem, Text: truncatedWarning()})
+			}
+		}
 
 		switch msg.Type {
 		case MsgTypeMsg:
+			h.markActive(client)
+			if msg.Nonce != "" && h.redeliver(client, msg.Nonce) {
+				break // resent after a reconnect, already posted
+			}
 			// PlayBell()
-			if h.callbacks.OnMessageReceived != nil {
-				h.callbacks.OnMessageReceived(Message{Nick: client.nick, Text: msg.Text})
+			quoted := Message{ID: msg.ReplyTo, Nick: msg.ReplyNick, Text: msg.ReplyText}
+			h.postMessage(withReply(Message{Type: MsgTypeMsg, Nick: client.nick, Text: msg.Text, Nonce: msg.Nonce}, quoted), client)
+
+		case MsgTypeEdit, MsgTypeDelete:
+			if err := h.editMessage(client, msg); err != nil {
+				SendMessage(conn, Message{Type: MsgTypeSystem, Text: err.Error()})
 			}
-			h.broadcast(Message{Type: MsgTypeMsg, Nick: client.nick, Text: msg.Text}, nil)
+
+		case MsgTypePrivate:
+			h.markActive(client)
+			h.routePrivate(client, msg.Target, msg.Text)
+
+		case MsgTypeAway:
+			h.setClientAway(client, msg)
+
+		case MsgTypeHandoff:
+			h.finishHandoff(client, msg)
+
+		case MsgTypeReaction:
+			h.addReaction(client.nick, msg.ID, msg.Text)
 
 		case MsgTypeNick:
 			oldNick := client.nick
 			client.nick = msg.Text
-			sysMsg := fmt.Sprintf("%s is now known as %s", oldNick, client.nick)
+			sysMsg := i18n.T("room.nick", oldNick, client.nick)
 			if h.callbacks.OnSystemMessage != nil {
 				h.callbacks.OnSystemMessage(sysMsg)
 			}
 			if h.callbacks.OnUserList != nil {
 				h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
 			}
-			h.broadcast(Message{Type: MsgTypeSystem, Text: sysMsg}, conn)
+			// Observers are only known to the host, so the room hears nothing
+			if !client.observer {
+				h.broadcast(Message{Type: MsgTypeSystem, Text: sysMsg}, conn)
+				h.broadcast(Message{Type: MsgTypeUserRenamed, Nick: oldNick, Text: client.nick}, nil)
+			}
 
 		case MsgTypePing:
 			SendMessage(conn, Message{Type: MsgTypePong})
 
+		case MsgTypeStats:
+			SendMessage(conn, Message{Type: MsgTypeStats, Text: h.roomStats()})
+
 		case MsgTypeUserList:
 			users := h.getUserList()
 			SendMessage(conn, Message{Type: MsgTypeUserList, Text: users})
 
+		case MsgTypeVote:
+			n, _ := strconv.Atoi(msg.Text)
+			SendMessage(conn, Message{Type: MsgTypeSystem, Text: h.vote(client.nick, n)})
+
 		case MsgTypeFileOffer:
 			// Store the offer and forward to recipient(s)
-			offerMsg := Message{Type: MsgTypeFileOffer, Nick: client.nick, Text: msg.Text, Data: msg.Data}
+			addr := remoteIP(conn)
+			offerMsg := Message{Type: MsgTypeFileOffer, Nick: client.nick, Text: msg.Text, Data: msg.Data, Addr: addr}
 			// Store by sender nick only - any recipient can accept
+			h.mutex.Lock()
 			h.pendingOffers[client.nick] = &PendingOffer{
 				SenderNick:    client.nick,
 				SenderConn:    conn,
+				SenderAddr:    addr,
 				Filename:      msg.Text,
+				Size:          msg.Data,
 				RecipientNick: msg.Target, // may be empty for broadcast
 			}
+			h.mutex.Unlock()
 			if msg.Target != "" {
 				if msg.Target == h.nick {
 					// Targeted offer to host
-					h.hostPendingFile = &PendingOffer{
+					// PlayBell()
+					h.offerToHost(&PendingOffer{
 						SenderNick: client.nick,
 						SenderConn: conn,
+						SenderAddr: addr,
 						Filename:   msg.Text,
-					}
-					// PlayBell()
-					if h.callbacks.OnFileOffer != nil {
-						h.callbacks.OnFileOffer(*h.hostPendingFile)
-					}
+						Size:       msg.Data,
+					})
 				} else {
 					h.sendToNick(msg.Target, offerMsg)
 					if h.callbacks.OnSystemMessage != nil {
-						h.callbacks.OnSystemMessage(fmt.Sprintf("%s offers %s to %s", client.nick, msg.Text, msg.Target))
+						h.callbacks.OnSystemMessage(i18n.T("file.offerRelayed", client.nick, msg.Text, msg.Target))
 					}
 				}
 			} else {
 				// Broadcast offer to all clients
 				h.broadcast(offerMsg, conn)
 				// Also track for host
-				h.hostPendingFile = &PendingOffer{
+				// PlayBell()
+				h.offerToHost(&PendingOffer{
 					SenderNick: client.nick,
 					SenderConn: conn,
+					SenderAddr: addr,
 					Filename:   msg.Text,
-				}
-				// PlayBell()
-				if h.callbacks.OnFileOffer != nil {
-					h.callbacks.OnFileOffer(*h.hostPendingFile)
-				}
+					Size:       msg.Data,
+				})
 			}
 
+		case MsgTypeFileCancel:
+			h.withdrawOffer(client)
+
 		case MsgTypeFileAcc:
 			// Recipient accepted - tell sender to send the file
 			senderNick := msg.Text // msg.Text = sender nick they're accepting from
-			if offer, ok := h.pendingOffers[senderNick]; ok {
+			if offer := h.takePendingOffer(senderNick); offer != nil {
 				// Tell sender their offer was accepted, include who accepted
 				SendMessage(offer.SenderConn, Message{Type: MsgTypeFileAcc, Nick: client.nick, Text: offer.Filename})
-				delete(h.pendingOffers, senderNick)
 				if h.callbacks.OnSystemMessage != nil {
-					h.callbacks.OnSystemMessage(fmt.Sprintf("%s accepted file from %s", client.nick, senderNick))
+					h.callbacks.OnSystemMessage(i18n.T("file.acceptedRelayed", client.nick, senderNick))
 				}
 			}
 
 		case MsgTypeFileRej:
 			// Recipient rejected
 			senderNick := msg.Text
-			if offer, ok := h.pendingOffers[senderNick]; ok {
+			if offer := h.takePendingOffer(senderNick); offer != nil {
 				SendMessage(offer.SenderConn, Message{Type: MsgTypeFileRej, Nick: client.nick})
-				delete(h.pendingOffers, senderNick)
 				if h.callbacks.OnSystemMessage != nil {
-					h.callbacks.OnSystemMessage(fmt.Sprintf("%s rejected file from %s", client.nick, senderNick))
+					h.callbacks.OnSystemMessage(i18n.T("file.rejectedRelayed", client.nick, senderNick))
 				}
 			}
 
 		case MsgTypeFile:
-			// Actual file data - route to target or broadcast
-			fileMsg := Message{Type: MsgTypeFile, Nick: client.nick, Text: msg.Text, Data: msg.Data}
+			// Actual file data - route to target or broadcast. Relaying
+			// copies it for each recipient, so wait for a free slot first.
+			if !h.acquireTransfer(conn) {
+				continue
+			}
+			h.room.files.Add(1)
+			h.notifyWebhook(WebhookFile, client.nick, msg.Target, msg.Text)
+			fileMsg := Message{Type: MsgTypeFile, Nick: client.nick, Text: msg.Text, Data: msg.Data, Raw: msg.Raw, Sum: msg.Sum}
 			if msg.Target != "" {
 				if msg.Target == h.nick {
 					// Sent to host
 					// PlayBell()
-					hostSaveFile(msg.Text, msg.Data, client.nick)
-					if h.callbacks.OnFileReceived != nil {
-						h.callbacks.OnFileReceived(msg.Text, msg.Data, client.nick)
-					}
+					h.receiveFile(conn, msg, client.nick)
 				} else {
 					h.sendToNick(msg.Target, fileMsg)
 					if h.callbacks.OnSystemMessage != nil {
-						h.callbacks.OnSystemMessage(fmt.Sprintf("%s sent file %s to %s", client.nick, msg.Text, msg.Target))
+						h.callbacks.OnSystemMessage(i18n.T("file.sentRelayed", client.nick, msg.Text, msg.Target))
 					}
 				}
 			} else {
 				// PlayBell()
-				hostSaveFile(msg.Text, msg.Data, client.nick)
-				if h.callbacks.OnFileReceived != nil {
-					h.callbacks.OnFileReceived(msg.Text, msg.Data, client.nick)
-				}
+				h.receiveFile(conn, msg, client.nick)
 				h.broadcast(fileMsg, conn)
 				if h.callbacks.OnSystemMessage != nil {
-					h.callbacks.OnSystemMessage(fmt.Sprintf("%s shared file %s", client.nick, msg.Text))
+					h.callbacks.OnSystemMessage(i18n.T("file.shared", client.nick, msg.Text))
 				}
 			}
+			h.slots.release()
+
+		case MsgTypeFileBad:
+			// A recipient's copy failed verification - tell the sender
+			if msg.Target == h.nick {
+				if h.callbacks.OnSystemMessage != nil {
+					h.callbacks.OnSystemMessage(i18n.T("file.corrupted", client.nick, msg.Text))
+				}
+			} else {
+				h.sendToNick(msg.Target, Message{Type: MsgTypeFileBad, Nick: client.nick, Text: msg.Text})
+			}
 
 		case MsgTypeWebRTC:
 			// Route signal
 			if msg.Target == h.nick {
 				// For host
-				h.mediaManager.HandleSignal(client.nick, msg.Data)
+				if h.mediaManager == nil {
+					h.refuseCall(client.nick, msg.Data)
+				} else {
+					h.mediaManager.HandleSignal(client.nick, msg.Data)
+				}
 			} else {
 				// Forward to target
 				forwardMsg := Message{Type: MsgTypeWebRTC, Nick: client.nick, Data: msg.Data, Target: msg.Target}
@@ -297,44 +660,54 @@ func (h *Host) handleClient(conn net.Conn) {
 			}
 		}
 	}
-
-	// Client disconnected
-	h.mutex.Lock()
-	delete(h.clients, conn)
-	h.mutex.Unlock()
-	conn.Close()
-
-	// PlayBell()
-	sysMsg := fmt.Sprintf("%s left", client.nick)
-	if h.callbacks.OnSystemMessage != nil {
-		h.callbacks.OnSystemMessage(sysMsg)
-	}
-	if h.callbacks.OnUserList != nil {
-		h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
-	}
-	h.broadcast(Message{Type: MsgTypeSystem, Text: sysMsg}, nil)
+	return dropped
 }
 
-// broadcast sends a message to all connected clients
+// broadcast sends a message to all connected clients. A client whose write
+// fails is disconnected, since its stream may now hold half a message; its
+// handler then cleans up as for any lost connection.
 func (h *Host) broadcast(msg Message, exclude net.Conn) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	for conn := range h.clients {
+	packed := compressFile(msg)
+	for conn, client := range h.clients {
 		if conn != exclude {
-			SendMessage(conn, msg)
+			if err := client.send(msg, packed); err != nil {
+				logger.Warnf("Dropping %s: %v", client.nick, err)
+				conn.Close()
+			}
 		}
 	}
 }
 
+// send writes msg to the client, or its compressed form if the client accepts it
+func (c *Client) send(msg Message, packed Message) error {
+	if c.gzip {
+		msg = packed
+	}
+	return SendMessage(c.conn, msg)
+}
+
 // getUserList returns a comma-separated list of all connected users
 func (h *Host) getUserList() string {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	names := []string{h.nick + " (host)"}
+	host := h.nick + " (host)"
+	if h.away {
+		host += " (away)"
+	}
+	names := []string{host}
 	for _, client := range h.clients {
-		names = append(names, client.nick)
+		if client.observer {
+			continue
+		}
+		if client.away {
+			names = append(names, client.nick+" (away)")
+		} else {
+			names = append(names, client.nick)
+		}
 	}
 	return strings.Join(names, ", ")
 }
@@ -346,59 +719,168 @@ func (h *Host) sendToNick(nick string, msg Message) bool {
 
 	for _, client := range h.clients {
 		if client.nick == nick {
-			SendMessage(client.conn, msg)
+			if client.gzip {
+				msg = compressFile(msg)
+			}
+			if err := SendMessage(client.conn, msg); err != nil {
+				logger.Warnf("Dropping %s: %v", client.nick, err)
+				client.conn.Close()
+			}
 			return true
 		}
 	}
 	return false
 }
 
-// hostSaveFile saves a received file (host version - uses same logic as client)
-func hostSaveFile(filename string, data string, from string) {
-	decoded, err := base64.StdEncoding.DecodeString(data)
-	if err != nil {
-		fmt.Printf("Error decoding file: %v\n", err)
-		return
+// whois describes a connected user's address, session times and away status
+func (h *Host) whois(nick string) string {
+	addr := h.Address()
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if nick == h.nick {
+		return i18n.T("whois.self", nick, addr) + whoisAway(h.away, h.awayText)
 	}
-	safeName := filepath.Base(filename)
-	err = os.WriteFile(safeName, decoded, 0644)
-	if err != nil {
-		fmt.Printf("Error saving file: %v\n", err)
+	for _, client := range h.clients {
+		if client.nick == nick {
+			return i18n.T("whois.client", nick,
+				client.conn.RemoteAddr(),
+				time.Since(client.joinedAt).Round(time.Second),
+				client.joinedAt.Format("15:04:05"),
+				time.Since(client.lastActive).Round(time.Second)) + whoisAway(client.away, client.awayText)
+		}
+	}
+	return i18n.T("user.notFound", nick)
+}
+
+// whoisAway is the /whois line for an away status, empty while present
+func whoisAway(away bool, text string) string {
+	if !away {
+		return ""
+	}
+	return "\n" + i18n.T("whois.away", text)
+}
+
+// offerToHost presents a file offer to the host, accepting it straight away
+// when the sender is in Settings.AutoAcceptFrom
+func (h *Host) offerToHost(offer *PendingOffer) {
+	if IsTrusted(offer.SenderNick, offer.SenderAddr) {
+		SendMessage(offer.SenderConn, Message{Type: MsgTypeFileAcc, Nick: h.nick, Text: offer.Filename})
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("file.autoAccepted", offer.Filename, offer.SenderNick))
+		}
 		return
 	}
-	fmt.Printf("-> Received %s from %s (%d bytes)\n", safeName, from, len(decoded))
+
+	h.mutex.Lock()
+	h.hostOffers = append(h.hostOffers, offer)
+	h.mutex.Unlock()
+	if h.callbacks.OnFileOffer != nil {
+		h.callbacks.OnFileOffer(*offer)
+	}
+}
+
+// takePendingOffer removes and returns the offer sender made to the room,
+// or nil when there is none
+func (h *Host) takePendingOffer(sender string) *PendingOffer {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	offer := h.pendingOffers[sender]
+	delete(h.pendingOffers, sender)
+	return offer
+}
+
+// takeHostOffer removes and returns the host's oldest pending offer from
+// sender, or the oldest overall when sender is empty
+func (h *Host) takeHostOffer(sender string) *PendingOffer {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, offer := range h.hostOffers {
+		if sender == "" || offer.SenderNick == sender {
+			h.hostOffers = append(h.hostOffers[:i], h.hostOffers[i+1:]...)
+			return offer
+		}
+	}
+	return nil
+}
+
+// acquireTransfer waits for a free transfer slot, telling whoever sent the
+// file (conn, or the host's own UI when nil) that it is queued. It returns
+// false if the room shuts down first.
+func (h *Host) acquireTransfer(conn net.Conn) bool {
+	return h.slots.acquire(h.ctx.Done(), func() {
+		if conn != nil {
+			SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("file.queued")})
+		} else if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("file.queued"))
+		}
+	})
+}
+
+// receiveFile hands a file sent to the host to the UI and reports corruption back to the sender
+func (h *Host) receiveFile(senderConn net.Conn, msg Message, from string) {
+	filename := msg.Text
+	decoded, err := decodeFile(msg)
+	if errors.Is(err, ErrChecksumMismatch) {
+		SendMessage(senderConn, Message{Type: MsgTypeFileBad, Nick: h.nick, Text: filename})
+	}
+	if err == nil {
+		h.stats.filesReceived.Add(1)
+	}
+	if h.callbacks.OnFileReceived != nil {
+		h.callbacks.OnFileReceived(filename, decoded, from, err)
+	} else if err == nil {
+		if path, err := SaveFile(filename, decoded); err == nil {
+			logger.Infof("Received %s from %s (%s)", path, from, FormatSize(int64(len(decoded))))
+		}
+	}
 }
 
 // hostSendFile sends a file from the host to clients
 func (h *Host) hostSendFile(path string, target string) {
-	data, err := os.ReadFile(path)
+	name, data, err := readForSending(path)
 	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
+		logger.Errorf("Reading file: %v", err)
 		return
 	}
-	if len(data) > 5*1024*1024 {
-		fmt.Println("File too large (max 5MB)")
+	h.sendFileData(name, data, target)
+}
+
+// sendFileData sends file contents from the host to clients
+func (h *Host) sendFileData(filename string, data []byte, target string) {
+	if len(data) > MaxFileSize {
+		logger.Errorf("File too large (max %s)", FormatSize(MaxFileSize))
 		return
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(data)
-	filename := filepath.Base(path)
-	msg := Message{Type: MsgTypeFile, Nick: h.nick, Text: filename, Data: encoded}
+	if !h.acquireTransfer(nil) {
+		return
+	}
+	defer h.slots.release()
+
+	msg := Message{Type: MsgTypeFile, Nick: h.nick, Text: filename, Raw: data, Sum: fileChecksum(data)}
 
 	if target != "" {
 		if h.sendToNick(target, msg) {
+			h.stats.filesSent.Add(1)
+			h.room.files.Add(1)
+			h.notifyWebhook(WebhookFile, h.nick, target, filename)
 			if h.callbacks.OnSystemMessage != nil {
-				h.callbacks.OnSystemMessage(fmt.Sprintf("Sent %s to %s (%d bytes)", filename, target, len(data)))
+				h.callbacks.OnSystemMessage(i18n.T("file.sentTo", filename, target, FormatSize(int64(len(data)))))
 			}
 		} else {
 			if h.callbacks.OnSystemMessage != nil {
-				h.callbacks.OnSystemMessage(fmt.Sprintf("User %s not found", target))
+				h.callbacks.OnSystemMessage(i18n.T("user.notFound", target))
 			}
 		}
 	} else {
 		h.broadcast(msg, nil)
+		h.stats.filesSent.Add(1)
+		h.room.files.Add(1)
+		h.notifyWebhook(WebhookFile, h.nick, "", filename)
 		if h.callbacks.OnSystemMessage != nil {
-			h.callbacks.OnSystemMessage(fmt.Sprintf("Sent %s to everyone (%d bytes)", filename, len(data)))
+			h.callbacks.OnSystemMessage(i18n.T("file.sentAll", filename, FormatSize(int64(len(data)))))
 		}
 	}
 }
@@ -426,56 +908,209 @@ func (h *Host) SendText(text string) (string, error) {
 		if result.NickChange != "" {
 			oldNick := h.nick
 			h.nick = result.NickChange
-			sysMsg := fmt.Sprintf("%s is now known as %s", oldNick, h.nick)
+			sysMsg := i18n.T("room.nick", oldNick, h.nick)
 			h.broadcast(Message{Type: MsgTypeSystem, Text: sysMsg}, nil)
-			// Trigger local callback for system message?
-			// Actually UI should just update.
+			h.broadcast(Message{Type: MsgTypeUserRenamed, Nick: oldNick, Text: h.nick}, nil)
+			if h.callbacks.OnNickChanged != nil {
+				h.callbacks.OnNickChanged(h.nick)
+			}
+		}
+		if result.ClearScreen {
+			if h.callbacks.OnClear != nil {
+				h.callbacks.OnClear()
+			} else {
+				output += clearScreenANSI
+			}
+		}
+		if result.Export {
+			if h.callbacks.OnExport != nil {
+				h.callbacks.OnExport(result.ExportPath)
+			} else {
+				output += i18n.T("cmd.noExport") + "\n"
+			}
 		}
 		if result.RequestUsers {
 			if h.callbacks.OnSystemMessage != nil {
-				h.callbacks.OnSystemMessage(fmt.Sprintf("Online: %s", h.getUserList()))
+				h.callbacks.OnSystemMessage(i18n.T("room.online", h.getUserList()))
+			}
+		}
+		if result.Whois != "" {
+			output += h.whois(result.Whois) + "\n"
+		}
+		if result.Away {
+			h.SetAway(true, result.AwayText)
+		}
+		if result.Back {
+			if h.Away() {
+				h.SetAway(false, "")
+			} else {
+				output += i18n.T("cmd.notAway") + "\n"
 			}
 		}
+		if result.PrivateTo != "" {
+			output += h.sendPrivate(result.PrivateTo, result.PrivateText)
+		}
+		if result.Reply != "" {
+			output += h.replyPrivate(result.Reply)
+		}
+		if result.Invite != "" {
+			invite(result.Invite, h.nick, h.JoinURL(), h.callbacks.OnSystemMessage)
+			output += i18n.T("invite.looking", result.Invite) + "\n"
+		}
+		if result.StartPoll != nil {
+			output += h.startPoll(result.StartPoll)
+		}
+		if result.ClosePoll {
+			output += h.closePoll()
+		}
+		if result.Handoff != "" {
+			output += h.startHandoff(result.Handoff)
+		}
+		if result.Ban != "" {
+			output += h.ban(result.Ban)
+		}
+		if result.BanList {
+			output += h.banList()
+		}
+		if result.KickAll {
+			output += h.kickAll()
+		}
+		if result.Unban != "" {
+			output += h.unban(result.Unban)
+		}
+		if result.Alias != nil {
+			output += setAlias(*result.Alias, h.callbacks.OnAliasesChanged)
+		}
+		if result.AliasList {
+			output += aliasList()
+		}
+		if result.SaveDir != "" {
+			output += setSaveDir(result.SaveDir, h.callbacks.OnSaveDirChanged)
+		}
+		if result.ShowSaveDir {
+			output += saveDirInfo()
+		}
+		if result.SetTopic {
+			h.setTopic(result.Topic)
+		}
+		if result.Motd != "" {
+			output += h.setMotd(result.Motd)
+		}
+		if result.ShowMotd {
+			output += h.motdInfo()
+		}
+		if result.Vote > 0 {
+			output += h.vote(h.nick, result.Vote) + "\n"
+		}
 		if result.FileSend != nil {
 			h.hostSendFile(result.FileSend.Path, result.FileSend.Target)
-			output += fmt.Sprintf("Sending file: %s\n", result.FileSend.Path)
+			output += i18n.T("file.sending", result.FileSend.Path) + "\n"
 		}
 		if result.AcceptFile {
-			if h.hostPendingFile != nil {
-				SendMessage(h.hostPendingFile.SenderConn, Message{Type: MsgTypeFileAcc, Nick: h.nick, Text: h.hostPendingFile.Filename})
-				output += fmt.Sprintf("Accepted file from %s\n", h.hostPendingFile.SenderNick)
-				h.hostPendingFile = nil
+			if offer := h.takeHostOffer(result.FileFrom); offer != nil {
+				SendMessage(offer.SenderConn, Message{Type: MsgTypeFileAcc, Nick: h.nick, Text: offer.Filename})
+				output += i18n.T("file.acceptedFrom", offer.SenderNick) + "\n"
 			} else {
-				output += "No pending file to accept\n"
+				output += i18n.T("file.noneToAccept") + "\n"
 			}
 		}
+		if result.Stats {
+			output += h.hostStats()
+		}
+		if result.Transfers {
+			output += transferList(h.Transfers())
+		}
+		if result.CancelTransfer > 0 {
+			output += h.cancelTransfer(result.CancelTransfer)
+		}
 		if result.RejectFile {
-			if h.hostPendingFile != nil {
-				SendMessage(h.hostPendingFile.SenderConn, Message{Type: MsgTypeFileRej, Nick: h.nick})
-				output += fmt.Sprintf("Rejected file from %s\n", h.hostPendingFile.SenderNick)
-				h.hostPendingFile = nil
+			if offer := h.takeHostOffer(result.FileFrom); offer != nil {
+				SendMessage(offer.SenderConn, Message{Type: MsgTypeFileRej, Nick: h.nick})
+				output += i18n.T("file.rejectedFrom", offer.SenderNick) + "\n"
 			} else {
-				output += "No pending file to reject\n"
+				output += i18n.T("file.noneToReject") + "\n"
 			}
 		}
 		if result.Message != nil {
-			h.broadcast(*result.Message, nil)
+			h.postMessage(*result.Message, nil)
+		}
+		if result.Edit != "" || result.Delete {
+			id, err := h.lastOwnMessage()
+			if err == nil && result.Delete {
+				err = h.DeleteMessage(id)
+			} else if err == nil {
+				err = h.EditMessage(id, result.Edit)
+			}
+			if err != nil {
+				output += i18n.T("error", err) + "\n"
+			}
+		}
+		if result.React != "" {
+			h.mutex.RLock()
+			id := h.nextMsgID
+			h.mutex.RUnlock()
+			if id > 0 {
+				h.addReaction(h.nick, strconv.Itoa(id), result.React)
+			} else {
+				output += i18n.T("cmd.noReactTarget") + "\n"
+			}
+		}
+		if result.Replay {
+			output += saveReplay()
+		}
+		if result.Record != "" {
+			output += record(h.mediaManager, result.Record)
 		}
 		if result.StartCall != "" {
-			h.mediaManager.StartCall(result.StartCall)
-			output += fmt.Sprintf("Calling %s...\n", result.StartCall)
+			if err := h.mediaManager.StartCall(result.StartCall); err != nil {
+				output += i18n.T("call.failed", result.StartCall, err) + "\n"
+			} else {
+				output += i18n.T("call.calling", result.StartCall) + "\n"
+			}
 		}
 		if result.StartShare != "" {
-			h.mediaManager.StartShare(result.StartShare)
-			output += fmt.Sprintf("Sharing screen with %s...\n", result.StartShare)
+			if err := h.mediaManager.StartShare(result.StartShare, 0); err != nil {
+				output += i18n.T("share.failed", result.StartShare, err) + "\n"
+			} else {
+				output += i18n.T("share.sharing", result.StartShare) + "\n"
+			}
 		}
 		return output, nil
 	}
 
 	// Regular message
-	msg := Message{Type: MsgTypeMsg, Nick: h.nick, Text: text}
-	h.broadcast(msg, nil)
-	return "", nil
+	text, cut := TruncateMessage(text)
+	h.postMessage(Message{Type: MsgTypeMsg, Nick: h.nick, Text: text}, nil)
+	if cut {
+		output = truncatedWarning() + "\n"
+	}
+	return output, nil
+}
+
+// sendSignal sends WebRTC signaling data to the client called target
+func (h *Host) sendSignal(target string, data string) {
+	msg := Message{
+		Type:   MsgTypeWebRTC,
+		Nick:   h.nick,
+		Text:   "signal",
+		Data:   data,
+		Target: target,
+	}
+	if target != "" {
+		h.sendToNick(target, msg)
+	}
+}
+
+// refuseCall declines a call offer when there is no media manager
+func (h *Host) refuseCall(from string, data string) {
+	reply := media.RefuseSignal(data)
+	if reply == "" {
+		return
+	}
+	h.sendSignal(from, reply)
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(i18n.T("call.missed", from, media.ErrUnavailable))
+	}
 }
 
 // OfferFile is called by UI
@@ -483,22 +1118,93 @@ func (h *Host) OfferFile(path string, target string) {
 	h.hostSendFile(path, target)
 }
 
+// OfferBytes sends in-memory data, such as a pasted image, as a file named name
+func (h *Host) OfferBytes(name string, data []byte, target string) {
+	h.sendFileData(name, data, target)
+}
+
 // Shutdown closes the host
 func (h *Host) Shutdown() {
+	// Deregister first; Shutdown sends mDNS goodbye packets so resolvers
+	// drop the record rather than caching it past a quick restart
+	if h.unadvertise != nil {
+		h.unadvertise()
+		h.unadvertise = nil
+	}
 	if h.listener != nil {
 		h.listener.Close()
+		forgetHosted(h.config.Port)
 	}
 	if h.mediaManager != nil {
 		h.mediaManager.Stop()
 	}
-	if h.mdnsServer != nil {
-		h.mdnsServer.Shutdown()
-	}
 
-	h.mutex.Lock()
+	h.cancel()
+
+	// Send the notice and half-close so it is flushed before the client sees EOF
+	h.mutex.RLock()
 	for conn := range h.clients {
-		SendMessage(conn, Message{Type: MsgTypeSystem, Text: "Room closed by host"})
-		conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(shutdownGrace))
+		SendMessage(conn, Message{Type: MsgTypeSystem, Text: i18n.T("room.closed")})
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite() // TCP or TLS
+		}
 	}
-	h.mutex.Unlock()
+	h.mutex.RUnlock()
+
+	// Client loops exit as clients hang up; force-close whoever is left after the grace period
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownGrace):
+		h.mutex.Lock()
+		for conn := range h.clients {
+			conn.Close()
+		}
+		h.mutex.Unlock()
+	}
+}
+
+// ErrPortInUse is returned by Start when the room's port is taken, e.g. by
+// another room on this machine
+var ErrPortInUse = errors.New("port already in use")
+
+// listen binds port. Port 0, or a taken port with Settings.AutoPort, lets
+// the OS pick a free port. It returns the port actually bound, so adverts
+// and links use the real one.
+func listen(transport Transport, port int) (net.Listener, int, error) {
+	listener, err := transport.Listen(fmt.Sprintf(":%d", port))
+	if err != nil && isAddrInUse(err) && Settings.AutoPort {
+		logger.Infof("Port %d is in use, letting the OS pick one", port)
+		listener, err = transport.Listen(":0")
+	}
+	if err != nil {
+		if isAddrInUse(err) && !errors.Is(err, ErrPortInUse) {
+			return nil, 0, fmt.Errorf("%w: %d", ErrPortInUse, port)
+		}
+		return nil, 0, err
+	}
+	if _, bound, err := net.SplitHostPort(listener.Addr().String()); err == nil {
+		port, _ = strconv.Atoi(bound)
+	}
+	return listener, port, nil
 }
+
+// isAddrInUse reports whether a listen error means the port is taken
+func isAddrInUse(err error) bool {
+	if errors.Is(err, ErrPortInUse) { // PipeTransport's
+		return true
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.EADDRINUSE || errno == wsaeAddrInUse
+}
+
+// wsaeAddrInUse is Windows' WSAEADDRINUSE, which syscall only defines there
+const wsaeAddrInUse = syscall.Errno(10048)
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+func TestWhois(t *testing.T) {
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	if got := h.whois("alice"); !strings.HasPrefix(got, "alice is connected from "+PipeHost+":") || strings.Contains(got, "Away") {
+		t.Errorf("whois alice = %q, want her address and no away status", got)
+	}
+
+	alice.SendText("/afk lunch")
+	eventually(t, "the host sees alice away", func() bool {
+		return strings.HasSuffix(h.whois("alice"), "\n  Away: lunch")
+	})
+
+	h.SetAway(true, "meeting")
+	want := "host is you, hosting on " + h.Address() + "\n  Away: meeting"
+	if got := h.whois("host"); got != want {
+		t.Errorf("whois host = %q, want %q", got, want)
+	}
+	if got := h.whois("bob"); got != "User bob not found" {
+		t.Errorf("whois bob = %q, want not found", got)
+	}
+}
+
+func TestMessageBeforeJoinIsRefused(t *testing.T) {
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	conn, err := transport.Dial(h.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(testTimeout))
+
+	if err := SendMessage(conn, Message{Type: MsgTypeMsg, Nick: "alice", Text: "hello"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	msg, err := ReadMessage(reader)
+	if err != nil {
+		t.Fatalf("reading the reply: %v", err)
+	}
+	if msg.Type != MsgTypeSystem || msg.Text != i18n.T("join.notJoined") {
+		t.Errorf("reply %+v, want the not-joined notice", msg)
+	}
+	if _, err := ReadMessage(reader); err != io.EOF {
+		t.Errorf("after the notice: err = %v, want the connection closed", err)
+	}
+}
+
+func TestFullRoomRefusesTheNextClient(t *testing.T) {
+	withSetting(t, &Settings.MaxClients, 1)
+	hostSystem, onHostSystem := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnSystemMessage: onHostSystem})
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	bobSystem, onBobSystem := collect[string]()
+	joinTestRoom(t, transport, h, "bob", ClientCallbacks{OnSystemMessage: onBobSystem, OnUserList: onUsers})
+	receiveUntil(t, bobSystem, textIs(i18n.T("join.full", 1)))
+	receiveUntil(t, hostSystem, textIs(i18n.T("join.fullNotice", "bob")))
+
+	h.mutex.Lock()
+	joined := len(h.clients)
+	h.mutex.Unlock()
+	if joined != 1 {
+		t.Errorf("%d clients joined, want only alice", joined)
+	}
+}
+
+func TestNickChangeRenamesOwnMessages(t *testing.T) {
+	hostMsgs, onHostMsg := collect[Message]()
+	hostNicks, onHostNick := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg, OnNickChanged: onHostNick})
+	users, onUsers := collect[[]string]()
+	aliceMsgs, onAliceMsg := collect[Message]()
+	aliceNicks, onAliceNick := collect[string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers, OnMessageReceived: onAliceMsg, OnNickChanged: onAliceNick})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	alice.SendText("/nick alicia")
+	if nick := receive(t, aliceNicks); nick != "alicia" {
+		t.Errorf("alice's OnNickChanged got %q, want alicia", nick)
+	}
+	receiveUntil(t, users, func(users []string) bool { return slices.Contains(users, "alicia") })
+	alice.SendText("hi")
+	if msg := receive(t, aliceMsgs); msg.Nick != "alicia" {
+		t.Errorf("alice's message came back from %q, want alicia", msg.Nick)
+	}
+	receive(t, hostMsgs)
+
+	h.SendText("/nick boss")
+	if nick := receive(t, hostNicks); nick != "boss" {
+		t.Errorf("host's OnNickChanged got %q, want boss", nick)
+	}
+	h.SendText("welcome")
+	if msg := receive(t, hostMsgs); msg.Nick != "boss" {
+		t.Errorf("host's message came back from %q, want boss", msg.Nick)
+	}
+	if msg := receive(t, aliceMsgs); msg.Nick != "boss" {
+		t.Errorf("alice got the host's message from %q, want boss", msg.Nick)
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// idleWarning is how long before an idle close or kick the room, or the
+// idle client, is warned
+const idleWarning = time.Minute
+
+// idleCheckInterval is how often the host looks for idleness, or a quarter
+// of Settings.IdleTimeout when that's shorter, but no more often than
+// minIdleCheckInterval
+const (
+	idleCheckInterval    = 5 * time.Second
+	minIdleCheckInterval = 10 * time.Millisecond
+)
+
+// markActive records that client said something
+func (h *Host) markActive(client *Client) {
+	h.mutex.Lock()
+	client.lastActive = time.Now()
+	h.mutex.Unlock()
+}
+
+// touchActivity records that something happened in the room, postponing
+// Settings.IdleTimeout
+func (h *Host) touchActivity() {
+	h.mutex.Lock()
+	h.lastActivity = time.Now()
+	h.mutex.Unlock()
+}
+
+// watchIdle enforces Settings.IdleTimeout: it closes the room once nobody has
+// said anything for that long, or with Settings.IdleKick disconnects each
+// client that hasn't. Either way a warning goes out idleWarning beforehand.
+func (h *Host) watchIdle() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(max(minIdleCheckInterval, min(idleCheckInterval, Settings.IdleTimeout/4)))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if Settings.IdleKick {
+			h.kickIdleClients()
+			continue
+		}
+		if h.checkIdleRoom() {
+			// Shutdown waits for this goroutine, so it can't run here
+			go h.closeIdleRoom()
+			return
+		}
+	}
+}
+
+// idleDeadlines returns when to warn and when to act for something last
+// active at since
+func idleDeadlines(since time.Time) (warn time.Time, act time.Time) {
+	act = since.Add(Settings.IdleTimeout)
+	return act.Add(-min(idleWarning, Settings.IdleTimeout/2)), act
+}
+
+// checkIdleRoom warns the room as it nears the timeout, reporting true once
+// it's reached
+func (h *Host) checkIdleRoom() bool {
+	h.mutex.Lock()
+	warnAt, closeAt := idleDeadlines(h.lastActivity)
+	now := time.Now()
+	warn := now.After(warnAt) && !h.idleWarnedAt.After(h.lastActivity)
+	if warn {
+		h.idleWarnedAt = now
+	}
+	h.mutex.Unlock()
+
+	if now.After(closeAt) {
+		return true
+	}
+	if warn {
+		text := i18n.T("idle.roomWarning", closeAt.Sub(now).Round(time.Second))
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(text)
+		}
+		h.broadcast(Message{Type: MsgTypeSystem, Text: text}, nil)
+	}
+	return false
+}
+
+// closeIdleRoom shuts the room down after Settings.IdleTimeout
+func (h *Host) closeIdleRoom() {
+	reason := i18n.T("idle.roomClosed", Settings.IdleTimeout)
+	h.Shutdown()
+	if h.callbacks.OnClosed != nil {
+		h.callbacks.OnClosed(reason)
+	}
+}
+
+// kickIdleClients warns clients nearing Settings.IdleTimeout without a
+// message and disconnects those past it
+func (h *Host) kickIdleClients() {
+	now := time.Now()
+	var warned, kicked []*Client
+	h.mutex.Lock()
+	for _, client := range h.clients {
+		warnAt, kickAt := idleDeadlines(client.lastActive)
+		switch {
+		case now.After(kickAt):
+			client.kicked.Store(true)
+			kicked = append(kicked, client)
+		case now.After(warnAt) && !client.idleWarnedAt.After(client.lastActive):
+			client.idleWarnedAt = now
+			warned = append(warned, client)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, client := range warned {
+		SendMessage(client.conn, Message{Type: MsgTypeSystem, Text: i18n.T("idle.kickWarning", min(idleWarning, Settings.IdleTimeout/2).Round(time.Second))})
+	}
+	for _, client := range kicked {
+		if h.callbacks.OnSystemMessage != nil {
+			h.callbacks.OnSystemMessage(i18n.T("idle.kickedNotice", client.nick))
+		}
+		SendMessage(client.conn, Message{Type: MsgTypeSystem, Text: i18n.T("idle.kicked", Settings.IdleTimeout)})
+		client.conn.Close()
+	}
+}
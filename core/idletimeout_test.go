@@ -0,0 +1,80 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+func TestIdleRoomIsWarnedThenClosed(t *testing.T) {
+	withSetting(t, &Settings.IdleTimeout, 300*time.Millisecond)
+	withSetting(t, &Settings.IdleKick, false)
+	system, onSystem := collect[string]()
+	closed, onClosed := collect[string]()
+	startTestRoom(t, "host", HostCallbacks{OnSystemMessage: onSystem, OnClosed: onClosed})
+
+	receiveUntil(t, system, func(text string) bool { return text == i18n.T("idle.roomWarning", 0*time.Second) })
+	if reason := receive(t, closed); reason != i18n.T("idle.roomClosed", Settings.IdleTimeout) {
+		t.Errorf("room closed with %q, want the idle reason", reason)
+	}
+}
+
+func TestIdleClientIsWarnedThenKicked(t *testing.T) {
+	withSetting(t, &Settings.IdleTimeout, 300*time.Millisecond)
+	withSetting(t, &Settings.IdleKick, true)
+	hostSystem, onHostSystem := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnSystemMessage: onHostSystem})
+	system, onSystem := collect[string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnSystemMessage: onSystem})
+
+	receiveUntil(t, system, textIs(i18n.T("idle.kickWarning", 0*time.Second)))
+	receiveUntil(t, system, textIs(i18n.T("idle.kicked", Settings.IdleTimeout)))
+	receiveUntil(t, hostSystem, textIs(i18n.T("idle.kickedNotice", "alice")))
+}
+
+func TestTinyIdleTimeout(t *testing.T) {
+	withSetting(t, &Settings.IdleTimeout, time.Nanosecond)
+	withSetting(t, &Settings.IdleKick, false)
+	closed, onClosed := collect[string]()
+	startTestRoom(t, "host", HostCallbacks{OnClosed: onClosed})
+	receive(t, closed)
+}
+
+func TestMessageResetsTheRoomsIdleTimer(t *testing.T) {
+	withSetting(t, &Settings.IdleTimeout, 400*time.Millisecond)
+	withSetting(t, &Settings.IdleKick, false)
+	hostMsgs, onHostMsg := collect[Message]()
+	closed, onClosed := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg, OnClosed: onClosed})
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{})
+
+	time.Sleep(Settings.IdleTimeout * 3 / 4)
+	sent := time.Now()
+	alice.SendText("still here")
+	receive(t, hostMsgs)
+
+	receive(t, closed)
+	if idle := time.Since(sent); idle < Settings.IdleTimeout {
+		t.Errorf("room closed %v after the last message, want at least %v", idle, Settings.IdleTimeout)
+	}
+}
+
+func TestMessageResetsAClientsIdleTimer(t *testing.T) {
+	withSetting(t, &Settings.IdleTimeout, 400*time.Millisecond)
+	withSetting(t, &Settings.IdleKick, true)
+	hostMsgs, onHostMsg := collect[Message]()
+	hostSystem, onHostSystem := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg, OnSystemMessage: onHostSystem})
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{})
+
+	time.Sleep(Settings.IdleTimeout * 3 / 4)
+	sent := time.Now()
+	alice.SendText("still here")
+	receive(t, hostMsgs)
+
+	receiveUntil(t, hostSystem, textIs(i18n.T("idle.kickedNotice", "alice")))
+	if idle := time.Since(sent); idle < Settings.IdleTimeout {
+		t.Errorf("alice was kicked %v after her last message, want at least %v", idle, Settings.IdleTimeout)
+	}
+}
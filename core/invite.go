@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+
+	"cabinchat/logger"
+
+	"cabinchat/i18n"
+)
+
+// Invite asks an idle instance to join a room
+type Invite struct {
+	From string // inviter's nick
+	Link string // cabinchat:// link to the room
+}
+
+// Presence advertises an idle instance and listens for invites to rooms
+type Presence struct {
+	listener   net.Listener
+	mdnsServer *zeroconf.Server
+}
+
+// StartPresence advertises this instance as idle under nick and calls
+// onInvite, from a network goroutine, for each invite received
+func StartPresence(nick string, onInvite func(invite Invite)) (*Presence, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("listening for invites: %w", err)
+	}
+	server, err := advertisePresence(nick, listener.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("advertising presence: %w", err)
+	}
+
+	p := &Presence{listener: listener, mdnsServer: server}
+	go p.acceptLoop(onInvite)
+	return p, nil
+}
+
+// acceptLoop reads one invite per connection until the listener is closed
+func (p *Presence) acceptLoop(onInvite func(invite Invite)) {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			msg, err := ReadMessage(bufio.NewReader(conn))
+			if err != nil || msg.Type != MsgTypeInvite {
+				return
+			}
+			if _, err := ParseJoinAddress(msg.Text); err != nil {
+				logger.Warnf("Ignoring invite from %s: %v", msg.Nick, err)
+				return
+			}
+			onInvite(Invite{From: msg.Nick, Link: msg.Text})
+		}()
+	}
+}
+
+// Stop withdraws the advertisement and stops listening for invites
+func (p *Presence) Stop() {
+	p.mdnsServer.Shutdown()
+	p.listener.Close()
+}
+
+// SendInvite invites the idle instance advertised under name, matching its
+// nick or machine name, to the room at link
+func SendInvite(name string, from string, link string) error {
+	peers, err := FindIdlePeers()
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		if !strings.EqualFold(peer.Nick, name) && !strings.EqualFold(peer.Hostname, name) {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", peer.Address(), 2*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return SendMessage(conn, Message{Type: MsgTypeInvite, Nick: from, Text: link})
+	}
+	return fmt.Errorf("no idle CabinChat found for %s", name)
+}
+
+// invite sends an invite in the background, reporting the outcome through report
+func invite(name string, from string, link string, report func(text string)) {
+	go func() {
+		text := i18n.T("invite.sent", name)
+		if err := SendInvite(name, from, link); err != nil {
+			text = i18n.T("invite.failed", name, err)
+		}
+		if report != nil {
+			report(text)
+		}
+	}()
+}
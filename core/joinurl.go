@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JoinScheme is the URL scheme of shareable room links
+const JoinScheme = "cabinchat"
+
+// JoinURL builds a cabinchat://host:port link to a room, with its name if
+// known and, for TLS rooms, the certificate fingerprint to pin
+func JoinURL(room DiscoveredRoom) string {
+	u := url.URL{Scheme: JoinScheme, Host: room.Address()}
+	query := url.Values{}
+	if room.Name != "" {
+		query.Set("room", room.Name)
+	}
+	if room.TLS {
+		query.Set("tls", "1")
+		if room.Fingerprint != "" {
+			query.Set("fp", room.Fingerprint)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// ParseJoinAddress reads a cabinchat:// link, host:port or bare host (using
+// Settings.Port) into the room to connect to
+func ParseJoinAddress(s string) (DiscoveredRoom, error) {
+	s = strings.TrimSpace(s)
+	room := DiscoveredRoom{}
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme != JoinScheme || u.Host == "" {
+			return room, fmt.Errorf("invalid room link %q", s)
+		}
+		query := u.Query()
+		room.Name = query.Get("room")
+		room.Fingerprint = query.Get("fp")
+		room.TLS = query.Get("tls") == "1" || room.Fingerprint != ""
+		s = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		// No port given
+		host, portStr = strings.Trim(s, "[]"), strconv.Itoa(Settings.Port)
+	}
+	port, err := strconv.Atoi(portStr)
+	if host == "" || err != nil || port < 1 || port > 65535 {
+		return room, fmt.Errorf("invalid room address %q", s)
+	}
+
+	room.Host, room.Port = host, port
+	return room, nil
+}
+
+// JoinURL returns a link others can use to join the hosted room
+func (h *Host) JoinURL() string {
+	room := DiscoveredRoom{Name: h.Name(), TLS: h.fingerprint != "", Fingerprint: h.fingerprint}
+	host, port, _ := net.SplitHostPort(h.Address())
+	room.Host = host
+	room.Port, _ = strconv.Atoi(port)
+	return JoinURL(room)
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// kickAll disconnects every client with a notice but keeps the room open,
+// listener and advert included, so they can join again. It returns local
+// output.
+func (h *Host) kickAll() string {
+	h.mutex.Lock()
+	clients := make([]*Client, 0, len(h.clients))
+	for conn, client := range h.clients {
+		client.kicked.Store(true)
+		clients = append(clients, client)
+		delete(h.clients, conn) // so handleClient doesn't announce each one leaving
+	}
+	clear(h.pendingOffers)
+	h.hostOffers = nil
+	h.mutex.Unlock()
+
+	for _, client := range clients {
+		// Refused rather than a plain notice, so clients don't reconnect straight back
+		SendMessage(client.conn, Message{Type: MsgTypeRefused, Text: i18n.T("room.reset")})
+		client.conn.Close()
+	}
+
+	if h.callbacks.OnUserList != nil {
+		h.callbacks.OnUserList(strings.Split(h.getUserList(), ", "))
+	}
+	return i18n.T("room.resetNotice", len(clients)) + "\n"
+}
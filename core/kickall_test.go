@@ -0,0 +1,39 @@
+package core
+
+import (
+	"testing"
+
+	"cabinchat/i18n"
+)
+
+func TestKickAllKeepsTheRoomUp(t *testing.T) {
+	hostUsers, onHostUsers := collect[[]string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnUserList: onHostUsers})
+	var lost []chan struct{}
+	var notices []chan string
+	for _, nick := range []string{"alice", "bob"} {
+		gone := make(chan struct{}, 1)
+		system, onSystem := collect[string]()
+		joinTestRoom(t, transport, h, nick, ClientCallbacks{
+			OnSystemMessage:  onSystem,
+			OnConnectionLost: func() { gone <- struct{}{} },
+		})
+		lost = append(lost, gone)
+		notices = append(notices, system)
+	}
+	receiveUntil(t, hostUsers, func(users []string) bool { return len(users) == 3 })
+
+	if got, want := h.kickAll(), i18n.T("room.resetNotice", 2)+"\n"; got != want {
+		t.Errorf("kickAll() = %q, want %q", got, want)
+	}
+	for i := range lost {
+		receiveUntil(t, notices[i], textIs(i18n.T("room.reset")))
+		receive(t, lost[i])
+	}
+	receiveUntil(t, hostUsers, func(users []string) bool { return len(users) == 1 })
+
+	// The listener is still there to join again
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+}
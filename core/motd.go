@@ -0,0 +1,32 @@
+package core
+
+import "cabinchat/i18n"
+
+// clearMotd is the /motd argument that removes the welcome message
+const clearMotd = "-"
+
+// setMotd changes the welcome message sent to each joiner from now on;
+// people already in the room don't get it again. It returns local output.
+func (h *Host) setMotd(text string) string {
+	if text == clearMotd {
+		text = ""
+	}
+	h.mutex.Lock()
+	h.motd = text
+	h.mutex.Unlock()
+
+	if text == "" {
+		return i18n.T("motd.cleared") + "\n"
+	}
+	return i18n.T("motd.set", text) + "\n"
+}
+
+// motdInfo shows the welcome message for /motd
+func (h *Host) motdInfo() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.motd == "" {
+		return i18n.T("motd.none") + "\n"
+	}
+	return i18n.T("motd.current", h.motd) + "\n"
+}
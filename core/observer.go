@@ -0,0 +1,24 @@
+package core
+
+import "cabinchat/i18n"
+
+// observeFlag in a join's Data asks the host to let the client in quietly:
+// no join or leave notices and no place in the user list. Hosts only agree
+// with Settings.AllowObservers, since nobody else can tell who is reading.
+const observeFlag = "observe"
+
+// wantsToObserve reports whether a join asks to observe
+func wantsToObserve(join Message) bool {
+	return join.Data == observeFlag
+}
+
+// noteObserver tells the host, and only the host, that an observer came or went
+func (h *Host) noteObserver(nick string, joined bool) {
+	text := i18n.T("observer.joined", nick)
+	if !joined {
+		text = i18n.T("observer.left", nick)
+	}
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(text)
+	}
+}
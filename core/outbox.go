@@ -0,0 +1,153 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cabinchat/logger"
+
+	"cabinchat/i18n"
+)
+
+// maxOutbox is how many undelivered chat messages a client holds on to
+const maxOutbox = 50
+
+// deliveredWindow is how long the host remembers a message's nonce, so a
+// client resending it after a reconnect doesn't post it twice
+const deliveredWindow = 10 * time.Minute
+
+// ErrOutboxFull is returned when too many messages await delivery
+var ErrOutboxFull = errors.New("too many messages waiting to be delivered")
+
+// outbox holds a client's chat messages until the host echoes them back.
+// Each gets a nonce the host copies into its echo, which acknowledges it.
+type outbox struct {
+	mu      sync.Mutex
+	prefix  string // makes nonces unique to this client
+	seq     int
+	pending []outboxEntry
+}
+
+type outboxEntry struct {
+	msg    Message
+	sentOn net.Conn // connection it was last written to, nil = not yet
+}
+
+func newOutbox() *outbox {
+	return &outbox{prefix: strconv.FormatInt(time.Now().UnixNano(), 36)}
+}
+
+// add queues msg under a new nonce
+func (o *outbox) add(msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) >= maxOutbox {
+		return ErrOutboxFull
+	}
+	o.seq++
+	msg.Nonce = fmt.Sprintf("%s-%d", o.prefix, o.seq)
+	o.pending = append(o.pending, outboxEntry{msg: msg})
+	return nil
+}
+
+// ack drops the message the host echoed back, or reported dropped, under nonce
+func (o *outbox) ack(nonce string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, entry := range o.pending {
+		if entry.msg.Nonce == nonce {
+			o.pending = append(o.pending[:i], o.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// flush writes the queued messages not yet sent on conn, in order, stopping
+// at the first failure; the rest go out after the next reconnect
+func (o *outbox) flush(conn net.Conn) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.pending {
+		if o.pending[i].sentOn == conn {
+			continue
+		}
+		if err := SendMessage(conn, o.pending[i].msg); err != nil {
+			return err
+		}
+		o.pending[i].sentOn = conn
+	}
+	return nil
+}
+
+// drain empties the outbox, returning what was never delivered
+func (o *outbox) drain() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	msgs := make([]Message, len(o.pending))
+	for i, entry := range o.pending {
+		msgs[i] = entry.msg
+	}
+	o.pending = nil
+	return msgs
+}
+
+// post queues a chat message and sends it if connected. A failed write
+// isn't an error: the message is resent once the connection is back.
+func (c *ChatClient) post(msg Message) error {
+	if err := c.out.add(msg); err != nil {
+		return err
+	}
+	if err := c.out.flush(c.hostConn()); err != nil {
+		logger.Debugf("Message queued until reconnected: %v", err)
+	}
+	return nil
+}
+
+// reportUndelivered tells the UI about messages lost with the connection
+func (c *ChatClient) reportUndelivered() {
+	msgs := c.out.drain()
+	if len(msgs) == 0 || c.callbacks.OnSystemMessage == nil {
+		return
+	}
+	texts := make([]string, len(msgs))
+	for i, msg := range msgs {
+		texts[i] = msg.Text
+	}
+	c.callbacks.OnSystemMessage(i18n.T("outbox.undelivered", strings.Join(texts, " / ")))
+}
+
+// delivered is a posted message remembered by its sender's nonce
+type delivered struct {
+	msg Message
+	at  time.Time
+}
+
+// redeliver handles a client resending a message the host already posted:
+// the client evidently missed the echo, so it gets it again, alone. It
+// reports whether nonce was already delivered.
+func (h *Host) redeliver(client *Client, nonce string) bool {
+	h.mutex.RLock()
+	done, ok := h.delivered[nonce]
+	h.mutex.RUnlock()
+	if ok {
+		client.send(done.msg, done.msg)
+	}
+	return ok
+}
+
+// noteDelivered remembers a posted message's nonce for redeliver, forgetting
+// those older than deliveredWindow. Callers hold h.mutex.
+func (h *Host) noteDelivered(msg Message) {
+	now := time.Now()
+	for nonce, d := range h.delivered {
+		if now.Sub(d.at) > deliveredWindow {
+			delete(h.delivered, nonce)
+		}
+	}
+	h.delivered[msg.Nonce] = delivered{msg: msg, at: now}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+)
+
+// pending counts the outbox's undelivered messages
+func (o *outbox) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+func TestOutboxBound(t *testing.T) {
+	o := newOutbox()
+	for i := range maxOutbox {
+		if err := o.add(Message{Type: MsgTypeMsg, Text: "hi"}); err != nil {
+			t.Fatalf("add %d: %v", i+1, err)
+		}
+	}
+	if err := o.add(Message{Type: MsgTypeMsg}); !errors.Is(err, ErrOutboxFull) {
+		t.Fatalf("add past the bound = %v, want ErrOutboxFull", err)
+	}
+
+	o.ack(o.pending[0].msg.Nonce)
+	if err := o.add(Message{Type: MsgTypeMsg}); err != nil {
+		t.Errorf("add after an ack = %v, want room for one more", err)
+	}
+}
+
+// readNonces reads n messages from conn in the background
+func readNonces(conn net.Conn, n int) <-chan []string {
+	done := make(chan []string, 1)
+	go func() {
+		reader := bufio.NewReader(conn)
+		var nonces []string
+		for range n {
+			msg, err := ReadMessage(reader)
+			if err != nil {
+				break
+			}
+			nonces = append(nonces, msg.Nonce)
+		}
+		done <- nonces
+	}()
+	return done
+}
+
+func TestOutboxFlushSendsEachMessageOncePerConnection(t *testing.T) {
+	o := newOutbox()
+	o.add(Message{Type: MsgTypeMsg, Text: "one"})
+	o.add(Message{Type: MsgTypeMsg, Text: "two"})
+
+	first, host := net.Pipe()
+	defer first.Close()
+	got := readNonces(host, 2)
+	if err := o.flush(first); err != nil {
+		t.Fatal(err)
+	}
+	if nonces := receive(t, got); len(nonces) != 2 || nonces[0] == nonces[1] {
+		t.Fatalf("first flush sent nonces %q, want two distinct ones", nonces)
+	}
+
+	// Nothing new to write on the same connection
+	if err := o.flush(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a reconnect, whatever is still unacknowledged goes again
+	o.ack(o.pending[0].msg.Nonce)
+	second, host2 := net.Pipe()
+	defer second.Close()
+	got = readNonces(host2, 1)
+	if err := o.flush(second); err != nil {
+		t.Fatal(err)
+	}
+	if nonces := receive(t, got); len(nonces) != 1 || nonces[0] != o.pending[0].msg.Nonce {
+		t.Errorf("resent %q, want only the unacknowledged %q", nonces, o.pending[0].msg.Nonce)
+	}
+}
+
+func TestOutboxForgetsMessagesDroppedForFlooding(t *testing.T) {
+	withSetting(t, &Settings.MsgRate, 0.001)
+	withSetting(t, &Settings.MsgBurst, 1)
+	withSetting(t, &Settings.FloodStrikes, 100)
+
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{})
+	for range 5 {
+		if _, err := alice.SendText("spam"); err != nil {
+			t.Fatalf("SendText: %v", err)
+		}
+	}
+	eventually(t, "the outbox is empty", func() bool { return alice.out.count() == 0 })
+}
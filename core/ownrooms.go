@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrOwnRoom is returned when joining a room this process is hosting
+var ErrOwnRoom = errors.New("that's the room you are hosting")
+
+// hostedRooms are the rooms this process hosts, instance name by port, so
+// discovery can leave them out
+var (
+	hostedMu    sync.Mutex
+	hostedRooms = make(map[int]string)
+)
+
+// noteHosted records a room this process now hosts
+func noteHosted(name string, port int) {
+	hostedMu.Lock()
+	hostedRooms[port] = name
+	hostedMu.Unlock()
+}
+
+// forgetHosted removes a room this process stopped hosting
+func forgetHosted(port int) {
+	hostedMu.Lock()
+	delete(hostedRooms, port)
+	hostedMu.Unlock()
+}
+
+// isOwnRoom reports whether room is one this process hosts: on one of our
+// ports, at one of this machine's addresses and, if found by discovery,
+// under the name we advertise it as
+func isOwnRoom(room DiscoveredRoom) bool {
+	hostedMu.Lock()
+	name, hosted := hostedRooms[room.Port]
+	hostedMu.Unlock()
+	if !hosted || room.Name != "" && room.Name != name {
+		return false
+	}
+	return isLocalAddress(room.Host)
+}
+
+// dropOwnRooms leaves the rooms this process hosts out of a scan, since
+// mDNS finds our own advertisement too
+func dropOwnRooms(rooms []DiscoveredRoom) []DiscoveredRoom {
+	result := []DiscoveredRoom{}
+	for _, room := range rooms {
+		if !isOwnRoom(room) {
+			result = append(result, room)
+		}
+	}
+	return result
+}
+
+// isLocalAddress reports whether host is an IP address of this machine
+func isLocalAddress(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.String() == getLocalIP() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
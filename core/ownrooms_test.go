@@ -0,0 +1,38 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDropOwnRooms(t *testing.T) {
+	noteHosted("cabin", 4242)
+	t.Cleanup(func() { forgetHosted(4242) })
+
+	own := DiscoveredRoom{Name: "cabin", Host: "127.0.0.1", Port: 4242}
+	typedIn := DiscoveredRoom{Host: "127.0.0.1", Port: 4242}
+	otherName := DiscoveredRoom{Name: "lodge", Host: "127.0.0.1", Port: 4242}
+	otherPort := DiscoveredRoom{Name: "cabin", Host: "127.0.0.1", Port: 4243}
+	elsewhere := DiscoveredRoom{Name: "cabin", Host: "192.0.2.7", Port: 4242}
+
+	got := dropOwnRooms([]DiscoveredRoom{own, otherName, typedIn, otherPort, elsewhere})
+	if want := []DiscoveredRoom{otherName, otherPort, elsewhere}; !slices.Equal(got, want) {
+		t.Errorf("dropOwnRooms = %+v, want %+v", got, want)
+	}
+
+	forgetHosted(4242)
+	if got := dropOwnRooms([]DiscoveredRoom{own}); len(got) != 1 {
+		t.Errorf("room still left out after we stopped hosting it: %+v", got)
+	}
+}
+
+func TestJoiningOwnRoomIsRefused(t *testing.T) {
+	noteHosted("cabin", 4242)
+	t.Cleanup(func() { forgetHosted(4242) })
+
+	room := DiscoveredRoom{Name: "cabin", Host: "127.0.0.1", Port: 4242}
+	if _, err := NewChatClient(room, "me", nil, ClientCallbacks{}, NewPipeTransport()); !errors.Is(err, ErrOwnRoom) {
+		t.Errorf("err = %v, want ErrOwnRoom", err)
+	}
+}
@@ -0,0 +1,12 @@
+package core
+
+import (
+	"runtime/debug"
+
+	"cabinchat/logger"
+)
+
+// logPanic logs a recovered panic with the stack that raised it
+func logPanic(what string, r any) {
+	logger.Errorf("Panic while %s: %v\n%s", what, r, debug.Stack())
+}
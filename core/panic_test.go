@@ -0,0 +1,50 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHostSurvivesAPanickingHandler(t *testing.T) {
+	hostUsers, onHostUsers := collect[[]string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{
+		OnUserList: onHostUsers,
+		OnMessageReceived: func(msg Message) {
+			if msg.Text == "boom" {
+				panic("bug in a handler")
+			}
+		},
+	})
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{})
+	receiveUntil(t, hostUsers, func(users []string) bool { return slices.Contains(users, "alice") })
+
+	alice.SendText("boom")
+	receiveUntil(t, hostUsers, func(users []string) bool { return !slices.Contains(users, "alice") })
+
+	// Only alice was dropped: the listener still takes new clients
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "bob", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return slices.Contains(users, "bob") })
+}
+
+func TestClientSkipsAMessageItPanicsOn(t *testing.T) {
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	msgs, onMsg := collect[Message]()
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{
+		OnUserList: onUsers,
+		OnMessageReceived: func(msg Message) {
+			if msg.Text == "boom" {
+				panic("bug in a handler")
+			}
+			onMsg(msg)
+		},
+	})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	h.SendText("boom")
+	h.SendText("after")
+	if msg := receive(t, msgs); msg.Text != "after" {
+		t.Errorf("alice got %q, want the message after the panic", msg.Text)
+	}
+}
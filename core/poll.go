@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// Poll is a room vote, sent as JSON in the Data of a MsgTypePoll
+type Poll struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Votes    []int    `json:"votes,omitempty"` // Tally per option, filled in when closed
+	Closed   bool     `json:"closed,omitempty"`
+}
+
+// parsePoll parses `"question" opt1 | opt2 | ...`
+func parsePoll(args string) (*Poll, bool) {
+	args = strings.TrimSpace(args)
+	if !strings.HasPrefix(args, "\"") {
+		return nil, false
+	}
+	end := strings.Index(args[1:], "\"")
+	if end < 1 {
+		return nil, false
+	}
+	question := args[1 : end+1]
+
+	var options []string
+	for _, opt := range strings.Split(args[end+2:], "|") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	if len(options) < 2 {
+		return nil, false
+	}
+	return &Poll{Question: question, Options: options}, true
+}
+
+// pollMessage wraps a poll for the wire
+func pollMessage(from string, poll Poll) Message {
+	data, _ := json.Marshal(poll)
+	return Message{Type: MsgTypePoll, Nick: from, Data: string(data)}
+}
+
+// results formats the tally of a closed poll
+func (p Poll) results() string {
+	var parts []string
+	for i, opt := range p.Options {
+		parts = append(parts, fmt.Sprintf("%s: %d", opt, p.Votes[i]))
+	}
+	return i18n.T("poll.closed", p.Question, strings.Join(parts, ", "))
+}
+
+// startPoll opens a poll and broadcasts it to the room
+func (h *Host) startPoll(poll *Poll) string {
+	h.mutex.Lock()
+	if h.poll != nil && !h.poll.Closed {
+		h.mutex.Unlock()
+		return i18n.T("poll.alreadyOpen") + "\n"
+	}
+	h.poll = poll
+	h.pollVotes = make(map[string]int)
+	h.mutex.Unlock()
+
+	h.broadcast(pollMessage(h.nick, *poll), nil)
+	if h.callbacks.OnPoll != nil {
+		h.callbacks.OnPoll(*poll)
+	}
+	return ""
+}
+
+// closePoll tallies the open poll and broadcasts the results
+func (h *Host) closePoll() string {
+	h.mutex.Lock()
+	if h.poll == nil || h.poll.Closed {
+		h.mutex.Unlock()
+		return i18n.T("poll.noneOpen") + "\n"
+	}
+	h.poll.Votes = make([]int, len(h.poll.Options))
+	for _, choice := range h.pollVotes {
+		h.poll.Votes[choice]++
+	}
+	h.poll.Closed = true
+	poll := *h.poll
+	h.mutex.Unlock()
+
+	h.broadcast(pollMessage(h.nick, poll), nil)
+	h.broadcast(Message{Type: MsgTypeSystem, Text: poll.results()}, nil)
+	if h.callbacks.OnPoll != nil {
+		h.callbacks.OnPoll(poll)
+	}
+	return poll.results() + "\n"
+}
+
+// vote records nick's vote for option n (1-based) and returns the reply for the voter
+func (h *Host) vote(nick string, n int) string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch {
+	case h.poll == nil:
+		return i18n.T("poll.none")
+	case h.poll.Closed:
+		return i18n.T("poll.isClosed")
+	case n < 1 || n > len(h.poll.Options):
+		return i18n.T("poll.pickOption", len(h.poll.Options))
+	}
+	if _, voted := h.pollVotes[nick]; voted {
+		return i18n.T("poll.alreadyVoted")
+	}
+	h.pollVotes[nick] = n - 1
+	return i18n.T("poll.voted", h.poll.Options[n-1])
+}
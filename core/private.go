@@ -0,0 +1,66 @@
+package core
+
+import "cabinchat/i18n"
+
+// sendPrivate sends a private message from the host, returning local output
+func (h *Host) sendPrivate(to string, text string) string {
+	if !h.sendToNick(to, Message{Type: MsgTypePrivate, Nick: h.nick, Target: to, Text: text}) {
+		return i18n.T("user.notFound", to) + "\n"
+	}
+	h.stats.message(true)
+	h.room.messages.Add(1)
+	h.touchActivity()
+	return i18n.T("private.sent", to, text) + "\n"
+}
+
+// routePrivate delivers a client's private message to the host or to its recipient
+func (h *Host) routePrivate(from *Client, target string, text string) {
+	msg := Message{Type: MsgTypePrivate, Nick: from.nick, Target: target, Text: text}
+	h.room.messages.Add(1)
+	if target == h.nick {
+		h.mutex.Lock()
+		h.lastPrivateFrom = from.nick
+		h.mutex.Unlock()
+		h.stats.message(false)
+		h.touchActivity()
+		if h.callbacks.OnMessageReceived != nil {
+			h.callbacks.OnMessageReceived(msg)
+		}
+		h.replyAway(from.nick)
+		return
+	}
+	if !h.sendToNick(target, msg) {
+		SendMessage(from.conn, Message{Type: MsgTypeSystem, Text: i18n.T("user.notFound", target)})
+	}
+}
+
+// replyPrivate answers whoever last messaged the host privately
+func (h *Host) replyPrivate(text string) string {
+	h.mutex.RLock()
+	to := h.lastPrivateFrom
+	h.mutex.RUnlock()
+	if to == "" {
+		return i18n.T("private.noSender") + "\n"
+	}
+	return h.sendPrivate(to, text)
+}
+
+// sendPrivate sends a private message via the host, returning local output
+func (c *ChatClient) sendPrivate(to string, text string) string {
+	if err := SendMessage(c.hostConn(), Message{Type: MsgTypePrivate, Nick: c.nick, Target: to, Text: text}); err != nil {
+		return i18n.T("error", err) + "\n"
+	}
+	c.stats.message(true)
+	return i18n.T("private.sent", to, text) + "\n"
+}
+
+// replyPrivate answers whoever last messaged us privately
+func (c *ChatClient) replyPrivate(text string) string {
+	c.awayMu.Lock()
+	to := c.lastPrivateFrom
+	c.awayMu.Unlock()
+	if to == "" {
+		return i18n.T("private.noSender") + "\n"
+	}
+	return c.sendPrivate(to, text)
+}
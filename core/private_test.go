@@ -0,0 +1,40 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReplyWhileAPrivateMessageArrives(t *testing.T) {
+	msgs, onMessage := collect[Message]()
+	users, onUsers := collect[[]string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onMessage, OnUserList: onUsers})
+	private, onPrivate := collect[Message]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnMessageReceived: onPrivate})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	h.SendText("/msg alice first")
+	receive(t, private)
+
+	// Run with -race: /r reads who to answer while the receive loop records it
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			h.SendText("/msg alice again")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			alice.SendText("/r ok")
+		}
+	}()
+	wg.Wait()
+
+	reply := receiveUntil(t, msgs, func(msg Message) bool { return msg.Type == MsgTypePrivate })
+	if reply.Nick != "alice" || reply.Target != "host" || reply.Text != "ok" {
+		t.Errorf("/r sent %+v, want alice's reply to the host", reply)
+	}
+}
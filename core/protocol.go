@@ -2,28 +2,62 @@ package core
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Message types
 const (
-	MsgTypeJoin      = "join"
-	MsgTypeMsg       = "msg"
-	MsgTypeSystem    = "system"
-	MsgTypeLeave     = "leave"
-	MsgTypeNick      = "nick"     // Nick change: Nick=old, Text=new
-	MsgTypeUserList  = "userlist" // Text contains comma-separated users
-	MsgTypePing      = "ping"
-	MsgTypePong      = "pong"
-	MsgTypeFileOffer = "fileoffer" // File offer: Nick=sender, Text=filename, Data=size
-	MsgTypeFileAcc   = "fileacc"   // Accept: Nick=recipient, Text=sender (who to accept from)
-	MsgTypeFileRej   = "filerej"   // Reject: Nick=recipient, Text=sender
-	MsgTypeFile      = "file"      // Actual file data: Nick=sender, Text=filename, Data=base64
-	MsgTypeWebRTC    = "webrtc"    // WebRTC signal: Nick=sender, Target=recipient, Data=JSON(Signal)
+	MsgTypeJoin        = "join" // Join: Nick=joiner, Enc=accepted encodings, Data="observe" to join quietly; echoed by the host to agree
+	MsgTypeMsg         = "msg"
+	MsgTypePrivate     = "private" // Private message: Nick=sender, Target=recipient, Text
+	MsgTypeEdit        = "edit"    // Edit own message: Nick=author, ID=message, Text=new text
+	MsgTypeDelete      = "delete"  // Delete own message: Nick=author, ID=message
+	MsgTypeSystem      = "system"  // Notice: Text; arrivals and departures also have Nick=who, Data=PresenceJoined or PresenceLeft
+	MsgTypeLeave       = "leave"
+	MsgTypeNick        = "nick"        // Nick change: Nick=old, Text=new
+	MsgTypeUserList    = "userlist"    // Text contains comma-separated users; sent by the host to a joining client, then kept current by the deltas below
+	MsgTypeUserJoined  = "userjoined"  // From host: Nick=user now in the list
+	MsgTypeUserLeft    = "userleft"    // From host: Nick=user no longer in the list
+	MsgTypeUserRenamed = "userrenamed" // From host: Nick=old, Text=new
+	MsgTypePing        = "ping"
+	MsgTypePong        = "pong"
+	MsgTypeStats       = "stats"      // /stats: request from a client; the host's answer has Text=stats
+	MsgTypeFileOffer   = "fileoffer"  // File offer: Nick=sender, Text=filename, Data=size
+	MsgTypeFileAcc     = "fileacc"    // Accept: Nick=recipient, Text=sender (who to accept from)
+	MsgTypeFileRej     = "filerej"    // Reject: Nick=recipient, Text=sender
+	MsgTypeFile        = "file"       // Actual file data: Nick=sender, Text=filename, Data=base64, Sum=sha256
+	MsgTypeFileBad     = "filebad"    // Checksum mismatch: Nick=recipient, Text=filename, Target=sender
+	MsgTypeFileCancel  = "filecancel" // Offer withdrawn: Nick=sender, Text=filename
+	MsgTypeWebRTC      = "webrtc"     // WebRTC signal: Nick=sender, Target=recipient, Data=JSON(Signal)
+	MsgTypePoll        = "poll"       // Poll opened/closed by host: Data=JSON(Poll)
+	MsgTypeVote        = "vote"       // Vote in the open poll: Nick=voter, Text=option number
+	MsgTypeReaction    = "reaction"   // Emoji reaction: Nick=reactor, ID=message, Text=emoji, Data=count (from host)
+	MsgTypeTopic       = "topic"      // Room topic from host: Nick=setter, Text=topic ("" = cleared)
+	MsgTypeInvite      = "invite"     // Room invite to an idle instance: Nick=inviter, Text=join link
+	MsgTypeAway        = "away"       // Away status, to host and relayed to all: Nick=user, Data="1" while away, Text=away message
+	MsgTypeHandoff     = "handoff"    // Host role: request Target=new host; answer Text=join link or Data=error; announce Nick=new host, Text=link
+	MsgTypeRefused     = "refused"    // From host before it disconnects a client it won't have (banned, or an observer where not allowed): Text=reason; don't reconnect
+	MsgTypeFileBinary  = "filebin"    // File header whose contents follow in a raw frame, see EncBinary; read back as MsgTypeFile
+	MsgTypeDropped     = "dropped"    // From host: the chat message sent under Nonce was dropped for flooding and won't be echoed
 )
 
+// ErrBadMessage is returned by ReadMessage for a line that isn't valid JSON.
+// Unlike I/O errors it is recoverable: the frame is skipped and the
+// connection can keep reading.
+var ErrBadMessage = errors.New("malformed message")
+
 // Message represents a chat message
 type Message struct {
 	Type   string `json:"type"`
@@ -31,25 +65,191 @@ type Message struct {
 	Text   string `json:"text,omitempty"`
 	Data   string `json:"data,omitempty"`   // Base64 file content
 	Target string `json:"target,omitempty"` // Target nick for DMs/files
+	Sum    string `json:"sum,omitempty"`    // Hex SHA-256 of file content
+	ID     string `json:"id,omitempty"`     // Chat message ID, assigned by the host
+	Enc    string `json:"enc,omitempty"`    // Join: Data encodings accepted; file: how Data is encoded
+	Nonce  string `json:"nonce,omitempty"`  // Sender's ID for a chat message, echoed back to acknowledge it
+	Addr   string `json:"addr,omitempty"`   // File offer from host: the sender's IP, see IsTrusted
+
+	// Replies quote the message they answer, which may no longer be in anyone's history
+	ReplyTo   string `json:"reply_to,omitempty"`   // ID of the quoted message
+	ReplyNick string `json:"reply_nick,omitempty"` // Its author
+	ReplyText string `json:"reply_text,omitempty"` // A snippet of it, see ReplySnippet
+
+	Raw []byte `json:"-"` // File content before base64, used instead of Data when set
 }
 
-// SendMessage writes a JSON message followed by newline to connection
+// Presence kinds, in the Data of a system message announcing that Nick
+// joined or left, so UIs need not parse the text
+const (
+	PresenceJoined = "joined"
+	PresenceLeft   = "left"
+)
+
+// EncGzip is the file Data encoding where the file is gzipped before base64.
+// Clients offer it in their join message and the host echoes a join back
+// when it agrees, so older peers never see it.
+const EncGzip = "gzip"
+
+// Already-compressed formats barely shrink (images, archives and media stay
+// at ~100% and cost 10-20ms per MB to try), so they are sent as-is
+var compressedExts = []string{
+	".7z", ".avi", ".bz2", ".docx", ".gif", ".gz", ".heic", ".jpeg", ".jpg", ".m4a", ".mkv",
+	".mov", ".mp3", ".mp4", ".ogg", ".png", ".rar", ".webm", ".webp", ".xlsx", ".xz", ".zip",
+}
+
+// minCompressSize is the smallest file worth compressing
+const minCompressSize = 1024
+
+// SendMessage writes a JSON message followed by newline to connection, or as
+// a frame if the peer agreed to framing (see EncFramed).
+// The whole line is written or an error is returned; after an error the
+// stream may hold part of a message, so the connection should be closed.
+// A peer that accepts nothing for Settings.WriteTimeout has stalled, and its
+// connection is closed here so no writer stays blocked on it.
 func SendMessage(conn net.Conn, msg Message) error {
+	msg, raw, err := splitRaw(conn, msg)
+	if err != nil {
+		return err
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(conn, "%s\n", data)
-	return err
+	if isFramed(conn) {
+		data = appendFrame(data)
+		if raw != nil {
+			data = appendRawFrame(data, raw)
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	if Settings.WriteTimeout > 0 {
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	for len(data) > 0 {
+		if Settings.WriteTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(Settings.WriteTimeout))
+		}
+		n, err := conn.Write(data)
+		if err == nil && n == 0 {
+			err = io.ErrShortWrite
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			conn.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("send %s message: %w", msg.Type, err)
+		}
+		data = data[n:]
+	}
+	return nil
 }
 
-// ReadMessage reads a single JSON message from buffered reader
+// ReadMessage reads a single JSON message from buffered reader, whether sent
+// as a line or a frame.
+// A final message missing its trailing newline before EOF is still returned;
+// the EOF is then reported by the next call.
 func ReadMessage(reader *bufio.Reader) (Message, error) {
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return Message{}, err
+	var data []byte
+	if atFrame(reader) {
+		frame, err := readFrame(reader)
+		if err != nil {
+			return Message{}, err
+		}
+		data = frame
+	} else {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || strings.TrimSpace(line) == "") {
+			return Message{}, err
+		}
+		data = []byte(line)
 	}
 	var msg Message
-	err = json.Unmarshal([]byte(line), &msg)
-	return msg, err
+	if err := json.Unmarshal(data, &msg); err != nil {
+		// A garbled file header may still be followed by its contents. Skip
+		// them too, or they would be taken for the next message.
+		if atMarker(reader, rawFrameMarker) {
+			if _, err := readRawFrame(reader); err != nil {
+				return Message{}, err
+			}
+		}
+		return Message{}, fmt.Errorf("%w: %v", ErrBadMessage, err)
+	}
+	if msg.Type == MsgTypeFileBinary {
+		raw, err := readRawFrame(reader)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Type = MsgTypeFile
+		msg.Raw = raw
+	}
+	if msg.Enc != "" && msg.Type == MsgTypeFile {
+		if err := decompressFile(&msg); err != nil {
+			return Message{}, fmt.Errorf("%w: %v", ErrBadMessage, err)
+		}
+	}
+	return msg, nil
+}
+
+// fileBytes returns a file message's contents, decoding Data unless they
+// are already in Raw
+func fileBytes(msg Message) ([]byte, error) {
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+	return base64.StdEncoding.DecodeString(msg.Data)
+}
+
+// compressFile returns a file message with its contents gzipped, or msg
+// unchanged when compression doesn't cut it by at least 10%.
+// Other message types are returned as they are.
+func compressFile(msg Message) Message {
+	if msg.Type != MsgTypeFile || msg.Enc != "" ||
+		slices.Contains(compressedExts, strings.ToLower(filepath.Ext(msg.Text))) {
+		return msg
+	}
+	raw, err := fileBytes(msg)
+	if err != nil || len(raw) < minCompressSize {
+		return msg
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed) // LAN transfers favour speed over ratio
+	zw.Write(raw)
+	zw.Close()
+	if buf.Len() > len(raw)*9/10 {
+		return msg
+	}
+	msg.Raw, msg.Data = buf.Bytes(), ""
+	msg.Enc = EncGzip
+	return msg
+}
+
+// decompressFile restores a file message's plain contents into Raw
+func decompressFile(msg *Message) error {
+	if msg.Enc != EncGzip {
+		return fmt.Errorf("unknown encoding %q", msg.Enc)
+	}
+	packed, err := fileBytes(*msg)
+	if err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		return err
+	}
+	// Read one byte past the limit so oversized files still fail decodeFile's size check
+	raw, err := io.ReadAll(io.LimitReader(zr, MaxFileSize+1))
+	if err != nil {
+		return err
+	}
+	msg.Raw, msg.Data = raw, ""
+	msg.Enc = ""
+	return nil
+}
+
+// acceptsGzip reports whether a join message offers the gzip encoding
+func acceptsGzip(join Message) bool {
+	return slices.Contains(strings.Split(join.Enc, ","), EncGzip)
 }
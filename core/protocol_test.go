@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnterminatedLastMessageIsRead(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(`{"type":"msg","text":"first"}` + "\n" + `{"type":"msg","text":"last"}`))
+
+	for _, want := range []string{"first", "last"} {
+		msg, err := ReadMessage(reader)
+		if err != nil {
+			t.Fatalf("reading %q: %v", want, err)
+		}
+		if msg.Text != want {
+			t.Errorf("read %q, want %q", msg.Text, want)
+		}
+	}
+	if _, err := ReadMessage(reader); err != io.EOF {
+		t.Errorf("after the last message: err = %v, want io.EOF", err)
+	}
+}
+
+func TestBlankTailIsEOF(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("  "))
+	if _, err := ReadMessage(reader); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+// trickleConn accepts at most max bytes per Write, as a congested socket may
+type trickleConn struct {
+	net.Conn
+	max int
+	buf bytes.Buffer
+}
+
+func (c *trickleConn) Write(p []byte) (int, error) {
+	n := min(len(p), c.max)
+	return c.buf.Write(p[:n])
+}
+
+func (c *trickleConn) SetWriteDeadline(time.Time) error { return nil }
+
+func TestShortWritesSendTheWholeMessage(t *testing.T) {
+	conn := &trickleConn{max: 3}
+	if err := SendMessage(conn, Message{Type: MsgTypeMsg, Nick: "alice", Text: "a long enough message"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	msg, err := ReadMessage(bufio.NewReader(&conn.buf))
+	if err != nil {
+		t.Fatalf("reading it back: %v", err)
+	}
+	if msg.Text != "a long enough message" {
+		t.Errorf("read %q", msg.Text)
+	}
+}
+
+func TestWriteOfNothingIsAnError(t *testing.T) {
+	conn := &trickleConn{max: 0}
+	if err := SendMessage(conn, Message{Type: MsgTypeMsg, Text: "hi"}); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("err = %v, want io.ErrShortWrite", err)
+	}
+}
+
+func TestStalledPeerTimesOut(t *testing.T) {
+	withSetting(t, &Settings.WriteTimeout, 50*time.Millisecond)
+	conn, stalled := net.Pipe() // stalled never reads
+	defer stalled.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- SendMessage(conn, Message{Type: MsgTypeMsg, Text: "anyone there?"}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("err = %v, want a deadline error", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("SendMessage blocked on a peer that never reads")
+	}
+	if _, err := conn.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("writing after the timeout: err = %v, want the connection closed", err)
+	}
+}
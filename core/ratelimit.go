@@ -0,0 +1,68 @@
+package core
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"cabinchat/i18n"
+)
+
+// rateLimiter is a token bucket that refills at a fixed rate
+type rateLimiter struct {
+	rate   float64 // tokens added per second
+	burst  float64 // maximum tokens held
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a full bucket allowing rate events/sec with the given burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token if one is available
+func (r *rateLimiter) Allow() bool {
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// allow reports whether a message of this type is within the client's rate limits.
+// File data and WebRTC signaling are exempt since they follow an accepted offer or call.
+func (c *Client) allow(msgType string) bool {
+	switch msgType {
+	case MsgTypeFileOffer:
+		return c.offerLimiter.Allow()
+	case MsgTypeMsg, MsgTypePrivate, MsgTypeEdit, MsgTypeDelete, MsgTypeNick, MsgTypeUserList, MsgTypePing, MsgTypeReaction, MsgTypeAway:
+		return c.msgLimiter.Allow()
+	}
+	return true
+}
+
+// TruncateMessage cuts chat text to Settings.MaxMessageLen characters,
+// reporting whether it had to
+func TruncateMessage(text string) (string, bool) {
+	if Settings.MaxMessageLen <= 0 || utf8.RuneCountInString(text) <= Settings.MaxMessageLen {
+		return text, false
+	}
+	return string([]rune(text)[:Settings.MaxMessageLen]), true
+}
+
+// truncatedWarning tells the sender their message was cut
+func truncatedWarning() string {
+	return i18n.T("msg.truncated", Settings.MaxMessageLen)
+}
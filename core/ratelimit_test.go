@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+func TestRateLimiterThrottlesBurst(t *testing.T) {
+	r := newRateLimiter(2, 3)
+	for i := 0; i < 3; i++ {
+		if !r.Allow() {
+			t.Fatalf("event %d of the burst refused", i+1)
+		}
+	}
+	if r.Allow() {
+		t.Fatal("event beyond the burst allowed")
+	}
+
+	r.last = r.last.Add(-time.Second) // two tokens' worth of waiting
+	if !r.Allow() || !r.Allow() {
+		t.Error("refilled tokens refused")
+	}
+	if r.Allow() {
+		t.Error("more allowed than refilled")
+	}
+}
+
+func TestFloodIsDroppedThenKicked(t *testing.T) {
+	withSetting(t, &Settings.MsgRate, 0.001)
+	withSetting(t, &Settings.MsgBurst, 3)
+	withSetting(t, &Settings.FloodStrikes, 2)
+	hostMsgs, onHostMsg := collect[Message]()
+	hostSystem, onHostSystem := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg, OnSystemMessage: onHostSystem})
+	system, onSystem := collect[string]()
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnSystemMessage: onSystem, OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	for _, text := range []string{"1", "2", "3", "4", "5"} {
+		alice.SendText(text)
+	}
+	for _, want := range []string{"1", "2", "3"} {
+		if msg := receive(t, hostMsgs); msg.Text != want {
+			t.Errorf("host got %q, want %q", msg.Text, want)
+		}
+	}
+	receiveUntil(t, system, textIs(i18n.T("flood.warning")))
+	receiveUntil(t, system, textIs(i18n.T("flood.kicked")))
+	receiveUntil(t, hostSystem, textIs(i18n.T("flood.kickedNotice", "alice")))
+	select {
+	case msg := <-hostMsgs:
+		t.Errorf("host got %q from the flood", msg.Text)
+	default:
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"strconv"
+	"time"
+)
+
+// reactionHistory is how many recent messages keep their reactions, and can
+// be edited or deleted, on the host
+const reactionHistory = 200
+
+// newMessageID returns the next chat message ID. Reactions and authors of
+// messages that have fallen out of the recent history are forgotten.
+func (h *Host) newMessageID() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.nextMsgID++
+	forgotten := strconv.Itoa(h.nextMsgID - reactionHistory)
+	delete(h.reactions, forgotten)
+	delete(h.authors, forgotten)
+	return strconv.Itoa(h.nextMsgID)
+}
+
+// postMessage assigns a chat message from author (nil = the host) its ID,
+// shows it locally and sends it to everyone
+func (h *Host) postMessage(msg Message, author *Client) {
+	msg.ID = h.newMessageID()
+	h.mutex.Lock()
+	h.authors[msg.ID] = author
+	if msg.Nonce != "" {
+		h.noteDelivered(msg)
+	}
+	h.lastActivity = time.Now()
+	if author == nil {
+		h.lastOwnMsgID = msg.ID
+	}
+	h.mutex.Unlock()
+	h.stats.message(author == nil)
+	h.room.messages.Add(1)
+	if h.callbacks.OnMessageReceived != nil {
+		h.callbacks.OnMessageReceived(msg)
+	}
+	h.broadcast(msg, nil)
+	h.notifyWebhook(WebhookMessage, msg.Nick, "", msg.Text)
+}
+
+// addReaction records nick's emoji reaction to a message and broadcasts the
+// new count. Each user counts once per emoji per message.
+func (h *Host) addReaction(nick string, id string, emoji string) {
+	h.mutex.Lock()
+	if h.reactions[id] == nil {
+		h.reactions[id] = make(map[string]map[string]bool)
+	}
+	if h.reactions[id][emoji] == nil {
+		h.reactions[id][emoji] = make(map[string]bool)
+	}
+	if h.reactions[id][emoji][nick] {
+		h.mutex.Unlock()
+		return
+	}
+	h.reactions[id][emoji][nick] = true
+	count := len(h.reactions[id][emoji])
+	h.mutex.Unlock()
+
+	h.broadcast(Message{Type: MsgTypeReaction, Nick: nick, ID: id, Text: emoji, Data: strconv.Itoa(count)}, nil)
+	if h.callbacks.OnReaction != nil {
+		h.callbacks.OnReaction(id, emoji, count)
+	}
+}
+
+// React adds the host's own reaction to a message
+func (h *Host) React(id string, emoji string) {
+	h.addReaction(h.nick, id, emoji)
+}
+
+// React sends a reaction to a message
+func (c *ChatClient) React(id string, emoji string) {
+	SendMessage(c.hostConn(), Message{Type: MsgTypeReaction, Nick: c.nick, ID: id, Text: emoji})
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"strings"
+)
+
+// replySnippetLen is how many characters of the quoted message a reply carries
+const replySnippetLen = 80
+
+// ReplySnippet shortens a message to the first line and replySnippetLen
+// characters, for quoting in a reply
+func ReplySnippet(text string) string {
+	text, _, cut := strings.Cut(strings.TrimSpace(text), "\n")
+	runes := []rune(text)
+	if len(runes) > replySnippetLen {
+		return string(runes[:replySnippetLen]) + "…"
+	}
+	if cut {
+		return text + " …"
+	}
+	return text
+}
+
+// withReply marks msg as a reply to the quoted message, if there is one
+func withReply(msg Message, to Message) Message {
+	if to.ID == "" {
+		return msg
+	}
+	msg.ReplyTo = to.ID
+	msg.ReplyNick = to.Nick
+	msg.ReplyText = ReplySnippet(to.Text)
+	return msg
+}
+
+// Reply sends text as a chat message quoting message to
+func (h *Host) Reply(to Message, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	text, _ = TruncateMessage(text)
+	h.postMessage(withReply(Message{Type: MsgTypeMsg, Nick: h.nick, Text: text}, to), nil)
+	return nil
+}
+
+// Reply sends text as a chat message quoting message to
+func (c *ChatClient) Reply(to Message, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	text, _ = TruncateMessage(text)
+	return c.post(withReply(Message{Type: MsgTypeMsg, Nick: c.nick, Text: text}, to))
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// askSaveDir is the /savedir argument that clears Settings.DownloadDir, so
+// the desktop app asks where to save each file again
+const askSaveDir = "-"
+
+// setSaveDir makes dir, created if missing, where received files are saved.
+// onChanged, if set, is given the new directory so it can be kept across
+// restarts. It returns local output.
+func setSaveDir(dir string, onChanged func(dir string)) string {
+	if dir == askSaveDir {
+		Settings.DownloadDir = ""
+		if onChanged != nil {
+			onChanged("")
+		}
+		return i18n.T("savedir.cleared") + "\n"
+	}
+
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err == nil {
+		err = checkSaveDir(dir)
+	}
+	if err != nil {
+		return i18n.T("savedir.failed", err) + "\n"
+	}
+	Settings.DownloadDir = dir
+	if onChanged != nil {
+		onChanged(dir)
+	}
+	return i18n.T("savedir.saving", dir) + "\n"
+}
+
+// checkSaveDir creates dir if needed and makes sure files can be written to it
+func checkSaveDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".cabinchat-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// saveDirInfo describes where received files go, for /savedir
+func saveDirInfo() string {
+	if Settings.DownloadDir != "" {
+		return i18n.T("savedir.saving", Settings.DownloadDir) + "\n"
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	return i18n.T("savedir.unset", cwd) + "\n"
+}
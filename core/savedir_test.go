@@ -0,0 +1,64 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cabinchat/i18n"
+)
+
+func TestSetSaveDir(t *testing.T) {
+	withSetting(t, &Settings.DownloadDir, "")
+	var changed []string
+	onChanged := func(dir string) { changed = append(changed, dir) }
+
+	dir := filepath.Join(t.TempDir(), "new", "downloads")
+	if got := setSaveDir(dir, onChanged); got != i18n.T("savedir.saving", dir)+"\n" {
+		t.Errorf("setSaveDir = %q", got)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("%s wasn't created: %v", dir, err)
+	}
+	if Settings.DownloadDir != dir || saveDirInfo() != i18n.T("savedir.saving", dir)+"\n" {
+		t.Errorf("DownloadDir = %q, saveDirInfo = %q", Settings.DownloadDir, saveDirInfo())
+	}
+
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if got := setSaveDir(file, onChanged); !strings.HasPrefix(got, "Cannot save files there") {
+		t.Errorf("saving into a file: %q", got)
+	}
+	if Settings.DownloadDir != dir {
+		t.Errorf("a failed /savedir changed DownloadDir to %q", Settings.DownloadDir)
+	}
+
+	setSaveDir(askSaveDir, onChanged)
+	if Settings.DownloadDir != "" || !strings.HasPrefix(saveDirInfo(), "No download directory set") {
+		t.Errorf("after clearing: DownloadDir = %q, saveDirInfo = %q", Settings.DownloadDir, saveDirInfo())
+	}
+	if want := []string{dir, ""}; strings.Join(changed, "|") != strings.Join(want, "|") {
+		t.Errorf("onChanged got %q, want %q", changed, want)
+	}
+}
+
+func TestSaveDirCommand(t *testing.T) {
+	withSetting(t, &Settings.DownloadDir, "")
+	dirs, onDir := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers, OnSaveDirChanged: onDir})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	dir := t.TempDir()
+	if out, _ := alice.SendText("/savedir " + dir); !strings.Contains(out, dir) {
+		t.Errorf("/savedir said %q", out)
+	}
+	if got := receive(t, dirs); got != dir {
+		t.Errorf("OnSaveDirChanged got %q, want %q", got, dir)
+	}
+	if out, _ := alice.SendText("/savedir"); out != saveDirInfo() {
+		t.Errorf("/savedir without a directory said %q", out)
+	}
+}
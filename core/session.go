@@ -0,0 +1,72 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// SessionStats counts what happened while hosting or in a room, for the
+// summary shown on leaving
+type SessionStats struct {
+	Duration      time.Duration
+	Sent          int // Chat and private messages
+	Received      int
+	FilesSent     int
+	FilesReceived int
+}
+
+// String summarizes the session in one line
+func (s SessionStats) String() string {
+	return i18n.T("session.summary",
+		s.Duration.Round(time.Second), plural(s.Sent, "count.message"), s.Received, plural(s.FilesSent, "count.file"), s.FilesReceived)
+}
+
+// plural formats a count with the catalog's id+".one" or id+".other" text,
+// e.g. "count.file" gives "1 file" or "3 files"
+func plural(n int, id string) string {
+	if n == 1 {
+		return i18n.T(id+".one", n)
+	}
+	return i18n.T(id+".other", n)
+}
+
+// sessionCounters keeps SessionStats as the network goroutines update them
+type sessionCounters struct {
+	started       time.Time
+	sent          atomic.Int32
+	received      atomic.Int32
+	filesSent     atomic.Int32
+	filesReceived atomic.Int32
+}
+
+// message counts a chat or private message, own ones as sent
+func (s *sessionCounters) message(own bool) {
+	if own {
+		s.sent.Add(1)
+	} else {
+		s.received.Add(1)
+	}
+}
+
+// snapshot returns the counts so far
+func (s *sessionCounters) snapshot() SessionStats {
+	return SessionStats{
+		Duration:      time.Since(s.started),
+		Sent:          int(s.sent.Load()),
+		Received:      int(s.received.Load()),
+		FilesSent:     int(s.filesSent.Load()),
+		FilesReceived: int(s.filesReceived.Load()),
+	}
+}
+
+// Stats returns what has happened since the host started
+func (h *Host) Stats() SessionStats {
+	return h.stats.snapshot()
+}
+
+// Stats returns what has happened since the client joined
+func (c *ChatClient) Stats() SessionStats {
+	return c.stats.snapshot()
+}
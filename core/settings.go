@@ -1,21 +1,159 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cabinchat/logger"
+	"cabinchat/media"
+)
+
+// MaxFileSize is the largest file that can be sent or received
+const MaxFileSize = 5 * 1024 * 1024
 
 // Settings holds user-configurable options
 var Settings = struct {
-	Nick  string
-	Sound bool
-	Port  int
+	Nick        string
+	Sound       bool
+	Port        int
+	AutoPort    bool   // Host on an OS-assigned port when Port is taken
+	DownloadDir string // Where received files are saved, set by /savedir; "" = ask in the desktop app, current dir in the terminal
+	Unzip       bool   // Unpack received .zip files, such as sent folders, into DownloadDir
+	MaxClients  int    // Joined clients the host accepts, 0 = unlimited
+	RoomName    string // Name the room is advertised under, "" = hostname
+	Motd        string // Welcome message the host sends each joiner, "" = none
+	WebhookURL  string // Host: where room events are POSTed as JSON, "" = nowhere
+	Advertise   bool   // Announce the room via mDNS, false = reachable by IP only
+	LogLevel    string // debug, info, warn or error
+	Locale      string // Language of the app and of rooms we host, "en" = built-in English
+	LocaleDir   string // Where <locale>.json message catalogs are loaded from
+	Theme       string // "auto" (follow the OS), "light" or "dark"
+	Markdown    bool   // Render **bold**, *italic* and `code` in messages
+	Join        string // Room link or address to join at startup instead of discovering
+
+	// Observers join without being announced or listed
+	Observe        bool // Join rooms as an observer
+	AllowObservers bool // Host: admit observers instead of turning them away
+
+	// TLS between clients and host
+	TLS            bool   // Host: only accept TLS connections
+	TLSCert        string // Host: certificate file, "" = generate a self-signed one
+	TLSKey         string // Host: key file for TLSCert
+	TLSFingerprint string // Client: certificate fingerprint to pin when the link has none
+
+	AutoAcceptFrom []string             // Senders whose file offers are accepted without asking, as nick@ip; see IsTrusted
+	Bans           []Ban                // Addresses kept out of hosted rooms, kept up to date by the host
+	Aliases        []Alias              // Local display names and colors for other users' nicks
+	Bells          map[BellEvent]string // Terminal cue per event: "off", "sound", a number of beeps or a command; unset = default
+	MaxTransfers   int                  // Files sent, received or relayed at once; more wait their turn, 0 = unlimited
+
+	RediscoverTimeout time.Duration // How long a client looks for a lost room before giving up
+	WriteTimeout      time.Duration // Drop a connection that accepts no data for this long, 0 = wait forever
+	IdleAway          time.Duration // Mark the user away after this long without typing, 0 = never
+
+	// Desktop notifications while the window is unfocused
+	Notify       bool
+	NotifyWindow time.Duration // Messages within this window are summarized together
+	MentionsOnly bool          // Only sound and notify for messages that @mention us
+
+	// Flood protection (enforced by the host per client)
+	MsgRate         float64 // Messages per second a client may sustain
+	MsgBurst        int     // Messages a client may send in a quick burst
+	OffersPerMinute int     // File offers a client may make per minute
+	FloodStrikes    int     // Dropped messages in a row before a kick
+	MaxMessageLen   int     // Characters in a chat message; longer ones are cut
+
+	// Kiosk-style hosts
+	IdleTimeout time.Duration // Close the room after this long without messages, 0 = never
+	IdleKick    bool          // Instead disconnect each client quiet for IdleTimeout
 }{
-	Nick:  "",
-	Sound: true,
-	Port:  7777,
+	Nick:         "",
+	Sound:        true,
+	Port:         7777,
+	DownloadDir:  "",
+	MaxClients:   32,
+	MaxTransfers: 3,
+	RoomName:     "",
+	Advertise:    true,
+	LogLevel:     "warn",
+	Locale:       "en",
+	LocaleDir:    "locales",
+	Theme:        "auto",
+	Markdown:     true,
+
+	RediscoverTimeout: 30 * time.Second,
+	WriteTimeout:      10 * time.Second,
+	IdleAway:          10 * time.Minute,
+
+	Notify:       true,
+	NotifyWindow: 3 * time.Second,
+
+	MsgRate:         3,
+	MsgBurst:        10,
+	OffersPerMinute: 5,
+	FloodStrikes:    20,
+	MaxMessageLen:   4000,
+}
+
+// soundInterval is the least time between two plays of the same sound, so a
+// burst of messages rings once instead of continuously
+const soundInterval = 500 * time.Millisecond
+
+var (
+	soundMu     sync.Mutex
+	soundPlayed = make(map[any]time.Time) // when each media.Sound or BellEvent last played
+)
+
+// soundDue reports whether kind, a media.Sound or BellEvent, may play now,
+// noting it as played if so
+func soundDue(kind any) bool {
+	soundMu.Lock()
+	defer soundMu.Unlock()
+	now := time.Now()
+	if now.Sub(soundPlayed[kind]) < soundInterval {
+		return false
+	}
+	soundPlayed[kind] = now
+	return true
 }
 
-// PlayBell plays a terminal bell sound for notifications
-func PlayBell() {
-	if Settings.Sound {
+// PlaySound plays a notification sound if Settings.Sound is on, falling back
+// to the terminal bell when no audio device is available. Repeats of a sound
+// within soundInterval are dropped.
+func PlaySound(kind media.Sound) {
+	if !Settings.Sound || !soundDue(kind) {
+		return
+	}
+	if err := media.PlaySound(kind); err != nil {
+		logger.Debugf("Playing sound: %v", err)
 		fmt.Print("\a")
 	}
 }
+
+// IsTrusted reports whether file offers from nick, connected from the IP
+// addr, are auto-accepted. Anyone can take a nick, so trust also needs the
+// address it was given to; with no address (offers relayed by older hosts)
+// nothing is trusted.
+func IsTrusted(nick string, addr string) bool {
+	if addr == "" {
+		return false
+	}
+	return slices.ContainsFunc(Settings.AutoAcceptFrom, func(trusted string) bool {
+		return strings.EqualFold(trusted, trustedSender(nick, addr))
+	})
+}
+
+// TrustSender adds nick at addr to Settings.AutoAcceptFrom
+func TrustSender(nick string, addr string) {
+	if addr != "" && !IsTrusted(nick, addr) {
+		Settings.AutoAcceptFrom = append(Settings.AutoAcceptFrom, trustedSender(nick, addr))
+	}
+}
+
+// trustedSender is how a sender is kept in Settings.AutoAcceptFrom
+func trustedSender(nick string, addr string) string {
+	return nick + "@" + addr
+}
@@ -0,0 +1,30 @@
+package core
+
+import "testing"
+
+func TestIsTrustedNeedsTheAddress(t *testing.T) {
+	withSetting(t, &Settings.AutoAcceptFrom, nil)
+	TrustSender("Alice", "192.168.1.5")
+
+	tests := []struct {
+		nick, addr string
+		want       bool
+	}{
+		{"Alice", "192.168.1.5", true},
+		{"alice", "192.168.1.5", true},
+		{"Alice", "192.168.1.6", false}, // someone else took the nick
+		{"Alice", "", false},            // relayed by a host that doesn't say
+		{"Bob", "192.168.1.5", false},
+	}
+	for _, tt := range tests {
+		if got := IsTrusted(tt.nick, tt.addr); got != tt.want {
+			t.Errorf("IsTrusted(%q, %q) = %v, want %v", tt.nick, tt.addr, got, tt.want)
+		}
+	}
+
+	TrustSender("alice", "192.168.1.5")
+	TrustSender("Alice", "")
+	if got := len(Settings.AutoAcceptFrom); got != 1 {
+		t.Errorf("AutoAcceptFrom = %q, want the one entry", Settings.AutoAcceptFrom)
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// roomCounters counts what has gone through the room since it opened, for
+// /stats. Unlike sessionCounters it counts everyone's traffic.
+type roomCounters struct {
+	messages atomic.Int32 // chat and private messages
+	files    atomic.Int32 // files sent by the host or relayed for clients
+}
+
+// roomStats describes the room for /stats as every member sees it
+func (h *Host) roomStats() string {
+	h.mutex.RLock()
+	users := 1 // the host
+	for _, client := range h.clients {
+		if !client.observer {
+			users++
+		}
+	}
+	h.mutex.RUnlock()
+
+	return i18n.T("stats.room",
+		time.Since(h.stats.started).Round(time.Second),
+		plural(users, "count.user"),
+		plural(int(h.room.messages.Load()), "count.message"),
+		plural(int(h.room.files.Load()), "count.file"))
+}
+
+// hostStats is /stats for the host: the room as members see it, and what
+// only the host knows about
+func (h *Host) hostStats() string {
+	h.mutex.RLock()
+	observers := 0
+	for _, client := range h.clients {
+		if client.observer {
+			observers++
+		}
+	}
+	offers := len(h.pendingOffers) + len(h.hostOffers)
+	bans := len(h.bans)
+	address := h.address
+	h.mutex.RUnlock()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, h.roomStats())
+	fmt.Fprintln(&b, i18n.T("stats.host",
+		address, plural(observers, "count.observer"), plural(offers, "count.fileOffer"), plural(bans, "count.ban")))
+	return b.String()
+}
+
+// requestStats asks the host for /stats, timing the answer as our latency
+func (c *ChatClient) requestStats() error {
+	c.statsAsked = time.Now()
+	return SendMessage(c.hostConn(), Message{Type: MsgTypeStats, Nick: c.nick})
+}
+
+// showStats shows the host's /stats answer with our latency to it
+func (c *ChatClient) showStats(msg Message) {
+	if c.callbacks.OnSystemMessage == nil {
+		return
+	}
+	text := msg.Text
+	if !c.statsAsked.IsZero() {
+		text = i18n.T("stats.latency", text, time.Since(c.statsAsked).Milliseconds())
+		c.statsAsked = time.Time{}
+	}
+	c.callbacks.OnSystemMessage(text)
+}
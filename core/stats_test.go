@@ -0,0 +1,58 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsCountTheRoomsTraffic(t *testing.T) {
+	withSetting(t, &Settings.AutoAcceptFrom, nil)
+	TrustSender("alice", PipeHost)
+	hostMsgs, onHostMsg := collect[Message]()
+	files, onFile := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{
+		OnMessageReceived: onHostMsg,
+		OnFileReceived:    func(name string, data []byte, from string, err error) { onFile(name) },
+	})
+	system, onSystem := collect[string]()
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnSystemMessage: onSystem, OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+	if got := h.roomStats(); !strings.HasSuffix(got, ": 2 users, 0 messages, 0 files") {
+		t.Errorf("fresh room stats = %q", got)
+	}
+
+	alice.SendText("hi")
+	h.SendText("hello")
+	receive(t, hostMsgs)
+	receive(t, hostMsgs)
+	alice.OfferBytes("notes.txt", []byte("hello"), "host")
+	receive(t, files)
+	eventually(t, "the file is counted", func() bool { return h.room.files.Load() == 1 })
+	want := ": 2 users, 2 messages, 1 file"
+	if got := h.roomStats(); !strings.HasSuffix(got, want) {
+		t.Errorf("room stats = %q, want it to end %q", got, want)
+	}
+
+	alice.SendText("/stats")
+	got := receiveUntil(t, system, func(text string) bool { return strings.HasPrefix(text, "Room up") })
+	if !strings.Contains(got, want+"; your latency ") {
+		t.Errorf("alice's /stats = %q, want the room's counts and her latency", got)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 files"},
+		{1, "1 file"},
+		{2, "2 files"},
+	}
+	for _, tt := range tests {
+		if got := plural(tt.n, "count.file"); got != tt.want {
+			t.Errorf("plural(%d, count.file) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"cabinchat/logger"
+)
+
+// ErrFingerprintMismatch is returned when a TLS host's certificate doesn't
+// match the pinned fingerprint
+var ErrFingerprintMismatch = errors.New("host certificate does not match the pinned fingerprint")
+
+// tlsCertificate loads Settings.TLSCert and Settings.TLSKey, or generates a
+// throwaway self-signed certificate for the room name when they aren't set
+func tlsCertificate(name string) (tls.Certificate, error) {
+	if Settings.TLSCert != "" {
+		return tls.LoadX509KeyPair(Settings.TLSCert, Settings.TLSKey)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// certFingerprint is the hex SHA-256 of a DER certificate, as shown to users for pinning
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// listenTLS wraps the listener of the room called name in TLS, returning
+// the certificate's fingerprint
+func listenTLS(listener net.Listener, name string) (net.Listener, string, error) {
+	cert, err := tlsCertificate(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return tls.NewListener(listener, config), certFingerprint(cert.Certificate[0]), nil
+}
+
+// dialRoom connects to a room, over TLS if it uses it. Self-signed host
+// certificates can't be verified against a CA, so the connection is checked
+// against the room's or Settings' pinned fingerprint when there is one.
+func dialRoom(transport Transport, room DiscoveredRoom) (net.Conn, error) {
+	conn, err := transport.Dial(room.Address())
+	if err != nil || !room.TLS {
+		return conn, err
+	}
+
+	pin := room.Fingerprint
+	if pin == "" {
+		pin = Settings.TLSFingerprint
+	}
+	config := &tls.Config{
+		InsecureSkipVerify: true, // replaced by the fingerprint check below
+		MinVersion:         tls.VersionTLS12,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return ErrFingerprintMismatch
+			}
+			got := certFingerprint(state.PeerCertificates[0].Raw)
+			if pin == "" {
+				logger.Warnf("Connected to %s without a pinned fingerprint; host's is %s", room.Address(), got)
+				return nil
+			}
+			if !strings.EqualFold(got, pin) {
+				return ErrFingerprintMismatch
+			}
+			return nil
+		},
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// Fingerprint returns the TLS certificate fingerprint clients can pin, "" without TLS
+func (h *Host) Fingerprint() string {
+	return h.fingerprint
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedCertificateNamesTheRoom(t *testing.T) {
+	withSetting(t, &Settings.TLSCert, "")
+	for _, name := range []string{"kitchen", "attic"} {
+		cert, err := tlsCertificate(name)
+		if err != nil {
+			t.Fatalf("tlsCertificate(%q): %v", name, err)
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Subject.CommonName != name {
+			t.Errorf("certificate for %q is issued to %q", name, parsed.Subject.CommonName)
+		}
+	}
+}
+
+func TestTLSRoomOverPipe(t *testing.T) {
+	withSetting(t, &Settings.TLS, true)
+	withSetting(t, &Settings.TLSCert, "")
+	hostMsgs, onHostMsg := collect[Message]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg})
+	if h.Fingerprint() == "" {
+		t.Fatal("TLS room has no fingerprint")
+	}
+
+	room := DiscoveredRoom{Host: PipeHost, Port: h.config.Port, TLS: true, Fingerprint: h.Fingerprint()}
+	users, onUsers := collect[[]string]()
+	alice, err := NewChatClient(room, "alice", nil, ClientCallbacks{OnUserList: onUsers}, transport)
+	if err != nil {
+		t.Fatalf("joining with the right fingerprint: %v", err)
+	}
+	alice.Start()
+	defer alice.Close()
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+	alice.SendText("secret")
+	if msg := receive(t, hostMsgs); msg.Text != "secret" {
+		t.Errorf("host got %q", msg.Text)
+	}
+
+	room.Fingerprint = strings.Repeat("0", 64)
+	if _, err := NewChatClient(room, "mallory", nil, ClientCallbacks{}, transport); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("joining with the wrong fingerprint: err = %v, want ErrFingerprintMismatch", err)
+	}
+}
@@ -0,0 +1,31 @@
+package core
+
+import "cabinchat/i18n"
+
+// Topic returns the room's current topic
+func (h *Host) Topic() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.topic
+}
+
+// setTopic changes the room topic and announces it to everyone
+func (h *Host) setTopic(topic string) {
+	h.mutex.Lock()
+	h.topic = topic
+	h.mutex.Unlock()
+
+	h.broadcast(Message{Type: MsgTypeTopic, Nick: h.nick, Text: topic}, nil)
+	if h.callbacks.OnTopic != nil {
+		h.callbacks.OnTopic(topic)
+	}
+
+	text := i18n.T("topic.set", h.nick, topic)
+	if topic == "" {
+		text = i18n.T("topic.cleared", h.nick)
+	}
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(text)
+	}
+	h.broadcast(Message{Type: MsgTypeSystem, Text: text}, nil)
+}
@@ -0,0 +1,51 @@
+package core
+
+import "testing"
+
+func TestTopicReachesLateJoiners(t *testing.T) {
+	hostTopics, onHostTopic := collect[string]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnTopic: onHostTopic})
+	users, onUsers := collect[[]string]()
+	aliceTopics, onAliceTopic := collect[string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers, OnTopic: onAliceTopic})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	h.SendText("/topic Ski trip")
+	if topic := receive(t, hostTopics); topic != "Ski trip" {
+		t.Errorf("host's topic = %q", topic)
+	}
+	if topic := receive(t, aliceTopics); topic != "Ski trip" {
+		t.Errorf("alice's topic = %q", topic)
+	}
+
+	bobTopics, onBobTopic := collect[string]()
+	joinTestRoom(t, transport, h, "bob", ClientCallbacks{OnTopic: onBobTopic})
+	if topic := receive(t, bobTopics); topic != "Ski trip" {
+		t.Errorf("bob joined to topic %q, want the current one", topic)
+	}
+
+	h.SendText("/topic")
+	if topic := receive(t, aliceTopics); topic != "" {
+		t.Errorf("alice's topic = %q after clearing", topic)
+	}
+	if h.Topic() != "" {
+		t.Errorf("Topic() = %q after clearing", h.Topic())
+	}
+	if topic := receive(t, bobTopics); topic != "" {
+		t.Errorf("bob's topic = %q after clearing", topic)
+	}
+}
+
+func TestNoTopicSentWhenUnset(t *testing.T) {
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	users, onUsers := collect[[]string]()
+	topics, onTopic := collect[string]()
+	joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers, OnTopic: onTopic})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	select {
+	case topic := <-topics:
+		t.Errorf("alice got topic %q in a room without one", topic)
+	default:
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"cabinchat/i18n"
+)
+
+// Transfer is a file offer still waiting on an answer, as listed by /transfers
+type Transfer struct {
+	Incoming bool
+	Peer     string // who offered it, or who it was offered to ("" = everyone)
+	Filename string
+	Size     string
+}
+
+// String describes the transfer for /transfers
+func (t Transfer) String() string {
+	if t.Incoming {
+		return i18n.T("transfer.incoming", t.Filename, t.Size, t.Peer)
+	}
+	peer := t.Peer
+	if peer == "" {
+		peer = i18n.T("transfer.everyone")
+	}
+	return i18n.T("transfer.outgoing", t.Filename, t.Size, peer)
+}
+
+// transferList numbers transfers for /transfers, so /cancel can refer to them
+func transferList(transfers []Transfer) string {
+	if len(transfers) == 0 {
+		return i18n.T("transfer.none") + "\n"
+	}
+	var b strings.Builder
+	for i, t := range transfers {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, t)
+	}
+	return b.String()
+}
+
+// transferSlots limits how many files are in memory at once, see
+// Settings.MaxTransfers. A nil transferSlots is unlimited.
+type transferSlots chan struct{}
+
+func newTransferSlots(n int) transferSlots {
+	if n <= 0 {
+		return nil
+	}
+	return make(transferSlots, n)
+}
+
+// acquire takes a slot, calling queued first if it has to wait for one. It
+// gives up, returning false, if done is closed while waiting.
+func (s transferSlots) acquire(done <-chan struct{}, queued func()) bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+	}
+	if queued != nil {
+		queued()
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (s transferSlots) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// Transfers lists the file offers made to the host that it hasn't answered
+func (h *Host) Transfers() []Transfer {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	var transfers []Transfer
+	for _, offer := range h.hostOffers {
+		transfers = append(transfers, Transfer{Incoming: true, Peer: offer.SenderNick, Filename: offer.Filename, Size: offer.Size})
+	}
+	return transfers
+}
+
+// cancelTransfer declines the nth offer from /transfers, returning local output
+func (h *Host) cancelTransfer(n int) string {
+	transfers := h.Transfers()
+	if n < 1 || n > len(transfers) {
+		return i18n.T("transfer.notFound", n) + "\n"
+	}
+	offer := h.takeHostOffer(transfers[n-1].Peer)
+	if offer == nil {
+		return i18n.T("transfer.notFound", n) + "\n"
+	}
+	SendMessage(offer.SenderConn, Message{Type: MsgTypeFileRej, Nick: h.nick})
+	return i18n.T("transfer.declined", offer.Filename, offer.SenderNick) + "\n"
+}
+
+// withdrawOffer handles a client cancelling its file offer: the offer is
+// forgotten and whoever it was made to is told
+func (h *Host) withdrawOffer(client *Client) {
+	offer := h.takePendingOffer(client.nick)
+	if offer == nil {
+		return
+	}
+	for h.takeHostOffer(client.nick) != nil {
+	}
+
+	cancel := Message{Type: MsgTypeFileCancel, Nick: client.nick, Text: offer.Filename}
+	if offer.RecipientNick == "" {
+		h.broadcast(cancel, client.conn)
+	} else if offer.RecipientNick != h.nick {
+		h.sendToNick(offer.RecipientNick, cancel)
+	}
+	if h.callbacks.OnSystemMessage != nil {
+		h.callbacks.OnSystemMessage(i18n.T("file.withdrawn", client.nick, offer.Filename))
+	}
+}
+
+// Transfers lists our file offer awaiting an answer and the offer awaiting ours
+func (c *ChatClient) Transfers() []Transfer {
+	var transfers []Transfer
+	c.offerMu.Lock()
+	if c.lastOfferedFile != "" {
+		transfers = append(transfers, Transfer{
+			Peer:     c.lastOfferedTo,
+			Filename: c.lastOfferedFile,
+			Size:     FormatSize(int64(len(c.lastOfferedData))),
+		})
+	}
+	if c.pendingFile != nil {
+		transfers = append(transfers, Transfer{
+			Incoming: true,
+			Peer:     c.pendingFile.From,
+			Filename: c.pendingFile.Filename,
+			Size:     c.pendingFile.Size,
+		})
+	}
+	c.offerMu.Unlock()
+	return transfers
+}
+
+// cancelTransfer withdraws our offer or declines the incoming one, by its
+// number in /transfers, returning local output
+func (c *ChatClient) cancelTransfer(n int) string {
+	transfers := c.Transfers()
+	if n < 1 || n > len(transfers) {
+		return i18n.T("transfer.notFound", n) + "\n"
+	}
+	t := transfers[n-1]
+	if t.Incoming {
+		SendMessage(c.hostConn(), Message{Type: MsgTypeFileRej, Nick: c.nick, Text: t.Peer})
+		c.takePendingFile(t.Peer)
+		return i18n.T("transfer.declined", t.Filename, t.Peer) + "\n"
+	}
+	SendMessage(c.hostConn(), Message{Type: MsgTypeFileCancel, Nick: c.nick, Text: t.Filename})
+	c.takeOffer()
+	return i18n.T("transfer.withdrew", t.Filename) + "\n"
+}
+
+// offerWithdrawn drops an incoming offer its sender cancelled
+func (c *ChatClient) offerWithdrawn(msg Message) {
+	if c.takePendingFile(msg.Nick) == nil {
+		return
+	}
+	if c.callbacks.OnSystemMessage != nil {
+		c.callbacks.OnSystemMessage(i18n.T("file.withdrawn", msg.Nick, msg.Text))
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"cabinchat/i18n"
+)
+
+// received is a file as handed to OnFileReceived
+type received struct {
+	name string
+	data []byte
+	from string
+}
+
+func TestTransferBeyondLimitWaits(t *testing.T) {
+	withSetting(t, &Settings.MaxTransfers, 1)
+	files := make(chan received, 10)
+	h, transport := startTestRoom(t, "host", HostCallbacks{
+		OnFileReceived: func(name string, data []byte, from string, err error) {
+			if err != nil {
+				t.Errorf("receiving %s: %v", name, err)
+			}
+			files <- received{name, data, from}
+		},
+	})
+	system, onSystem := collect[string]()
+	msgs, onMsg := collect[Message]()
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnSystemMessage: onSystem, OnMessageReceived: onMsg, OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	// Another send holds the only slot
+	alice.acquireTransfer()
+	alice.OfferBytes("notes.txt", []byte("hello"), "host")
+	eventually(t, "the host has the offer", func() bool { return len(h.Transfers()) == 1 })
+	h.SendText("/accept")
+	receiveUntil(t, system, textIs(i18n.T("file.queued")))
+
+	h.SendText("still there?")
+	if msg := receive(t, msgs); msg.Text != "still there?" {
+		t.Errorf("alice got %+v while the file was queued, want the host's message", msg)
+	}
+	select {
+	case f := <-files:
+		t.Fatalf("host got %s while alice had no free slot", f.name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	alice.slots.release()
+	if f := receive(t, files); f.name != "notes.txt" || string(f.data) != "hello" || f.from != "alice" {
+		t.Errorf("host got %s %q from %s, want alice's notes.txt", f.name, f.data, f.from)
+	}
+}
+
+func TestTrustedSenderIsAutoAccepted(t *testing.T) {
+	withSetting(t, &Settings.AutoAcceptFrom, nil)
+	TrustSender("alice", PipeHost)
+	files := make(chan received, 10)
+	h, transport := startTestRoom(t, "host", HostCallbacks{
+		OnFileOffer: func(offer PendingOffer) { t.Errorf("host was asked about %s", offer.Filename) },
+		OnFileReceived: func(name string, data []byte, from string, err error) {
+			files <- received{name, data, from}
+		},
+	})
+	users, onUsers := collect[[]string]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+
+	alice.OfferBytes("notes.txt", []byte("hello"), "host")
+	if f := receive(t, files); f.name != "notes.txt" || f.from != "alice" {
+		t.Errorf("host got %s from %s, want alice's notes.txt", f.name, f.from)
+	}
+}
+
+func TestRelayedOfferSaysWhereItCameFrom(t *testing.T) {
+	withSetting(t, &Settings.AutoAcceptFrom, nil)
+	h, transport := startTestRoom(t, "host", HostCallbacks{})
+	offers, onOffer := collect[PendingFile]()
+	users, onUsers := collect[[]string]()
+	joinTestRoom(t, transport, h, "bob", ClientCallbacks{OnFileOffer: onOffer, OnUserList: onUsers})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 2 })
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{})
+	receiveUntil(t, users, func(users []string) bool { return len(users) == 3 })
+
+	alice.OfferBytes("notes.txt", []byte("hello"), "bob")
+	if offer := receive(t, offers); offer.From != "alice" || offer.Addr != PipeHost {
+		t.Errorf("bob was offered %+v, want alice's offer from %s", offer, PipeHost)
+	}
+}
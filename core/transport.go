@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport opens the connections a room runs over, and tells others where
+// to find it. NewHost and NewChatClient use TCP and mDNS when given nil;
+// tests can pass a PipeTransport to run a host and its clients in one
+// process without touching the network.
+type Transport interface {
+	Listen(addr string) (net.Listener, error)
+	Dial(addr string) (net.Conn, error)
+	LocalIP() string                                          // Host part of the address clients connect to
+	Advertise(name string, port int) (stop func(), err error) // Announce a room until stop is called
+}
+
+// netTransport is the default TCP transport, advertising rooms over mDNS
+type netTransport struct{}
+
+func (netTransport) Listen(addr string) (net.Listener, error) { return net.Listen("tcp", addr) }
+func (netTransport) Dial(addr string) (net.Conn, error)       { return net.Dial("tcp", addr) }
+func (netTransport) LocalIP() string                          { return getLocalIP() }
+
+func (netTransport) Advertise(name string, port int) (func(), error) {
+	server, err := StartMDNSAdvertisement(name, port)
+	if err != nil {
+		return nil, err
+	}
+	return server.Shutdown, nil
+}
+
+// transportOrDefault returns t, or TCP when t is nil
+func transportOrDefault(t Transport) Transport {
+	if t == nil {
+		return netTransport{}
+	}
+	return t
+}
+
+// PipeTransport connects hosts and clients in memory. Listeners are keyed
+// by port, so a client dialling any host on a port reaches whoever listens
+// on it.
+type PipeTransport struct {
+	mu        sync.Mutex
+	listeners map[string]*pipeListener
+	nextPort  int // handed out for port 0
+	dialed    int // connections made, numbering their client ends
+}
+
+// PipeHost is the host part of pipe addresses. It isn't an IP, so a pipe
+// room is never mistaken for one hosted on this machine's network.
+const PipeHost = "pipe"
+
+// firstPipePort is the first port handed out for port 0, as an OS would
+// pick an ephemeral one
+const firstPipePort = 49152
+
+// NewPipeTransport creates an in-memory transport
+func NewPipeTransport() *PipeTransport {
+	return &PipeTransport{listeners: make(map[string]*pipeListener), nextPort: firstPipePort}
+}
+
+// Listen starts accepting pipe connections on addr's port, or on a free one
+// for port 0
+func (t *PipeTransport) Listen(addr string) (net.Listener, error) {
+	port, err := pipePort(addr)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if port == "0" {
+		for t.listeners[strconv.Itoa(t.nextPort)] != nil {
+			t.nextPort++
+		}
+		port = strconv.Itoa(t.nextPort)
+		t.nextPort++
+	}
+	if _, ok := t.listeners[port]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrPortInUse, port)
+	}
+	l := &pipeListener{
+		port:  port,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+		owner: t,
+	}
+	t.listeners[port] = l
+	return l, nil
+}
+
+// Dial connects to whoever listens on addr's port
+func (t *PipeTransport) Dial(addr string) (net.Conn, error) {
+	port, err := pipePort(addr)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	l, ok := t.listeners[port]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dial pipe %s: connection refused", addr)
+	}
+
+	t.mu.Lock()
+	t.dialed++
+	clientAddr := pipeAddr(net.JoinHostPort(PipeHost, strconv.Itoa(t.dialed)))
+	t.mu.Unlock()
+	server, client := newPipeConns(pipeAddr(net.JoinHostPort(PipeHost, port)), clientAddr)
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		server.Close()
+		client.Close()
+		return nil, fmt.Errorf("dial pipe %s: %w", addr, net.ErrClosed)
+	}
+}
+
+// LocalIP returns PipeHost, which Dial ignores
+func (t *PipeTransport) LocalIP() string { return PipeHost }
+
+// Advertise does nothing: pipe rooms are joined by port
+func (t *PipeTransport) Advertise(name string, port int) (func(), error) {
+	return func() {}, nil
+}
+
+// pipePort is the port part of a host:port or :port address
+func pipePort(addr string) (string, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("pipe address %q: %w", addr, err)
+	}
+	return port, nil
+}
+
+// pipeListener hands the server ends of dialled pipes to Accept
+type pipeListener struct {
+	port      string
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+	owner     *PipeTransport
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.owner.mu.Lock()
+		delete(l.owner.listeners, l.port)
+		l.owner.mu.Unlock()
+	})
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(":" + l.port) }
+
+// pipeAddr is the address of a pipe listener
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is one end of an in-memory connection. Unlike net.Pipe's, its
+// writes are buffered as a socket's are, so a host and a client writing to
+// each other at the same time don't deadlock.
+type pipeConn struct {
+	in, out       *pipeBuffer
+	local, remote net.Addr
+}
+
+// newPipeConns returns the two ends of a connection between addresses a and b
+func newPipeConns(a, b net.Addr) (*pipeConn, *pipeConn) {
+	ab, ba := newPipeBuffer(), newPipeBuffer()
+	return &pipeConn{in: ba, out: ab, local: a, remote: b},
+		&pipeConn{in: ab, out: ba, local: b, remote: a}
+}
+
+func (c *pipeConn) Read(p []byte) (int, error)  { return c.in.read(p) }
+func (c *pipeConn) Write(p []byte) (int, error) { return c.out.write(p) }
+func (c *pipeConn) LocalAddr() net.Addr         { return c.local }
+func (c *pipeConn) RemoteAddr() net.Addr        { return c.remote }
+
+// Close ends both directions; unread data is discarded
+func (c *pipeConn) Close() error {
+	c.in.close(true)
+	c.out.close(false)
+	return nil
+}
+
+// CloseWrite lets the other end read what was sent, then EOF
+func (c *pipeConn) CloseWrite() error {
+	c.out.close(false)
+	return nil
+}
+
+func (c *pipeConn) SetDeadline(t time.Time) error     { return c.SetReadDeadline(t) }
+func (c *pipeConn) SetReadDeadline(t time.Time) error { return c.in.setDeadline(t) }
+
+// SetWriteDeadline does nothing, as writes never block
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// pipeBuffer carries one direction of a pipeConn
+type pipeBuffer struct {
+	mu       sync.Mutex
+	data     bytes.Buffer
+	closed   bool
+	deadline time.Time
+	wake     chan struct{} // signalled when data arrives, the buffer closes or the deadline moves
+}
+
+func newPipeBuffer() *pipeBuffer {
+	return &pipeBuffer{wake: make(chan struct{}, 1)}
+}
+
+// signal wakes a waiting reader
+func (b *pipeBuffer) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *pipeBuffer) write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	b.data.Write(p)
+	b.signal()
+	return len(p), nil
+}
+
+// read waits for data, returning io.EOF once the buffer is closed and drained
+func (b *pipeBuffer) read(p []byte) (int, error) {
+	for {
+		b.mu.Lock()
+		if b.data.Len() > 0 {
+			n, _ := b.data.Read(p)
+			b.mu.Unlock()
+			return n, nil
+		}
+		closed, deadline := b.closed, b.deadline
+		b.mu.Unlock()
+		if closed {
+			return 0, io.EOF
+		}
+		if err := b.wait(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// wait blocks until signalled or deadline, if set, passes
+func (b *pipeBuffer) wait(deadline time.Time) error {
+	if deadline.IsZero() {
+		<-b.wake
+		return nil
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-b.wake:
+		return nil
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// close stops further writes; discard also drops what hasn't been read
+func (b *pipeBuffer) close(discard bool) {
+	b.mu.Lock()
+	b.closed = true
+	if discard {
+		b.data.Reset()
+	}
+	b.mu.Unlock()
+	b.signal()
+}
+
+func (b *pipeBuffer) setDeadline(t time.Time) error {
+	b.mu.Lock()
+	b.deadline = t
+	b.mu.Unlock()
+	b.signal()
+	return nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTimeout bounds every wait for something to arrive over a pipe
+const testTimeout = 2 * time.Second
+
+// startTestRoom hosts a room as nick over a fresh PipeTransport, shut down
+// when the test ends
+func startTestRoom(t *testing.T, nick string, callbacks HostCallbacks) (*Host, *PipeTransport) {
+	t.Helper()
+	transport := NewPipeTransport()
+	h := newRoom(nick, nil, callbacks, transport, roomConfig{Name: "test"})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(h.Shutdown)
+	return h, transport
+}
+
+// joinTestRoom connects nick to h's room and starts its receive loop. The
+// client is closed when the test ends, before the room shuts down.
+func joinTestRoom(t *testing.T, transport *PipeTransport, h *Host, nick string, callbacks ClientCallbacks) *ChatClient {
+	t.Helper()
+	room := DiscoveredRoom{Host: PipeHost, Port: h.config.Port}
+	c, err := NewChatClient(room, nick, nil, callbacks, transport)
+	if err != nil {
+		t.Fatalf("joining as %s: %v", nick, err)
+	}
+	c.Start()
+	t.Cleanup(c.Close)
+	return c
+}
+
+// receive waits for the next value on ch
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(testTimeout):
+		var zero T
+		t.Fatalf("timed out waiting for %T", zero)
+		return zero
+	}
+}
+
+// receiveUntil waits for a value on ch that satisfies match, skipping others
+func receiveUntil[T any](t *testing.T, ch <-chan T, match func(T) bool) T {
+	t.Helper()
+	deadline := time.After(testTimeout)
+	for {
+		select {
+		case v := <-ch:
+			if match(v) {
+				return v
+			}
+		case <-deadline:
+			var zero T
+			t.Fatalf("timed out waiting for a matching %T", zero)
+			return zero
+		}
+	}
+}
+
+// textIs matches a string equal to want
+func textIs(want string) func(string) bool {
+	return func(got string) bool { return got == want }
+}
+
+// collect returns a buffered channel and a callback feeding it, for
+// watching callbacks from other goroutines
+func collect[T any]() (chan T, func(T)) {
+	ch := make(chan T, 100)
+	return ch, func(v T) { ch <- v }
+}
+
+func TestPipeTransportPicksFreePort(t *testing.T) {
+	transport := NewPipeTransport()
+	first, port1, err := listen(transport, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, port2, err := listen(transport, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if port1 == 0 || port2 == 0 || port1 == port2 {
+		t.Errorf("listen(0) bound ports %d and %d, want two distinct free ports", port1, port2)
+	}
+}
+
+func TestPipeRoomJoinAndChat(t *testing.T) {
+	hostMsgs, onHostMsg := collect[Message]()
+	h, transport := startTestRoom(t, "host", HostCallbacks{OnMessageReceived: onHostMsg})
+	if got := h.Address(); !strings.HasPrefix(got, PipeHost+":") {
+		t.Errorf("Address() = %q, want a %s address", got, PipeHost)
+	}
+
+	aliceUsers, onAliceUsers := collect[[]string]()
+	aliceMsgs, onAliceMsg := collect[Message]()
+	alice := joinTestRoom(t, transport, h, "alice", ClientCallbacks{OnUserList: onAliceUsers, OnMessageReceived: onAliceMsg})
+	receiveUntil(t, aliceUsers, func(users []string) bool { return len(users) == 2 })
+
+	if _, err := alice.SendText("hello"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if msg := receive(t, hostMsgs); msg.Nick != "alice" || msg.Text != "hello" {
+		t.Errorf("host got %+v, want alice's hello", msg)
+	}
+	if msg := receive(t, aliceMsgs); msg.Nick != "alice" || msg.Text != "hello" {
+		t.Errorf("alice got %+v, want her own hello echoed", msg)
+	}
+
+	h.SendText("hi alice")
+	receive(t, hostMsgs)
+	if msg := receive(t, aliceMsgs); msg.Nick != "host" || msg.Text != "hi alice" {
+		t.Errorf("alice got %+v, want the host's reply", msg)
+	}
+}
+
+// withSetting sets one of Settings' fields for the rest of the test
+func withSetting[T any](t *testing.T, field *T, value T) {
+	t.Helper()
+	old := *field
+	*field = value
+	t.Cleanup(func() { *field = old })
+}
+
+// eventually waits for cond to hold, polling it
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBusyPort(t *testing.T) {
+	for name, transport := range map[string]Transport{"tcp": netTransport{}, "pipe": NewPipeTransport()} {
+		t.Run(name, func(t *testing.T) {
+			taken, port, err := listen(transport, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer taken.Close()
+
+			withSetting(t, &Settings.AutoPort, false)
+			if _, _, err := listen(transport, port); !errors.Is(err, ErrPortInUse) {
+				t.Errorf("listen on a taken port: err = %v, want ErrPortInUse", err)
+			}
+
+			Settings.AutoPort = true
+			other, got, err := listen(transport, port)
+			if err != nil {
+				t.Fatalf("listen with AutoPort: %v", err)
+			}
+			defer other.Close()
+			if got == 0 || got == port {
+				t.Errorf("AutoPort bound port %d, want a free one other than %d", got, port)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"slices"
+	"strings"
+)
+
+// userEntry is one member of the room as the client keeps it
+type userEntry struct {
+	nick string
+	host bool
+	away bool
+}
+
+// userList is the client's copy of who is in the room: a snapshot from the
+// host on joining, then kept current by the host's deltas. It's only
+// touched by the read loop.
+type userList []userEntry
+
+// parseUserList reads a MsgTypeUserList snapshot, as built by getUserList
+func parseUserList(text string) userList {
+	var users userList
+	for _, name := range strings.Split(text, ", ") {
+		if name == "" {
+			continue
+		}
+		nick, status, _ := strings.Cut(name, " (")
+		users = append(users, userEntry{
+			nick: nick,
+			host: strings.Contains(status, "host)"),
+			away: strings.Contains(status, "away)"),
+		})
+	}
+	return users
+}
+
+// names lists the users the way getUserList does
+func (l userList) names() []string {
+	names := make([]string, len(l))
+	for i, user := range l {
+		names[i] = user.nick
+		if user.host {
+			names[i] += " (host)"
+		}
+		if user.away {
+			names[i] += " (away)"
+		}
+	}
+	return names
+}
+
+func (l userList) index(nick string) int {
+	return slices.IndexFunc(l, func(user userEntry) bool { return user.nick == nick })
+}
+
+// apply updates the list from a host snapshot or delta
+func (l *userList) apply(msg Message) {
+	switch msg.Type {
+	case MsgTypeUserList:
+		*l = parseUserList(msg.Text)
+	case MsgTypeUserJoined:
+		if l.index(msg.Nick) < 0 {
+			*l = append(*l, userEntry{nick: msg.Nick})
+		}
+	case MsgTypeUserLeft:
+		if i := l.index(msg.Nick); i >= 0 {
+			*l = slices.Delete(*l, i, i+1)
+		}
+	case MsgTypeUserRenamed:
+		if i := l.index(msg.Nick); i >= 0 {
+			(*l)[i].nick = msg.Text
+		}
+	case MsgTypeAway:
+		if i := l.index(msg.Nick); i >= 0 {
+			(*l)[i].away = msg.Data == awayFlag
+		}
+	}
+}
+
+// updateUsers applies a user list message and shows the result
+func (c *ChatClient) updateUsers(msg Message) {
+	c.users.apply(msg)
+	if c.callbacks.OnUserList != nil {
+		c.callbacks.OnUserList(c.users.names())
+	}
+}
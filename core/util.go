@@ -1,13 +1,48 @@
 package core
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
-	"os"
 	"strings"
 )
 
+// ErrChecksumMismatch means a received file does not match the sender's checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch, file corrupted in transfer")
+
+// fileChecksum returns the hex SHA-256 of data
+func fileChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyChecksum checks received file content against the expected checksum.
+// An empty sum (from a peer that doesn't send one) is not checked.
+func verifyChecksum(data []byte, sum string) error {
+	if sum == "" {
+		return nil
+	}
+	if fileChecksum(data) != sum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// FormatSize formats a byte count for display, e.g. 512B, 1.5KB, 2.0MB
+func FormatSize(n int64) string {
+	kb := float64(n) / 1024
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case kb < 1023.95: // anything more rounds to 1024.0KB, so show MB
+		return fmt.Sprintf("%.1fKB", kb)
+	default:
+		return fmt.Sprintf("%.1fMB", kb/1024)
+	}
+}
+
 // getLocalIP returns the preferred outbound IP of this machine
 func getLocalIP() string {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
@@ -41,18 +76,3 @@ func getSubnetIPs() []string {
 	}
 	return ips
 }
-
-// promptInput reads a line from stdin with a prompt
-func promptInput(prompt string) string {
-	fmt.Print(prompt)
-	reader := bufio.NewReader(os.Stdin)
-	line, _ := reader.ReadString('\n')
-	return strings.TrimSpace(line)
-}
-
-// promptYesNo asks a yes/no question and returns true for yes
-func promptYesNo(prompt string) bool {
-	answer := promptInput(prompt + " (y/n): ")
-	answer = strings.ToLower(answer)
-	return answer == "y" || answer == "yes"
-}
@@ -0,0 +1,24 @@
+package core
+
+import "testing"
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KB"},
+		{1048524, "1023.9KB"},
+		{1048525, "1.0MB"},
+		{1048575, "1.0MB"},
+		{1048576, "1.0MB"},
+		{5 * 1024 * 1024, "5.0MB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.n); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cabinchat/logger"
+)
+
+// Webhook event types
+const (
+	WebhookJoin    = "join"
+	WebhookLeave   = "leave"
+	WebhookMessage = "message" // public chat only, private messages stay private
+	WebhookFile    = "file"    // Text=filename
+)
+
+const (
+	webhookQueue    = 100             // events waiting to be posted; more are dropped
+	webhookAttempts = 3               // posts of one event before giving up on it
+	webhookRetry    = time.Second     // wait before the first retry, doubling after
+	webhookTimeout  = 5 * time.Second // per post
+)
+
+// WebhookEvent is the JSON body posted to Settings.WebhookURL
+type WebhookEvent struct {
+	Type   string    `json:"type"`
+	Room   string    `json:"room"`
+	Nick   string    `json:"nick"`
+	Target string    `json:"target,omitempty"` // recipient of a file sent to one user
+	Text   string    `json:"text,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// webhook posts room events to a URL from its own goroutine, so a slow or
+// unreachable endpoint never holds up the room
+type webhook struct {
+	url    string
+	events chan WebhookEvent
+	client *http.Client
+}
+
+// startWebhook posts events to url until ctx is cancelled
+func startWebhook(ctx context.Context, url string) *webhook {
+	w := &webhook{
+		url:    url,
+		events: make(chan WebhookEvent, webhookQueue),
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+	go w.run(ctx)
+	return w
+}
+
+// send queues an event, dropping it if the queue is full
+func (w *webhook) send(event WebhookEvent) {
+	select {
+	case w.events <- event:
+	default:
+		logger.Warnf("Webhook queue full, dropping %s event", event.Type)
+	}
+}
+
+func (w *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.events:
+			w.deliver(ctx, event)
+		}
+	}
+}
+
+// deliver posts an event, retrying with backoff before dropping it
+func (w *webhook) deliver(ctx context.Context, event WebhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Warnf("Webhook: %v", err)
+		return
+	}
+	wait := webhookRetry
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			logger.Warnf("Webhook: dropping %s event after %d attempts: %v", event.Type, attempt, err)
+			return
+		}
+		logger.Debugf("Webhook: %v, retrying in %s", err, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (w *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// notifyWebhook reports a room event to the webhook, if one is configured
+func (h *Host) notifyWebhook(kind string, nick string, target string, text string) {
+	if h.webhook == nil {
+		return
+	}
+	h.webhook.send(WebhookEvent{Type: kind, Room: h.Name(), Nick: nick, Target: target, Text: text, Time: time.Now()})
+}
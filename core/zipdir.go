@@ -0,0 +1,154 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxUnzipSize caps what a received archive may unpack to, so a small zip
+// can't fill the disk
+const maxUnzipSize = 20 * MaxFileSize
+
+// errTooLarge is returned for files and archives over MaxFileSize
+var errTooLarge = fmt.Errorf("too large (max %s)", FormatSize(MaxFileSize))
+
+// readForSending reads the file at path for sending, returning the name it
+// is sent under. A directory is zipped and sent as "<dir>.zip".
+func readForSending(path string) (string, []byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		data, err := zipDir(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("zipping %s: %w", path, err)
+		}
+		return filepath.Base(filepath.Clean(path)) + ".zip", data, nil
+	}
+	if info.Size() > MaxFileSize {
+		return "", nil, errTooLarge
+	}
+	data, err := os.ReadFile(path)
+	return filepath.Base(path), data, err
+}
+
+// cappedBuffer is a buffer refusing to grow past MaxFileSize
+type cappedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxFileSize {
+		return 0, errTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// zipDir archives the regular files and directories under dir in memory,
+// giving up once the archive outgrows MaxFileSize. Symlinks and other
+// special files are left out.
+func zipDir(dir string) ([]byte, error) {
+	var buf cappedBuffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			_, err := zw.Create(name + "/")
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return addToZip(zw, path, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addToZip compresses the file at path into the archive as name
+func addToZip(zw *zip.Writer, path string, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// unzip extracts an archive into dir, refusing entries that would land
+// outside it and stopping once maxUnzipSize bytes have been written
+func unzip(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	budget := int64(maxUnzipSize)
+	for _, file := range zr.File {
+		name := strings.TrimSuffix(file.Name, "/")
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path %q in archive", file.Name)
+		}
+		target := filepath.Join(dir, name)
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		n, err := extractFile(file, target, budget)
+		if err != nil {
+			return err
+		}
+		budget -= n
+	}
+	return nil
+}
+
+// extractFile writes one archive entry to target, failing if it holds more
+// than budget bytes
+func extractFile(file *zip.File, target string, budget int64) (int64, error) {
+	r, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, budget+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > budget {
+		err = fmt.Errorf("archive unpacks to more than %s", FormatSize(maxUnzipSize))
+	}
+	return n, err
+}
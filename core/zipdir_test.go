@@ -0,0 +1,28 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipThatWontUnpackIsSavedAsIs(t *testing.T) {
+	withSetting(t, &Settings.DownloadDir, t.TempDir())
+	withSetting(t, &Settings.Unzip, true)
+
+	data := []byte("not really a zip")
+	path, err := SaveFile("folder.zip", data)
+	if err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if want := filepath.Join(Settings.DownloadDir, "folder.zip"); path != want {
+		t.Errorf("saved to %s, want %s", path, want)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("saved %q (%v), want the archive as received", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(Settings.DownloadDir, "folder")); !os.IsNotExist(err) {
+		t.Errorf("the folder it would have unpacked to was left behind: %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package i18n
+
+// english is the built-in catalog and the reference for translations:
+// every message ID and the placeholders each text must keep
+var english = map[string]string{
+	// Room system messages, formatted by the host and sent to everyone
+	"host.portMoved":         "Port %d was in use, hosting on %d",
+	"host.hosting":           "Hosting room on %s",
+	"join.notJoined":         "You must join the room first",
+	"join.banned":            "You are banned from this room",
+	"join.bannedNotice":      "%s was turned away, they are banned",
+	"join.noObservers":       "This room doesn't allow observers",
+	"join.noObserversNotice": "%s was turned away, observers aren't allowed",
+	"join.full":              "Room is full (max %d users)",
+	"join.fullNotice":        "%s was turned away, room is full",
+	"room.joined":            "%s joined",
+	"room.nick":              "%s is now known as %s",
+	"flood.kicked":           "You were kicked for flooding",
+	"flood.kickedNotice":     "%s was kicked for flooding",
+	"flood.warning":          "Slow down! Messages are being dropped",
+	"room.closed":            "Room closed by host",
+	"room.left":              "%s left",
+	"room.reset":             "The host cleared the room; you can join again",
+	"room.resetNotice":       "Cleared the room, disconnecting %d users",
+	"topic.set":              "%s set the topic: %s",
+	"topic.cleared":          "%s cleared the topic",
+	"idle.roomWarning":       "The room closes in %s unless someone says something",
+	"idle.roomClosed":        "Room closed after %s without messages",
+	"idle.kickWarning":       "You'll be disconnected in %s unless you say something",
+	"idle.kickedNotice":      "%s was disconnected for being idle",
+	"idle.kicked":            "Disconnected after %s without messages",
+	"ban.banned":             "You were banned from this room",
+	"observer.joined":        "%s is observing quietly",
+	"observer.left":          "%s stopped observing",
+	"poll.closed":            "Poll closed - %s | %s",
+	"handoff.takingOver":     "%s is taking over the room, reconnecting...",
+	"away.away":              "%s is away: %s",
+	"away.back":              "%s is back",
+
+	// Notices shown to the host or a client about files, calls and the room
+	"host.fingerprint":       "TLS certificate fingerprint: %s",
+	"file.offerRelayed":      "%s offers %s to %s",
+	"file.acceptedRelayed":   "%s accepted file from %s",
+	"file.rejectedRelayed":   "%s rejected file from %s",
+	"file.sentRelayed":       "%s sent file %s to %s",
+	"file.shared":            "%s shared file %s",
+	"file.corrupted":         "%s received a corrupted copy of %s",
+	"file.autoAccepted":      "Auto-accepted %s from %s",
+	"file.autoAcceptedSized": "Auto-accepted %s (%s) from %s",
+	"file.sentTo":            "Sent %s to %s (%s)",
+	"file.sentAll":           "Sent %s to everyone (%s)",
+	"file.offeredTo":         "Offered %s (%s) to %s",
+	"file.offeredAll":        "Offered %s (%s) to everyone",
+	"file.sent":              "File sent (%s)",
+	"call.missed":            "Missed a call from %s: %v",
+	"cmd.pong":               "Pong! %dms",
+	"room.online":            "Online: %s",
+	"handoff.failed":         "%s couldn't take over the room: %s",
+	"handoff.done":           "Handed the room over to %s",
+	"file.withdrawn":         "%s withdrew %s",
+	"file.queued":            "Transfer queued.",
+	"outbox.undelivered":     "Not delivered: %s",
+	"invite.sent":            "Invited %s",
+	"invite.failed":          "Cannot invite %s: %v",
+	"msg.truncated":          "Message too long, cut to %d characters",
+
+	// Chat text that commands send on the user's behalf
+	"away.default":     "away",
+	"away.reply":       "I'm away: %s",
+	"fun.slap":         "%s slaps %s around a bit with a large trout 🐟",
+	"fun.slapSelf":     "themselves",
+	"fun.fightPunch":   "%s throws a punch at %s!",
+	"fun.fightCombat":  "%s challenges %s to mortal combat!",
+	"fun.fightFerrets": "%s summons a mass of wild ferrets to attack %s!",
+	"fun.fightAir":     "the air",
+	"fun.dice":         "%s rolls a dice and gets %d",
+	"fun.coin":         "%s flips a coin: %s!",
+	"fun.heads":        "heads",
+	"fun.tails":        "tails",
+	"fun.eightBall":    "%s asks the magic 8-ball \"%s\": %s",
+	"eightball.1":      "It is certain.",
+	"eightball.2":      "It is decidedly so.",
+	"eightball.3":      "Without a doubt.",
+	"eightball.4":      "Yes definitely.",
+	"eightball.5":      "You may rely on it.",
+	"eightball.6":      "As I see it, yes.",
+	"eightball.7":      "Most likely.",
+	"eightball.8":      "Outlook good.",
+	"eightball.9":      "Yes.",
+	"eightball.10":     "Signs point to yes.",
+	"eightball.11":     "Reply hazy, try again.",
+	"eightball.12":     "Ask again later.",
+	"eightball.13":     "Better not tell you now.",
+	"eightball.14":     "Cannot predict now.",
+	"eightball.15":     "Concentrate and ask again.",
+	"eightball.16":     "Don't count on it.",
+	"eightball.17":     "My reply is no.",
+	"eightball.18":     "My sources say no.",
+	"eightball.19":     "Outlook not so good.",
+	"eightball.20":     "Very doubtful.",
+
+	// Command replies, shown only to whoever ran the command
+	"whois.self":            "%s is you, hosting on %s",
+	"whois.client":          "%s is connected from %s\n  Connected: %s ago (since %s)\n  Idle: %s",
+	"whois.away":            "  Away: %s",
+	"user.notFound":         "User %s not found",
+	"cmd.noExport":          "Export isn't available here",
+	"cmd.hostOnlyWhois":     "/whois is only available to the host",
+	"cmd.notAway":           "You aren't away",
+	"invite.looking":        "Looking for %s...",
+	"cmd.hostOnlyPoll":      "Only the host can run polls",
+	"cmd.hostOnlyTopic":     "Only the host can set the topic",
+	"cmd.hostOnlyMotd":      "Only the host can set the welcome message",
+	"cmd.hostOnlyHandoff":   "Only the host can hand the room over",
+	"cmd.hostOnlyBans":      "Only the host can manage bans",
+	"cmd.hostOnlyKickAll":   "Only the host can clear the room",
+	"cmd.noReactTarget":     "No message to react to",
+	"cmd.statsFailed":       "Cannot get stats: %v",
+	"file.sending":          "Sending file: %s",
+	"file.offering":         "Offering file: %s",
+	"file.acceptedFrom":     "Accepted file from %s",
+	"file.noneToAccept":     "No pending file to accept",
+	"file.rejectedFrom":     "Rejected file from %s",
+	"file.noneToReject":     "No pending file to reject",
+	"call.failed":           "Cannot call %s: %v",
+	"call.calling":          "Calling %s...",
+	"share.failed":          "Cannot share with %s: %v",
+	"share.sharing":         "Sharing screen with %s...",
+	"replay.failed":         "Cannot save replay: %v",
+	"replay.saved":          "Saved replay to %s",
+	"record.stopFailed":     "Cannot stop recording: %v",
+	"record.saved":          "Saved recording to %s",
+	"record.failed":         "Cannot record: %v",
+	"record.started":        "Recording call, /record stop to finish",
+	"ban.self":              "You can't ban yourself",
+	"ban.done":              "Banned %s (%s)",
+	"ban.none":              "No one is banned",
+	"ban.list":              "Banned:",
+	"ban.noMatch":           "No ban matches %s",
+	"ban.lifted":            "Unbanned %s",
+	"poll.alreadyOpen":      "A poll is already open, use /poll close first",
+	"poll.noneOpen":         "No open poll",
+	"poll.none":             "No poll to vote in",
+	"poll.isClosed":         "The poll is closed",
+	"poll.pickOption":       "Pick an option between 1 and %d",
+	"poll.alreadyVoted":     "You already voted",
+	"poll.voted":            "Voted for %s",
+	"handoff.self":          "You're already the host",
+	"handoff.asking":        "Asking %s to take over the room...",
+	"private.noSender":      "No one has messaged you privately yet",
+	"private.sent":          "-> %s: %s",
+	"transfer.incoming":     "%s (%s) from %s, waiting for you",
+	"transfer.outgoing":     "%s (%s) to %s, waiting for an answer",
+	"transfer.everyone":     "everyone",
+	"transfer.none":         "No file transfers waiting",
+	"transfer.notFound":     "No transfer %d, see /transfers",
+	"transfer.declined":     "Declined %s from %s",
+	"transfer.withdrew":     "Withdrew %s",
+	"ascii.tooLong":         "Banners are limited to %d characters",
+	"nick.tooLong":          "Nickname too long (max %d chars)",
+	"cmd.time":              "Current time: %s",
+	"cmd.leaving":           "Leaving...",
+	"cmd.unknown":           "Unknown command: %s (try /help)",
+	"usage.ascii":           "Usage: /ascii <text>",
+	"usage.ban":             "Usage: /ban <nick>",
+	"usage.call":            "Usage: /call <nick>",
+	"usage.cancel":          "Usage: /cancel <n>, numbered as in /transfers",
+	"usage.edit":            "Usage: /edit <new text>",
+	"usage.eightball":       "Usage: /8ball <question>",
+	"usage.host":            "Usage: /host <nick>",
+	"usage.invite":          "Usage: /invite <name>",
+	"usage.me":              "Usage: /me <action>",
+	"usage.msg":             "Usage: /msg <nick> <text>",
+	"usage.nick":            "Usage: /nick <newnickname>",
+	"usage.poll":            "Usage: /poll \"question\" opt1 | opt2 [| ...] or /poll close",
+	"usage.r":               "Usage: /r <text>",
+	"usage.record":          "Usage: /record start|stop",
+	"usage.send":            "Usage: /send <filepath> [nick] or /send @ to pick",
+	"usage.share":           "Usage: /share <nick>",
+	"usage.unban":           "Usage: /unban <ip-or-nick>",
+	"usage.vote":            "Usage: /vote <option number>",
+	"usage.whois":           "Usage: /whois <nick>",
+	"alias.cleared":         "Cleared the alias for %s",
+	"alias.set":             "%s is now shown as %s",
+	"alias.none":            "No aliases set",
+	"alias.entry":           "%s: %s",
+	"alias.inColor":         "%s in %s",
+	"stats.room":            "Room up %s: %s, %s, %s",
+	"stats.host":            "Hosting on %s; %s, %s waiting, %s",
+	"stats.latency":         "%s; your latency %dms",
+	"session.summary":       "Session lasted %s: %s sent, %d received; %s sent, %d received",
+	"count.user.one":        "%d user",
+	"count.user.other":      "%d users",
+	"count.message.one":     "%d message",
+	"count.message.other":   "%d messages",
+	"count.file.one":        "%d file",
+	"count.file.other":      "%d files",
+	"count.observer.one":    "%d observer",
+	"count.observer.other":  "%d observers",
+	"count.fileOffer.one":   "%d file offer",
+	"count.fileOffer.other": "%d file offers",
+	"count.ban.one":         "%d ban",
+	"count.ban.other":       "%d bans",
+	"savedir.cleared":       "Cleared the download directory",
+	"savedir.failed":        "Cannot save files there: %v",
+	"savedir.saving":        "Saving received files to %s",
+	"savedir.unset":         "No download directory set: the desktop app asks where to save each file, the terminal saves to %s",
+	"motd.cleared":          "Cleared the welcome message",
+	"motd.set":              "Joiners will be greeted with: %s",
+	"motd.none":             "No welcome message set",
+	"motd.current":          "Welcome message: %s",
+	"cmd.help": `
++------------------------------------------+
+|           CabinChat Commands             |
++------------------------------------------+
+| UTILITY                                  |
+|   /nick <name>    Change your nickname   |
+|   /users          List online users      |
+|   /afk [message]  Mark yourself away     |
+|   /back           Clear away status      |
+|   /msg <nick> ..  Private message        |
+|   /r <text>       Reply privately        |
+|   /whois <nick>   Connection info (host) |
+|   /poll "q" a | b Start a poll (host)    |
+|   /poll close     Close poll (host)      |
+|   /topic [text]   Set topic (host)       |
+|   /motd [text]    Welcome msg (host)     |
+|   /host <nick>    Hand room over (host)  |
+|   /ban <nick>     Ban by address (host)  |
+|   /banlist        Show bans (host)       |
+|   /unban <who>    Lift a ban (host)      |
+|   /kickall        Clear the room (host)  |
+|   /invite <name>  Invite an idle user    |
+|   /alias          List local aliases     |
+|   /alias <n> ..   Local name [#color]    |
+|   /alias <nick>   Clear an alias         |
+|   /vote <n>       Vote in the poll       |
+|   /react [emoji]  React to last message  |
+|   /edit <text>    Edit your last message |
+|   /delete         Delete your last one   |
+|   /send <file>    Send a file            |
+|   /send @         Pick from list         |
+|   /accept [nick]  Accept file transfer   |
+|   /reject [nick]  Reject file transfer   |
+|   /transfers      Files awaiting answers |
+|   /cancel <n>     Cancel one of those    |
+|   /savedir [dir]  Show/set download dir  |
+|   /call <nick>    Call a user           |
+|   /share <nick>   Share screen          |
+|   /record start   Record the call        |
+|   /record stop    Finish recording       |
+|   /replay         Save last call audio   |
+|   /ping           Check connection       |
+|   /stats          Room uptime and counts |
+|   /time           Show current time      |
+|   /clear          Clear screen           |
+|   /export [file]  Save the chat history  |
+|   /quit           Leave the room         |
++------------------------------------------+
+| FUN                                      |
+|   /me <action>    Action message         |
+|   /slap <user>    Classic IRC slap       |
+|   /shrug          Shrug emoticon         |
+|   /flip           Flip a table           |
+|   /unflip         Put it back            |
+|   /rage           Express yourself       |
+|   /dice           Roll a d6              |
+|   /coin           Flip a coin            |
+|   /lenny          Lenny face             |
+|   /disapprove     Look of disapproval    |
+|   /fight <who>    Start a fight          |
+|   /8ball <q>      Ask the magic 8-ball   |
+|   /ascii <text>   Text as a big banner   |
++------------------------------------------+
+`,
+
+	// Network check, in the desktop app and -diagnose
+	"diagnose.lanAddress":  "LAN address: %s",
+	"diagnose.portInUse":   "• Port %d: in use, perhaps by a running room",
+	"diagnose.port":        "Port %d",
+	"diagnose.free":        "free",
+	"diagnose.reachable":   "Reachable on LAN address",
+	"diagnose.yes":         "yes",
+	"diagnose.advertising": "mDNS advertising",
+	"diagnose.discovery":   "mDNS discovery",
+	"diagnose.ok":          "ok",
+
+	// Desktop app
+	"welcome.found":              "Found %d rooms",
+	"welcome.lastSeen":           " · last seen %s ago",
+	"invite.question":            "%s invites you to join their room. Join now?",
+	"error":                      "Error: %v",
+	"host.portInUse":             "Port %d is already in use, maybe by another room. Host on a free port instead?",
+	"client.reconnecting":        "Connection lost, looking for %s...",
+	"client.reconnected":         "Reconnected to %s",
+	"file.offer":                 "%s wants to send %s. Accept?",
+	"file.offerSized":            "%s wants to send %s (%s). Accept?",
+	"file.accepted":              "File accepted by %s, sending...",
+	"file.rejected":              "File rejected by %s",
+	"file.alwaysAccept":          "Always accept from %s at %s",
+	"export.error":               "Error exporting transcript: %v",
+	"file.failed":                "File %s from %s failed: %v",
+	"file.saveError":             "Error saving %s: %v",
+	"file.discarded":             "Discarded %s from %s",
+	"file.saved":                 "Saved %s from %s to %s",
+	"welcome.rooms":              "Discovered Rooms:",
+	"welcome.nickPlaceholder":    "Enter Nickname",
+	"welcome.scanning":           "Scanning network...",
+	"welcome.host":               "Start New Room",
+	"welcome.addressPlaceholder": "host:port or cabinchat:// link",
+	"welcome.connect":            "Connect",
+	"welcome.diagnose":           "Check Network",
+	"welcome.noRooms":            "No rooms found. Be the first to host! (Scanning...)",
+	"diagnose.checking":          "Checking network",
+	"diagnose.title":             "Network check",
+	"copy":                       "Copy",
+	"close":                      "Close",
+	"invite.title":               "Invite",
+	"room.closedTitle":           "Room closed",
+	"host.portInUseTitle":        "Port in use",
+	"client.connecting":          "Connecting...",
+	"client.disconnectedTitle":   "Disconnected",
+	"client.disconnected":        "Connection lost",
+	"file.offerTitle":            "File Offer",
+	"file.accept":                "Accept",
+	"file.reject":                "Reject",
+	"leave.dontAsk":              "Don't ask again",
+	"export.saved":               "Saved transcript to %s",
+	"welcome.needNick":           "Please enter a nickname",
+	"chat.replyingTo":            "Replying to %s: %s",
+	"chat.quoteGone":             "↪ %s: %s (no longer in history)",
+	"chat.usersConnecting":       "Online:\n(Connecting...)",
+	"chat.users":                 "Room Users",
+	"chat.newMessages":           "↓ New messages",
+	"chat.inputPlaceholder":      "Type a message... (Shift+Enter for a new line)",
+	"chat.send":                  "Send",
+	"chat.call":                  "📞 Call",
+	"chat.share":                 "📺 Share",
+	"chat.reply":                 "Reply",
+	"chat.edit":                  "Edit",
+	"chat.editTitle":             "Edit message",
+	"chat.save":                  "Save",
+	"cancel":                     "Cancel",
+	"chat.delete":                "Delete",
+	"chat.deleteTitle":           "Delete message",
+	"chat.deleteQuestion":        "Delete this message for everyone?",
+	"chat.roleClient":            "Client",
+	"chat.roleHost":              "Host",
+	"chat.hosting":               "Hosting on %s",
+	"leave.summaryTitle":         "Left the room",
+	"leave.question":             "Leave the room?",
+	"leave.questionHost":         "Stop hosting? Everyone in the room will be disconnected.",
+	"leave.leave":                "Leave",
+	"leave.stay":                 "Stay",
+	"qr.copyLink":                "Copy link",
+	"qr.title":                   "Join this room",
+	"notify.fromOne":             "%d new messages from %s",
+	"notify.fromMany":            "%d new messages from %d people",
+}
@@ -0,0 +1,93 @@
+// Package i18n looks up user-facing text by message ID in the selected
+// locale's catalog, falling back to the built-in English one.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+
+	"cabinchat/logger"
+)
+
+// English is the default locale, built in as the english catalog
+const English = "en"
+
+var (
+	mu      sync.RWMutex
+	current map[string]string // selected locale's catalog, nil = English
+)
+
+// T returns the text for id in the current locale, formatted with args as
+// by fmt.Sprintf. IDs missing from the locale come from English, and unknown
+// IDs are returned as they are.
+func T(id string, args ...any) string {
+	mu.RLock()
+	text, ok := current[id]
+	mu.RUnlock()
+	if !ok {
+		text, ok = english[id]
+	}
+	if !ok {
+		text = id
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Load selects locale, reading its catalog from dir/<locale>.json: a JSON
+// object of message ID to text. English needs no file. Translations whose
+// placeholders differ from the English text are skipped with a warning, so
+// a bad translation can't garble a message.
+func Load(dir string, locale string) error {
+	if locale == "" || locale == English {
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, locale+".json"))
+	if err != nil {
+		return fmt.Errorf("loading locale %q: %w", locale, err)
+	}
+	var catalog map[string]string
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("loading locale %q: %w", locale, err)
+	}
+	for id, text := range catalog {
+		source, ok := english[id]
+		if !ok {
+			logger.Warnf("Locale %s: unknown message %q", locale, id)
+			delete(catalog, id)
+		} else if !slices.Equal(placeholders(text), placeholders(source)) {
+			logger.Warnf("Locale %s: %q must use the placeholders of %q", locale, id, source)
+			delete(catalog, id)
+		}
+	}
+
+	mu.Lock()
+	current = catalog
+	mu.Unlock()
+	return nil
+}
+
+// placeholderPattern matches fmt verbs, including flags, width and precision
+var placeholderPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// placeholders lists the fmt verbs in text, in order, ignoring %%
+func placeholders(text string) []string {
+	var verbs []string
+	for _, verb := range placeholderPattern.FindAllString(text, -1) {
+		if verb != "%%" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Level is a log verbosity; messages below the current level are dropped
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+var (
+	current = LevelWarn
+	out     = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// SetLevel sets the verbosity by name: debug, info, warn or error
+func SetLevel(name string) error {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			current = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q", name)
+}
+
+// Debugf logs detail useful when diagnosing a problem
+func Debugf(format string, args ...any) {
+	logf(LevelDebug, format, args...)
+}
+
+// Infof logs normal events
+func Infof(format string, args ...any) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf logs recoverable problems
+func Warnf(format string, args ...any) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf logs failed operations
+func Errorf(format string, args ...any) {
+	logf(LevelError, format, args...)
+}
+
+func logf(level Level, format string, args ...any) {
+	if level < current {
+		return
+	}
+	out.Printf("[%s] %s", strings.ToUpper(levelNames[level]), fmt.Sprintf(format, args...))
+}
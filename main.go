@@ -1,10 +1,135 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"cabinchat/cli"
+	"cabinchat/core"
+	"cabinchat/i18n"
+	"cabinchat/logger"
+	"cabinchat/media"
 	"cabinchat/ui"
 )
 
 func main() {
+	cliMode := flag.Bool("cli", false, "run in the terminal instead of opening a window")
+	diagnose := flag.Bool("diagnose", false, "check whether hosting works on this network, then exit")
+	bridge := flag.String("bridge", "", "relay chat between two rooms, given as \"room-a,room-b\" (links or host:port)")
+	flag.StringVar(&core.Settings.Nick, "nick", core.Settings.Nick, "nickname")
+	flag.BoolVar(&core.Settings.Unzip, "unzip", core.Settings.Unzip, "unpack received .zip files, such as sent folders, into the download directory")
+	flag.BoolVar(&core.Settings.Sound, "sound", core.Settings.Sound, "enable sound notifications")
+	flag.BoolVar(&core.Settings.MentionsOnly, "mentions-only", core.Settings.MentionsOnly, "only sound and notify for messages that @mention you")
+	flag.StringVar(&core.Settings.Theme, "theme", core.Settings.Theme, "window theme: auto (follow the OS), light or dark")
+	bells := flag.String("bell", "", "terminal: cue per event, e.g. \"mention=2,own=off,private=paplay ping.wav\"; cues are off, sound, a number of beeps or a command")
+	flag.IntVar(&core.Settings.Port, "port", core.Settings.Port, "port to use for hosting/connecting")
+	flag.BoolVar(&core.Settings.AutoPort, "auto-port", core.Settings.AutoPort, "host on a free port when -port is taken")
+	flag.StringVar(&core.Settings.RoomName, "room", core.Settings.RoomName, "name to advertise the room under (default: hostname)")
+	flag.StringVar(&core.Settings.Motd, "motd", core.Settings.Motd, "host: welcome message sent to everyone who joins")
+	flag.StringVar(&core.Settings.WebhookURL, "webhook", core.Settings.WebhookURL, "host: URL to POST room events to as JSON (join, leave, message, file)")
+	flag.DurationVar(&core.Settings.IdleTimeout, "idle-timeout", core.Settings.IdleTimeout, "host: close the room after this long without messages (0 = never)")
+	flag.BoolVar(&core.Settings.IdleKick, "idle-kick", core.Settings.IdleKick, "host: with -idle-timeout, disconnect quiet clients instead of closing the room")
+	flag.IntVar(&core.Settings.MaxMessageLen, "max-message", core.Settings.MaxMessageLen, "host: characters allowed in a chat message, longer ones are cut (0 = no limit)")
+	flag.IntVar(&core.Settings.MaxTransfers, "max-transfers", core.Settings.MaxTransfers, "files sent, received or relayed at once; more are queued (0 = no limit)")
+	flag.DurationVar(&core.Settings.WriteTimeout, "write-timeout", core.Settings.WriteTimeout, "drop a connection that accepts no data for this long (0 = wait forever)")
+	flag.DurationVar(&core.Settings.IdleAway, "idle-away", core.Settings.IdleAway, "mark yourself away after this long without typing (0 = never)")
+	flag.StringVar(&core.Settings.LogLevel, "log-level", core.Settings.LogLevel, "log verbosity: debug, info, warn or error")
+	flag.StringVar(&core.Settings.Locale, "lang", core.Settings.Locale, "language, loaded from <lang>.json in -lang-dir (default: built-in English)")
+	flag.StringVar(&core.Settings.LocaleDir, "lang-dir", core.Settings.LocaleDir, "directory of translation catalogs")
+	flag.IntVar(&media.Settings.MaxSessions, "max-calls", media.Settings.MaxSessions, "calls and screen shares open at once, across rooms (0 = no limit)")
+	flag.IntVar(&media.Settings.NoiseGate, "noise-gate", media.Settings.NoiseGate, "mic level (RMS, 0-32767) below which call audio isn't sent; 0 = off")
+	flag.IntVar(&media.Settings.ReplaySeconds, "replay", media.Settings.ReplaySeconds, "seconds of received call audio to keep for /replay (0 = off)")
+	stun := flag.String("stun", "stun:stun.l.google.com:19302", "comma-separated STUN servers for calls; empty = LAN only")
+	turn := flag.String("turn", "", "TURN server for calls, as user:password@turn:host:port")
+	noMDNS := flag.Bool("no-mdns", false, "don't advertise hosted rooms; clients must connect by IP")
+	flag.StringVar(&core.Settings.Join, "join", core.Settings.Join, "room to join directly: cabinchat:// link or host:port")
+	flag.BoolVar(&core.Settings.Observe, "observe", core.Settings.Observe, "join quietly, without being announced or listed (the host must allow it)")
+	flag.BoolVar(&core.Settings.AllowObservers, "allow-observers", core.Settings.AllowObservers, "host: let clients join quietly with -observe")
+	flag.BoolVar(&core.Settings.TLS, "tls", core.Settings.TLS, "host: only accept TLS connections")
+	flag.StringVar(&core.Settings.TLSCert, "tls-cert", core.Settings.TLSCert, "host: TLS certificate file (default: generate a self-signed one)")
+	flag.StringVar(&core.Settings.TLSKey, "tls-key", core.Settings.TLSKey, "host: TLS key file for -tls-cert")
+	flag.StringVar(&core.Settings.TLSFingerprint, "tls-fingerprint", core.Settings.TLSFingerprint, "client: host certificate fingerprint to pin")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		// Opening a cabinchat:// link passes it as the only argument
+		core.Settings.Join = flag.Arg(0)
+	}
+	core.Settings.Advertise = !*noMDNS
+	media.Settings.ICEServers = nil
+	for _, url := range strings.Split(*stun, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			media.Settings.ICEServers = append(media.Settings.ICEServers, media.ICEServer{URLs: []string{url}})
+		}
+	}
+	if *turn != "" {
+		server, err := parseTURN(*turn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		media.Settings.ICEServers = append(media.Settings.ICEServers, server)
+	}
+	if *bells != "" {
+		var err error
+		if core.Settings.Bells, err = core.ParseBells(*bells); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if u, err := url.Parse(core.Settings.WebhookURL); core.Settings.WebhookURL != "" && (err != nil || u.Scheme != "http" && u.Scheme != "https") {
+		fmt.Fprintf(os.Stderr, "invalid -webhook %q, want an http:// or https:// URL\n", core.Settings.WebhookURL)
+		os.Exit(2)
+	}
+	if !slices.Contains([]string{"auto", "light", "dark"}, core.Settings.Theme) {
+		fmt.Fprintf(os.Stderr, "invalid -theme %q, want auto, light or dark\n", core.Settings.Theme)
+		os.Exit(2)
+	}
+	if err := logger.SetLevel(core.Settings.LogLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := i18n.Load(core.Settings.LocaleDir, core.Settings.Locale); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *diagnose {
+		fmt.Print(core.RunDiagnostics())
+		return
+	}
+
+	if *bridge != "" {
+		a, b, ok := strings.Cut(*bridge, ",")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "invalid -bridge %q, want two rooms separated by a comma\n", *bridge)
+			os.Exit(2)
+		}
+		if err := cli.RunBridge(strings.TrimSpace(a), strings.TrimSpace(b)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *cliMode {
+		cli.Run()
+		return
+	}
+
 	app := ui.NewApp()
 	app.Run()
 }
+
+// parseTURN reads a -turn value of the form user:password@turn:host:port
+func parseTURN(value string) (media.ICEServer, error) {
+	creds, url, ok := strings.Cut(value, "@")
+	user, password, hasPassword := strings.Cut(creds, ":")
+	if !ok || !hasPassword || !strings.HasPrefix(url, "turn") {
+		return media.ICEServer{}, fmt.Errorf("invalid -turn %q, want user:password@turn:host:port", value)
+	}
+	return media.ICEServer{URLs: []string{url}, Username: user, Credential: password}, nil
+}
@@ -1,7 +1,10 @@
 package media
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/gen2brain/malgo"
@@ -10,15 +13,97 @@ import (
 )
 
 var (
+	// audioMutex guards the contexts and devices below, which calls start
+	// from their own goroutines while hanging up stops them
+	audioMutex     sync.Mutex
 	captureCtx     *malgo.AllocatedContext
 	playbackCtx    *malgo.AllocatedContext
 	captureDevice  *malgo.Device
 	playbackDevice *malgo.Device
+
+	// replay holds the most recent received call audio for /replay
+	replay replayBuffer
 )
 
+// ErrReplayOff is returned by ReplayWAV when Settings.ReplaySeconds is 0
+var ErrReplayOff = errors.New("replay recording is off")
+
+// ErrReplayEmpty is returned by ReplayWAV before any call audio was received
+var ErrReplayEmpty = errors.New("no call audio to replay")
+
+// replayBuffer is a fixed-size ring of the latest received samples
+type replayBuffer struct {
+	mutex   sync.Mutex
+	samples []int16
+	next    int  // index the next sample is written to
+	full    bool // samples has wrapped at least once
+}
+
+// reset sizes the ring for seconds of audio and discards what it held
+func (r *replayBuffer) reset(seconds int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.samples = nil
+	if seconds > 0 {
+		r.samples = make([]int16, seconds*sampleRate)
+	}
+	r.next = 0
+	r.full = false
+}
+
+// write appends a sample, overwriting the oldest once the ring is full
+func (r *replayBuffer) write(sample int16) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.samples) == 0 {
+		return
+	}
+	r.samples[r.next] = sample
+	r.next++
+	if r.next == len(r.samples) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// snapshot returns the buffered samples, oldest first
+func (r *replayBuffer) snapshot() []int16 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.full {
+		return append([]int16(nil), r.samples[:r.next]...)
+	}
+	out := make([]int16, 0, len(r.samples))
+	out = append(out, r.samples[r.next:]...)
+	return append(out, r.samples[:r.next]...)
+}
+
+// ReplayWAV returns the last Settings.ReplaySeconds of received call audio as a WAV file
+func ReplayWAV() ([]byte, error) {
+	if Settings.ReplaySeconds <= 0 {
+		return nil, ErrReplayOff
+	}
+	samples := replay.snapshot()
+	if len(samples) == 0 {
+		return nil, ErrReplayEmpty
+	}
+
+	var buf bytes.Buffer
+	if err := writeWAVHeader(&buf, len(samples)*2); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, samples); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // StartAudioCapture initializes microphone capture and sends to WebRTC track
-// Uses 48kHz sample rate for Opus codec (no manual encoding needed)
-func StartAudioCapture(track *webrtc.TrackLocalStaticSample) error {
+// Uses 48kHz sample rate for Opus codec (no manual encoding needed).
+// onLevel, if set, gets each captured frame's RMS level.
+func StartAudioCapture(track *webrtc.TrackLocalStaticSample, onLevel func(rms float64)) error {
+	audioMutex.Lock()
+	defer audioMutex.Unlock()
 	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
 	})
 	if err != nil {
@@ -32,6 +117,7 @@ func StartAudioCapture(track *webrtc.TrackLocalStaticSample) error {
 	deviceConfig.SampleRate = 48000 // Native macOS rate - no resampling needed
 	deviceConfig.PeriodSizeInMilliseconds = 20
 
+	var gate noiseGate
 	onRecv := func(pOutputSample, pInputSample []byte, framecount uint32) {
 		// Input is S16LE (2 bytes per sample) at 48kHz
 		// Send raw samples - Opus encoding happens in WebRTC layer
@@ -42,8 +128,14 @@ func StartAudioCapture(track *webrtc.TrackLocalStaticSample) error {
 		// Calculate proper duration based on sample count
 		duration := time.Duration(float64(framecount) / 48000.0 * float64(time.Second))
 
-		if err := track.WriteSample(media.Sample{Data: pInputSample[:framecount*2], Duration: duration}); err != nil {
-			// Silently ignore write errors
+		recorder.captured(pInputSample[:framecount*2])
+		if onLevel != nil {
+			onLevel(frameRMS(pInputSample[:framecount*2]))
+		}
+		for _, frame := range gate.filter(pInputSample[:framecount*2], time.Now()) {
+			if err := track.WriteSample(media.Sample{Data: frame, Duration: duration}); err != nil {
+				// Silently ignore write errors
+			}
 		}
 	}
 
@@ -62,8 +154,11 @@ func StartAudioCapture(track *webrtc.TrackLocalStaticSample) error {
 	return nil
 }
 
-// StartAudioPlayback plays audio from a WebRTC track at 48kHz
-func StartAudioPlayback(track *webrtc.TrackRemote) error {
+// StartAudioPlayback plays audio from a WebRTC track at 48kHz. onLevel, if
+// set, gets the RMS level of each period played, after the volume is applied.
+func StartAudioPlayback(track *webrtc.TrackRemote, onLevel func(rms float64)) error {
+	audioMutex.Lock()
+	defer audioMutex.Unlock()
 	if playbackCtx == nil {
 		ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
 		})
@@ -84,6 +179,7 @@ func StartAudioPlayback(track *webrtc.TrackRemote) error {
 	audioBuffer := make(chan int16, bufferSize)
 
 	var lastSample int16 = 0
+	replay.reset(Settings.ReplaySeconds)
 
 	// Goroutine to read from WebRTC track
 	go func() {
@@ -97,6 +193,8 @@ func StartAudioPlayback(track *webrtc.TrackRemote) error {
 			// Decode S16LE samples (2 bytes per sample)
 			for i := 0; i+1 < n; i += 2 {
 				sample := int16(binary.LittleEndian.Uint16(buf[i : i+2]))
+				replay.write(sample)
+				recorder.received(sample)
 				select {
 				case audioBuffer <- sample:
 				default:
@@ -118,7 +216,10 @@ func StartAudioPlayback(track *webrtc.TrackRemote) error {
 			default:
 				// Use last sample for smooth continuation
 			}
-			binary.LittleEndian.PutUint16(pOutputSample[2*i:2*i+2], uint16(lastSample))
+			binary.LittleEndian.PutUint16(pOutputSample[2*i:2*i+2], uint16(applyGain(lastSample)))
+		}
+		if onLevel != nil {
+			onLevel(frameRMS(pOutputSample[:framecount*2]))
 		}
 	}
 
@@ -140,8 +241,11 @@ func StartAudioPlayback(track *webrtc.TrackRemote) error {
 	return nil
 }
 
-// StopAudio stops capture and playback
+// StopAudio stops capture and playback, finishing any recording
 func StopAudio() {
+	finishRecording()
+	audioMutex.Lock()
+	defer audioMutex.Unlock()
 	if captureDevice != nil {
 		captureDevice.Uninit()
 		captureDevice = nil
@@ -158,5 +262,4 @@ func StopAudio() {
 		playbackCtx.Free()
 		playbackCtx = nil
 	}
-
 }
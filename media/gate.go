@@ -0,0 +1,57 @@
+package media
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// gateHangover keeps the gate open after the level drops, so quiet word
+// endings and short pauses aren't cut
+const gateHangover = 300 * time.Millisecond
+
+// noiseGate suppresses microphone frames quieter than Settings.NoiseGate,
+// so background hiss isn't transmitted between words
+type noiseGate struct {
+	openUntil time.Time
+	prev      []byte // last suppressed frame, sent ahead of speech so its onset isn't clipped
+}
+
+// filter returns the frames to send for a captured S16LE frame: none while
+// the gate is closed, the held-back frame plus this one as it opens
+func (g *noiseGate) filter(frame []byte, now time.Time) [][]byte {
+	threshold := Settings.NoiseGate
+	if threshold <= 0 {
+		return [][]byte{frame}
+	}
+
+	if frameRMS(frame) >= float64(threshold) {
+		opening := now.After(g.openUntil)
+		g.openUntil = now.Add(gateHangover)
+		if opening && g.prev != nil {
+			prev := g.prev
+			g.prev = nil
+			return [][]byte{prev, frame}
+		}
+		return [][]byte{frame}
+	}
+	if now.Before(g.openUntil) {
+		return [][]byte{frame}
+	}
+	g.prev = append(g.prev[:0], frame...) // frame's buffer is reused by the device
+	return nil
+}
+
+// frameRMS is the root mean square level of S16LE samples
+func frameRMS(frame []byte) float64 {
+	n := len(frame) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(frame[2*i:])))
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(n))
+}
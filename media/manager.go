@@ -3,30 +3,71 @@ package media
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"image/jpeg"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kbinani/screenshot"
 	"github.com/pion/webrtc/v3"
+
+	"cabinchat/logger"
 )
 
 // SignalMessage represents the JSON payload in a MsgTypeWebRTC
 type SignalMessage struct {
-	Type          string `json:"type"` // "offer", "answer", "candidate"
+	Type          string `json:"type"` // "offer", "answer", "candidate", "decline", "busy", "hangup"
 	SDP           string `json:"sdp,omitempty"`
 	Candidate     string `json:"candidate,omitempty"`
 	CandidateMid  string `json:"mid,omitempty"`
 	CandidateLine int    `json:"line,omitempty"`
 }
 
+// failedCallTimeout is how long a failed call stays open for a retry
+const failedCallTimeout = 15 * time.Second
+
+// endedCallTimeout is how long the window stays up after the peer ends a call
+const endedCallTimeout = 3 * time.Second
+
+// ErrBadRegion is returned when a share region falls outside the display
+var ErrBadRegion = errors.New("region is outside the display")
+
+// ErrInCall is returned when starting a call while another is open: a
+// MediaManager holds one peer connection at a time
+var ErrInCall = errors.New("already in a call")
+
+// ErrTooManySessions is returned when a call would exceed Settings.MaxSessions
+var ErrTooManySessions = errors.New("too many active calls")
+
+// ErrUnavailable is returned by a nil MediaManager, as used by hosts and
+// clients without a GUI
+var ErrUnavailable = errors.New("calls and screen sharing need the GUI")
+
+// RefuseSignal answers a signal on a peer without a MediaManager. Offers get
+// a decline, so the caller isn't left ringing; anything else gets "".
+func RefuseSignal(data string) string {
+	var msg SignalMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil || msg.Type != "offer" {
+		return ""
+	}
+	reply, _ := json.Marshal(SignalMessage{Type: "decline"})
+	return string(reply)
+}
+
 // NetworkCallback is a function to send a message over the network
 type NetworkCallback func(targetNick string, data string)
 
-// MediaManager handles WebRTC sessions
+// MediaManager handles WebRTC sessions. A nil MediaManager, for running
+// without a GUI, refuses calls with ErrUnavailable and ignores signals.
 type MediaManager struct {
 	mutex          sync.Mutex
 	peerConnection *webrtc.PeerConnection
@@ -34,14 +75,25 @@ type MediaManager struct {
 	app            fyne.App    // Reference to App to create new windows
 	mediaWindow    fyne.Window // The separate window for the call
 	remoteVideo    *canvas.Image
+	controls       *callControls // widgets in the call window updated during the call
 	localStream    *webrtc.TrackLocalStaticSample
 
 	currentTarget   string
 	isSharingScreen bool
+	shareDisplay    atomic.Int32                    // display index captured while sharing
+	shareRegion     atomic.Pointer[image.Rectangle] // display-relative capture area, nil = whole display
+	session         uint64                          // bumped by Stop to invalidate in-flight signal handling
+	ringing         *incomingCall                   // unanswered call, if any
+	counted         bool                            // holds a place under Settings.MaxSessions
+
+	OnRing func(from string) // Called when an incoming call starts ringing
 }
 
 // NewMediaManager creates a new MediaManager
 func NewMediaManager(app fyne.App, sender NetworkCallback) *MediaManager {
+	if app != nil {
+		SetVolume(app.Preferences().IntWithFallback(prefVolume, Settings.Volume))
+	}
 	return &MediaManager{
 		app:        app,
 		sendSignal: sender,
@@ -53,22 +105,38 @@ func (m *MediaManager) createPeerConnection() error {
 	if m.peerConnection != nil {
 		m.peerConnection.Close()
 	}
+	if !m.counted {
+		if !acquireSession() {
+			return ErrTooManySessions
+		}
+		m.counted = true
+	}
 
-	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
+	// Without servers ICE still pairs host candidates, which is all a LAN needs;
+	// an unreachable STUN server only costs a gathering timeout
+	var config webrtc.Configuration
+	for _, server := range Settings.ICEServers {
+		config.ICEServers = append(config.ICEServers, webrtc.ICEServer{
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
 	}
 
 	pc, err := webrtc.NewPeerConnection(config)
 	if err != nil {
+		m.closePeer()
 		return err
 	}
 
 	m.peerConnection = pc
 
+	session := m.session
+	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		logger.Debugf("ICE connection state: %s", state)
+		m.showConnectionState(session, state)
+	})
+
 	// ICE Candidates
 	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
 		if c == nil {
@@ -87,11 +155,17 @@ func (m *MediaManager) createPeerConnection() error {
 
 	// Track Handling (Received Video/Audio)
 	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		fmt.Printf("Track has started: %s (%s)\n", track.ID(), track.Kind())
+		logger.Debugf("Track has started: %s (%s)", track.ID(), track.Kind())
 		if track.Kind() == webrtc.RTPCodecTypeAudio {
-			err := StartAudioPlayback(track)
+			m.mutex.Lock()
+			var onLevel func(rms float64)
+			if m.controls != nil {
+				onLevel = m.controls.speaker.update
+			}
+			m.mutex.Unlock()
+			err := StartAudioPlayback(track, onLevel)
 			if err != nil {
-				fmt.Printf("Failed to start audio playback: %v\n", err)
+				logger.Errorf("Failed to start audio playback: %v", err)
 			}
 		}
 		if track.Kind() == webrtc.RTPCodecTypeVideo {
@@ -127,9 +201,23 @@ func (m *MediaManager) createPeerConnection() error {
 	// Handle DataChannel for Screen Share
 	pc.OnDataChannel(func(d *webrtc.DataChannel) {
 		if d.Label() == "screen" {
-			fmt.Println("Received Screen Share DataChannel")
+			logger.Debugf("Received Screen Share DataChannel")
+			var frames frameAssembler
 			d.OnMessage(func(msg webrtc.DataChannelMessage) {
-				img, err := jpeg.Decode(bytes.NewReader(msg.Data))
+				if msg.IsString {
+					if reason, ok := strings.CutPrefix(string(msg.Data), shareFailedPrefix); ok {
+						m.mutex.Lock()
+						from := m.currentTarget
+						m.mutex.Unlock()
+						m.showShareStopped(fmt.Sprintf("%s stopped sharing their screen: %s", from, reason))
+					}
+					return
+				}
+				frame := frames.add(msg.Data)
+				if frame == nil {
+					return
+				}
+				img, err := jpeg.Decode(bytes.NewReader(frame))
 				if err == nil {
 					// Update UI
 					// We need a thread-safe way to update the image
@@ -147,6 +235,21 @@ func (m *MediaManager) createPeerConnection() error {
 	return nil
 }
 
+// showShareStopped tells the user screen sharing ended while the call goes
+// on, in the status label or, once the shared screen replaced it, the title
+func (m *MediaManager) showShareStopped(text string) {
+	m.mutex.Lock()
+	window, controls := m.mediaWindow, m.controls
+	m.mutex.Unlock()
+	if window == nil || controls == nil {
+		return
+	}
+	fyne.Do(func() {
+		controls.status.SetText(text)
+		window.SetTitle(text)
+	})
+}
+
 // createVideoCanvas sets up the Fyne canvas for video
 func (m *MediaManager) createVideoCanvas() {
 	m.remoteVideo = canvas.NewImageFromImage(nil)
@@ -155,35 +258,64 @@ func (m *MediaManager) createVideoCanvas() {
 }
 
 // StartCall initiates a VOIP call (Audio Only)
-func (m *MediaManager) StartCall(target string) {
-	m.startSession(target, false)
+func (m *MediaManager) StartCall(target string) error {
+	if m == nil {
+		return ErrUnavailable
+	}
+	return m.startSession(target, false)
 }
 
-// StartShare initiates a Screen Share (Audio + Screen)
-func (m *MediaManager) StartShare(target string) {
-	m.startSession(target, true)
+// StartShare initiates a Screen Share (Audio + Screen) of the given display,
+// 0 being the primary one
+func (m *MediaManager) StartShare(target string, display int) error {
+	if m == nil {
+		return ErrUnavailable
+	}
+	if screenshot.NumActiveDisplays() == 0 {
+		return ErrNoDisplay
+	}
+	m.shareDisplay.Store(int32(display))
+	m.shareRegion.Store(nil)
+	return m.startSession(target, true)
 }
 
-func (m *MediaManager) startSession(target string, shareScreen bool) {
+// startSession opens the call window and sends target an offer. If that
+// fails, the window is closed again and the error returned, so the caller
+// can say the call never started.
+func (m *MediaManager) startSession(target string, shareScreen bool) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	if m.peerConnection != nil {
+		return ErrInCall
+	}
+	if atSessionLimit() {
+		return ErrTooManySessions
+	}
+
 	m.currentTarget = target
 	m.isSharingScreen = shareScreen
 
 	// Create Media Window
-	m.mediaWindow = m.app.NewWindow("Call with " + target)
-	m.mediaWindow.Resize(fyne.NewSize(600, 400))
-	m.mediaWindow.SetOnClosed(func() {
-		m.Stop()
-	})
-
-	m.setupUI("Calling " + target + "...")
+	var controls []fyne.CanvasObject
+	if shareScreen {
+		controls = append(controls, m.displayPicker(), m.regionPicker())
+	}
+	m.mediaWindow, m.controls = m.newCallWindow("Call with "+target, "Calling "+target+"...", controls...)
 	m.mediaWindow.Show()
 
+	if err := m.sendOffer(target, shareScreen); err != nil {
+		m.stop(false)
+		return err
+	}
+	return nil
+}
+
+// sendOffer sets up the peer connection with our tracks and sends target
+// the offer. Must be called with m.mutex held.
+func (m *MediaManager) sendOffer(target string, shareScreen bool) error {
 	if err := m.createPeerConnection(); err != nil {
-		fmt.Printf("Error creating PC: %v\n", err)
-		return
+		return fmt.Errorf("creating peer connection: %w", err)
 	}
 
 	// Add Audio Track (Opus at 48kHz - standard WebRTC codec)
@@ -194,37 +326,36 @@ func (m *MediaManager) startSession(target string, shareScreen bool) {
 			Channels:  2,
 		}, "audio", "pion_audio")
 	if err != nil {
-		fmt.Printf("Error creating track: %v\n", err)
-		return
+		return fmt.Errorf("creating audio track: %w", err)
+	}
+	if _, err := m.peerConnection.AddTrack(audioTrack); err != nil {
+		return fmt.Errorf("adding audio track: %w", err)
 	}
-	m.peerConnection.AddTrack(audioTrack)
 	m.localStream = audioTrack
 
 	// Start Audio Capture
-	go StartAudioCapture(audioTrack)
+	go StartAudioCapture(audioTrack, m.controls.mic.update)
 
 	// If sharing screen, create DataChannel
 	if shareScreen {
 		dc, err := m.peerConnection.CreateDataChannel("screen", nil)
 		if err != nil {
-			fmt.Printf("Error creating DC: %v\n", err)
-		} else {
-			dc.OnOpen(func() {
-				StartScreenShare(dc)
-			})
+			return fmt.Errorf("creating screen channel: %w", err)
 		}
+		dc.OnOpen(func() {
+			StartScreenShare(dc, &m.shareDisplay, &m.shareRegion, func(err error) {
+				m.showShareStopped(fmt.Sprintf("Screen sharing stopped: %v", err))
+			})
+		})
 	}
 
 	// Create Offer
 	offer, err := m.peerConnection.CreateOffer(nil)
 	if err != nil {
-		fmt.Printf("Error creating offer: %v\n", err)
-		return
+		return fmt.Errorf("creating offer: %w", err)
 	}
-
 	if err = m.peerConnection.SetLocalDescription(offer); err != nil {
-		fmt.Printf("Error setting local desc: %v\n", err)
-		return
+		return fmt.Errorf("setting local description: %w", err)
 	}
 
 	payload := SignalMessage{
@@ -233,109 +364,277 @@ func (m *MediaManager) startSession(target string, shareScreen bool) {
 	}
 	data, _ := json.Marshal(payload)
 	m.sendSignal(target, string(data))
+	return nil
 }
 
 // HandleSignal processes incoming signaling messages
 func (m *MediaManager) HandleSignal(from string, data string) {
+	if m == nil {
+		return
+	}
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	var msg SignalMessage
 	if err := json.Unmarshal([]byte(data), &msg); err != nil {
-		fmt.Printf("Error decoding signal: %v\n", err)
+		logger.Errorf("Error decoding signal: %v", err)
 		return
 	}
 
-	fmt.Printf("Received %s signal from %s\n", msg.Type, from)
-
-	if m.peerConnection == nil {
-		m.currentTarget = from
-		if err := m.createPeerConnection(); err != nil {
-			fmt.Printf("Error creating PC: %v\n", err)
-			return
-		}
-
-		// Create Media Window on main thread (must wait for it to complete)
-		m.mutex.Unlock() // Release lock while waiting for UI
-		fyne.DoAndWait(func() {
-			m.mediaWindow = m.app.NewWindow("Call with " + from)
-			m.mediaWindow.Resize(fyne.NewSize(600, 400))
-			m.mediaWindow.SetOnClosed(func() {
-				m.Stop()
-			})
-
-			m.setupUI("Call from " + from)
-			m.mediaWindow.Show()
-		})
-		m.mutex.Lock() // Re-acquire lock
-	}
+	logger.Debugf("Received %s signal from %s", msg.Type, from)
 
 	switch msg.Type {
 	case "offer":
-		offer := webrtc.SessionDescription{
-			Type: webrtc.SDPTypeOffer,
-			SDP:  msg.SDP,
-		}
-		if err := m.peerConnection.SetRemoteDescription(offer); err != nil {
-			fmt.Printf("Error setting remote desc: %v\n", err)
+		if m.peerConnection != nil && from == m.currentTarget {
+			m.answer(from, msg) // renegotiation within the call
 			return
 		}
-
-		// Create Answer
-		// But first add our own tracks so they are included
-		audioTrack, err := webrtc.NewTrackLocalStaticSample(
-			webrtc.RTPCodecCapability{
-				MimeType:  webrtc.MimeTypeOpus,
-				ClockRate: 48000,
-				Channels:  2,
-			}, "audio", "pion_audio")
-		if err != nil {
-			fmt.Printf("Error creating track: %v\n", err)
-		} else {
-			m.peerConnection.AddTrack(audioTrack)
-			m.localStream = audioTrack
-			go StartAudioCapture(audioTrack)
-		}
-
-		answer, err := m.peerConnection.CreateAnswer(nil)
-		if err != nil {
-			fmt.Printf("Error creating answer: %v\n", err)
+		if m.peerConnection != nil || m.ringing != nil {
+			// Answering would replace the current call's connection, whatever
+			// the session limit; leave it alone and let the caller know
+			logger.Infof("Refused call from %s: already in a call", from)
+			data, _ := json.Marshal(SignalMessage{Type: "busy"})
+			m.sendSignal(from, string(data))
 			return
 		}
-		if err = m.peerConnection.SetLocalDescription(answer); err != nil {
-			fmt.Printf("Error setting local desc: %v\n", err)
+		if atSessionLimit() {
+			logger.Infof("Refused call from %s: %d calls open already", from, Settings.MaxSessions)
+			data, _ := json.Marshal(SignalMessage{Type: "busy"})
+			m.sendSignal(from, string(data))
 			return
 		}
-
-		payload := SignalMessage{
-			Type: "answer",
-			SDP:  answer.SDP,
-		}
-		respData, _ := json.Marshal(payload)
-		m.sendSignal(from, string(respData))
+		m.ring(from, msg)
 
 	case "answer":
+		if m.peerConnection == nil || from != m.currentTarget {
+			return
+		}
 		answer := webrtc.SessionDescription{
 			Type: webrtc.SDPTypeAnswer,
 			SDP:  msg.SDP,
 		}
 		if err := m.peerConnection.SetRemoteDescription(answer); err != nil {
-			fmt.Printf("Error setting remote desc: %v\n", err)
+			logger.Errorf("Error setting remote desc: %v", err)
 		}
 
 	case "candidate":
-		candidate := webrtc.ICECandidateInit{
-			Candidate:     msg.Candidate,
-			SDPMid:        &msg.CandidateMid,
-			SDPMLineIndex: uint16Ptr(msg.CandidateLine),
+		if m.ringing != nil && m.ringing.from == from {
+			// Not answered yet; applied once the call is accepted
+			m.ringing.candidates = append(m.ringing.candidates, msg)
+			return
+		}
+		if m.peerConnection == nil || from != m.currentTarget {
+			return
+		}
+		m.addCandidate(msg)
+
+	case "decline":
+		if m.peerConnection != nil && from == m.currentTarget {
+			m.endCall(from + " declined the call")
+		}
+
+	case "busy":
+		if m.peerConnection != nil && from == m.currentTarget {
+			m.endCall(from + " is busy")
+		}
+
+	case "hangup":
+		if m.ringing != nil && m.ringing.from == from {
+			m.ringing.dismiss()
+			m.ringing = nil
+		} else if m.peerConnection != nil && from == m.currentTarget {
+			m.stop(false)
+		}
+	}
+}
+
+// answer accepts an offer from the peer, adding our microphone to the call.
+// Must be called with m.mutex held.
+func (m *MediaManager) answer(from string, msg SignalMessage) {
+	offer := webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  msg.SDP,
+	}
+	if err := m.peerConnection.SetRemoteDescription(offer); err != nil {
+		logger.Errorf("Error setting remote desc: %v", err)
+		return
+	}
+
+	// Create Answer
+	// But first add our own tracks so they are included
+	audioTrack, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeOpus,
+			ClockRate: 48000,
+			Channels:  2,
+		}, "audio", "pion_audio")
+	if err != nil {
+		logger.Errorf("Error creating track: %v", err)
+	} else {
+		m.peerConnection.AddTrack(audioTrack)
+		m.localStream = audioTrack
+		go StartAudioCapture(audioTrack, m.controls.mic.update)
+	}
+
+	answer, err := m.peerConnection.CreateAnswer(nil)
+	if err != nil {
+		logger.Errorf("Error creating answer: %v", err)
+		return
+	}
+	if err = m.peerConnection.SetLocalDescription(answer); err != nil {
+		logger.Errorf("Error setting local desc: %v", err)
+		return
+	}
+
+	payload := SignalMessage{
+		Type: "answer",
+		SDP:  answer.SDP,
+	}
+	respData, _ := json.Marshal(payload)
+	m.sendSignal(from, string(respData))
+}
+
+// addCandidate applies an ICE candidate from the peer. Must be called with m.mutex held.
+func (m *MediaManager) addCandidate(msg SignalMessage) {
+	candidate := webrtc.ICECandidateInit{
+		Candidate:     msg.Candidate,
+		SDPMid:        &msg.CandidateMid,
+		SDPMLineIndex: uint16Ptr(msg.CandidateLine),
+	}
+	if err := m.peerConnection.AddICECandidate(candidate); err != nil {
+		logger.Errorf("Error adding candidate: %v", err)
+	}
+}
+
+// endCall closes the connection after the peer ended the call, leaving the
+// window up with the reason for a moment. Must be called with m.mutex held.
+func (m *MediaManager) endCall(reason string) {
+	m.closePeer()
+	m.currentTarget = ""
+
+	session := m.session
+	if controls := m.controls; controls != nil {
+		fyne.Do(func() {
+			controls.status.SetText(reason)
+			controls.retry.Hide()
+		})
+	}
+	time.AfterFunc(endedCallTimeout, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if m.session == session {
+			m.stop(false)
+		}
+	})
+}
+
+// displayPicker lets the sharer switch between displays, or just names the
+// shared one when there is only a single display
+func (m *MediaManager) displayPicker() fyne.CanvasObject {
+	n := screenshot.NumActiveDisplays()
+	if n <= 1 {
+		return widget.NewLabel("Sharing primary display")
+	}
+
+	options := make([]string, n)
+	for i := range options {
+		b := screenshot.GetDisplayBounds(i)
+		options[i] = fmt.Sprintf("Display %d (%dx%d)", i+1, b.Dx(), b.Dy())
+	}
+	picker := widget.NewSelect(options, nil)
+	if current := int(m.shareDisplay.Load()); current < n {
+		picker.SetSelectedIndex(current)
+	} else {
+		picker.SetSelectedIndex(0)
+	}
+	picker.OnChanged = func(string) {
+		m.shareDisplay.Store(int32(picker.SelectedIndex()))
+	}
+	return picker
+}
+
+// SetShareRegion limits screen sharing to a rectangle relative to the shared
+// display's top-left corner. An empty rectangle shares the whole display.
+func (m *MediaManager) SetShareRegion(region image.Rectangle) error {
+	if m == nil {
+		return ErrUnavailable
+	}
+	if region.Empty() {
+		m.shareRegion.Store(nil)
+		return nil
+	}
+	bounds := screenshot.GetDisplayBounds(int(m.shareDisplay.Load()))
+	if !region.Add(bounds.Min).In(bounds) {
+		return ErrBadRegion
+	}
+	m.shareRegion.Store(&region)
+	return nil
+}
+
+// regionPicker lets the sharer type in the part of the display to share
+func (m *MediaManager) regionPicker() fyne.CanvasObject {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("x, y, width, height (empty = whole display)")
+	status := widget.NewLabel("")
+
+	apply := widget.NewButton("Share region", func() {
+		region, err := parseRegion(entry.Text)
+		if err == nil {
+			err = m.SetShareRegion(region)
+		}
+		switch {
+		case err != nil:
+			status.SetText(err.Error())
+		case region.Empty():
+			status.SetText("Sharing whole display")
+		default:
+			status.SetText(fmt.Sprintf("Sharing %dx%d at %d,%d", region.Dx(), region.Dy(), region.Min.X, region.Min.Y))
 		}
-		if err := m.peerConnection.AddICECandidate(candidate); err != nil {
-			fmt.Printf("Error adding candidate: %v\n", err)
+	})
+	return container.NewVBox(container.NewBorder(nil, nil, nil, apply, entry), status)
+}
+
+// parseRegion reads "x, y, width, height"; blank input means no region
+func parseRegion(text string) (image.Rectangle, error) {
+	if strings.TrimSpace(text) == "" {
+		return image.Rectangle{}, nil
+	}
+	parts := strings.Split(text, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, errors.New("expected x, y, width, height")
+	}
+	var n [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || v < 0 {
+			return image.Rectangle{}, fmt.Errorf("invalid number %q", strings.TrimSpace(p))
 		}
+		n[i] = v
+	}
+	if n[2] == 0 || n[3] == 0 {
+		return image.Rectangle{}, errors.New("width and height must be positive")
 	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
+
+// callControls are the call window widgets that change as the call goes on
+type callControls struct {
+	status    *widget.Label
+	recording *widget.Label  // hidden unless recording
+	retry     *widget.Button // hidden unless the connection failed
+	mic       *levelMeter
+	speaker   *levelMeter
 }
 
-func (m *MediaManager) setupUI(status string) {
+// newCallWindow creates the call window with its status label, any extra
+// controls, and a hangup button
+func (m *MediaManager) newCallWindow(title string, status string, extra ...fyne.CanvasObject) (fyne.Window, *callControls) {
+	window := m.app.NewWindow(title)
+	window.Resize(fyne.NewSize(600, 400))
+	window.SetOnClosed(func() {
+		m.Stop()
+	})
+
 	label := widget.NewLabel(status)
 	label.Alignment = fyne.TextAlignCenter
 
@@ -344,20 +643,109 @@ func (m *MediaManager) setupUI(status string) {
 		m.Stop()
 	})
 
-	content := container.NewVBox(
-		label,
-		widget.NewSeparator(),
-		hangupBtn,
-	)
-	m.mediaWindow.SetContent(content)
+	recording := widget.NewLabel("● Recording")
+	recording.Importance = widget.DangerImportance
+	recording.Hide()
+
+	retryBtn := widget.NewButton("Retry", func() {
+		m.retry()
+	})
+	retryBtn.Hide()
+
+	mic, speaker := newLevelMeter(), newLevelMeter()
+	content := container.NewVBox(label, recording, meterRow(mic, speaker), m.volumeControls())
+	for _, c := range extra {
+		content.Add(c)
+	}
+	content.Add(widget.NewSeparator())
+	content.Add(retryBtn)
+	content.Add(hangupBtn)
+	window.SetContent(content)
+	return window, &callControls{status: label, recording: recording, retry: retryBtn, mic: mic, speaker: speaker}
+}
+
+// showConnectionState reflects the ICE state of session in the call window.
+// A failed connection offers a retry and closes the call after failedCallTimeout.
+func (m *MediaManager) showConnectionState(session uint64, state webrtc.ICEConnectionState) {
+	var text string
+	switch state {
+	case webrtc.ICEConnectionStateChecking:
+		text = "Connecting…"
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		text = "Connected"
+	case webrtc.ICEConnectionStateDisconnected:
+		text = "Disconnected"
+	case webrtc.ICEConnectionStateFailed:
+		text = "Failed"
+	default:
+		return
+	}
+
+	m.mutex.Lock()
+	controls := m.controls
+	current := m.session == session
+	m.mutex.Unlock()
+	if !current || controls == nil {
+		return
+	}
+
+	failed := state == webrtc.ICEConnectionStateFailed
+	fyne.Do(func() {
+		controls.status.SetText(text)
+		if failed {
+			controls.retry.Show()
+		} else {
+			controls.retry.Hide()
+		}
+	})
+
+	if failed {
+		time.AfterFunc(failedCallTimeout, func() {
+			m.mutex.Lock()
+			current := m.session == session
+			m.mutex.Unlock()
+			if current {
+				m.Stop()
+			}
+		})
+	}
+}
+
+// retry restarts a failed call with the same peer
+func (m *MediaManager) retry() {
+	m.mutex.Lock()
+	target, share := m.currentTarget, m.isSharingScreen
+	m.mutex.Unlock()
+
+	m.Stop()
+	if target == "" {
+		return
+	}
+	if err := m.startSession(target, share); err != nil {
+		logger.Errorf("Retrying call to %s: %v", target, err)
+	}
 }
 
+// Stop ends the current session and cancels any signal handling in progress
 func (m *MediaManager) Stop() {
-	if m.peerConnection != nil {
-		m.peerConnection.Close()
-		m.peerConnection = nil
+	if m == nil {
+		return
 	}
-	StopAudio()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stop(true)
+}
+
+// stop ends the session, telling the peer to hang up unless it already has.
+// Must be called with m.mutex held.
+func (m *MediaManager) stop(notifyPeer bool) {
+	if notifyPeer && m.currentTarget != "" {
+		data, _ := json.Marshal(SignalMessage{Type: "hangup"})
+		m.sendSignal(m.currentTarget, string(data))
+	}
+
+	m.session++
+	m.closePeer()
 
 	if m.mediaWindow != nil {
 		// Avoid recursive close loop if called from OnClosed
@@ -365,9 +753,25 @@ func (m *MediaManager) Stop() {
 		m.mediaWindow.Close()
 		m.mediaWindow = nil
 	}
+	m.controls = nil
 	m.currentTarget = ""
 }
 
+// closePeer closes the peer connection with its audio and gives back its
+// place under Settings.MaxSessions. A manager without a call leaves the audio
+// devices alone, as they may be another room's. Must be called with m.mutex held.
+func (m *MediaManager) closePeer() {
+	if m.peerConnection != nil {
+		m.peerConnection.Close()
+		m.peerConnection = nil
+		StopAudio()
+	}
+	if m.counted {
+		releaseSession()
+		m.counted = false
+	}
+}
+
 func uint16Ptr(i int) *uint16 {
 	v := uint16(i)
 	return &v
@@ -0,0 +1,165 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+// signalLog records the signals a MediaManager sends, by recipient
+type signalLog struct {
+	mu   sync.Mutex
+	sent map[string][]string // recipient -> signal types
+}
+
+func (l *signalLog) send(target string, data string) {
+	var msg SignalMessage
+	json.Unmarshal([]byte(data), &msg)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sent == nil {
+		l.sent = make(map[string][]string)
+	}
+	l.sent[target] = append(l.sent[target], msg.Type)
+}
+
+func (l *signalLog) to(target string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sent[target]
+}
+
+// newTestManager returns a MediaManager on a headless app that calls
+// without STUN, stopped when the test ends
+func newTestManager(t *testing.T) (*MediaManager, *signalLog) {
+	t.Helper()
+	servers := Settings.ICEServers
+	Settings.ICEServers = nil
+	t.Cleanup(func() { Settings.ICEServers = servers })
+
+	app := test.NewApp()
+	t.Cleanup(app.Quit)
+	log := &signalLog{}
+	m := NewMediaManager(app, log.send)
+	t.Cleanup(m.Stop)
+	return m, log
+}
+
+func TestStopDuringSignals(t *testing.T) {
+	m, _ := newTestManager(t)
+	offer, _ := json.Marshal(SignalMessage{Type: "offer", SDP: "v=0"})
+	signals := []string{
+		string(offer),
+		`{"type":"candidate","candidate":"candidate:1 1 udp 1 127.0.0.1 9 typ host"}`,
+		`{"type":"answer","sdp":"v=0"}`,
+		`{"type":"busy"}`,
+		`{"type":"hangup"}`,
+	}
+
+	var wg sync.WaitGroup
+	for _, from := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for _, signal := range signals {
+					m.HandleSignal(from, signal)
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			m.StartCall("alice")
+			m.Stop()
+		}
+	}()
+	wg.Wait()
+
+	m.Stop()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.peerConnection != nil || m.currentTarget != "" {
+		t.Errorf("after Stop, call with %q still open", m.currentTarget)
+	}
+}
+
+func TestSecondCallIsRefused(t *testing.T) {
+	m, log := newTestManager(t)
+	if err := m.StartCall("alice"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if err := m.StartCall("bob"); !errors.Is(err, ErrInCall) {
+		t.Fatalf("second call = %v, want ErrInCall", err)
+	}
+	if sent := log.to("bob"); len(sent) != 0 {
+		t.Errorf("bob was sent %q, want nothing", sent)
+	}
+
+	m.mutex.Lock()
+	target := m.currentTarget
+	m.mutex.Unlock()
+	if target != "alice" {
+		t.Errorf("current call is with %q, want alice's left alone", target)
+	}
+}
+
+func TestCallAfterHangupIsAllowed(t *testing.T) {
+	m, _ := newTestManager(t)
+	if err := m.StartCall("alice"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	m.Stop()
+	if err := m.StartCall("bob"); err != nil {
+		t.Errorf("call after hanging up = %v, want it to start", err)
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	for _, limit := range []int{2, 3} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			old := Settings.MaxSessions
+			Settings.MaxSessions = limit
+			t.Cleanup(func() { Settings.MaxSessions = old })
+
+			var managers []*MediaManager
+			for i := range limit {
+				m, _ := newTestManager(t)
+				if err := m.StartCall("alice"); err != nil {
+					t.Fatalf("call %d of %d: %v", i+1, limit, err)
+				}
+				managers = append(managers, m)
+			}
+			extra, log := newTestManager(t)
+			if err := extra.StartCall("bob"); !errors.Is(err, ErrTooManySessions) {
+				t.Fatalf("call %d = %v, want ErrTooManySessions", limit+1, err)
+			}
+			if sent := log.to("bob"); len(sent) != 0 {
+				t.Errorf("bob was sent %q, want nothing", sent)
+			}
+
+			managers[0].Stop()
+			if err := extra.StartCall("bob"); err != nil {
+				t.Errorf("call after one hung up = %v, want it to start", err)
+			}
+		})
+	}
+}
+
+func TestNoSessionLimit(t *testing.T) {
+	old := Settings.MaxSessions
+	Settings.MaxSessions = 0
+	t.Cleanup(func() { Settings.MaxSessions = old })
+	for i := range 3 {
+		m, _ := newTestManager(t)
+		if err := m.StartCall("alice"); err != nil {
+			t.Fatalf("call %d without a limit: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package media
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// meterInterval limits how often a level meter redraws; audio arrives every 20-40ms
+const meterInterval = 100 * time.Millisecond
+
+// meterFloor is the quietest level a meter shows, in dBFS
+const meterFloor = -60.0
+
+// levelMeter is a call window bar showing an audio level
+type levelMeter struct {
+	bar  *widget.ProgressBar
+	last atomic.Int64 // UnixNano of the last redraw
+}
+
+// newLevelMeter creates a meter bar with no text, as it changes too fast to read
+func newLevelMeter() *levelMeter {
+	bar := widget.NewProgressBar()
+	bar.TextFormatter = func() string { return "" }
+	return &levelMeter{bar: bar}
+}
+
+// update shows an RMS sample level, called from the audio callbacks
+func (l *levelMeter) update(rms float64) {
+	now := time.Now().UnixNano()
+	last := l.last.Load()
+	if now-last < int64(meterInterval) || !l.last.CompareAndSwap(last, now) {
+		return
+	}
+	level := 0.0
+	if rms > 0 {
+		level = (20*math.Log10(rms/math.MaxInt16) - meterFloor) / -meterFloor
+	}
+	level = max(0, min(1, level))
+	fyne.Do(func() {
+		l.bar.SetValue(level)
+	})
+}
+
+// meterRow lays out the mic and speaker meters for the call window
+func meterRow(mic *levelMeter, speaker *levelMeter) fyne.CanvasObject {
+	return container.NewGridWithColumns(2,
+		container.NewBorder(nil, nil, widget.NewLabel("Mic"), nil, mic.bar),
+		container.NewBorder(nil, nil, widget.NewLabel("Speaker"), nil, speaker.bar),
+	)
+}
@@ -0,0 +1,184 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"fyne.io/fyne/v2"
+
+	"cabinchat/logger"
+)
+
+// ErrRecording is returned when starting a recording while one is running
+var ErrRecording = errors.New("already recording")
+
+// ErrNotRecording is returned when stopping with no recording running
+var ErrNotRecording = errors.New("not recording")
+
+// ErrNoCall is returned when recording is started outside a call
+var ErrNoCall = errors.New("no active call")
+
+// maxPendingMic bounds microphone audio waiting to be mixed in (1 second)
+const maxPendingMic = sampleRate
+
+// callRecorder streams call audio into a WAV file while a recording runs
+type callRecorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	out     *bufio.Writer
+	dataLen int
+	mixMic  bool
+	mic     []int16 // captured samples not yet mixed into the recording
+}
+
+// recorder is shared by the capture and playback paths, like the audio devices
+var recorder callRecorder
+
+// start creates path and begins recording into it
+func (r *callRecorder) start(path string, mixMic bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file != nil {
+		return ErrRecording
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating recording: %w", err)
+	}
+	out := bufio.NewWriter(f)
+	// Placeholder header; the lengths are filled in by stop
+	if err := writeWAVHeader(out, 0); err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.out, r.dataLen = f, out, 0
+	r.mixMic, r.mic = mixMic, nil
+	return nil
+}
+
+// stop finalizes the WAV header and closes the file, returning its path
+func (r *callRecorder) stop() (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return "", ErrNotRecording
+	}
+
+	f := r.file
+	r.file, r.mic = nil, nil
+	err := r.out.Flush()
+	if err == nil {
+		_, err = f.Seek(0, 0)
+	}
+	if err == nil {
+		err = writeWAVHeader(f, r.dataLen)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("finalizing recording: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// received writes a sample of remote audio, mixing in pending microphone audio
+func (r *callRecorder) received(sample int16) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return
+	}
+
+	mixed := int32(sample)
+	if len(r.mic) > 0 {
+		mixed += int32(r.mic[0])
+		r.mic = r.mic[1:]
+	}
+	mixed = max(min(mixed, 32767), -32768)
+
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], uint16(int16(mixed)))
+	if _, err := r.out.Write(b[:]); err == nil {
+		r.dataLen += 2
+	}
+}
+
+// captured queues S16LE microphone audio to be mixed into the recording
+func (r *callRecorder) captured(data []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil || !r.mixMic {
+		return
+	}
+
+	for i := 0; i+1 < len(data); i += 2 {
+		r.mic = append(r.mic, int16(binary.LittleEndian.Uint16(data[i:i+2])))
+	}
+	if drop := len(r.mic) - maxPendingMic; drop > 0 {
+		r.mic = r.mic[drop:] // remote audio stalled; keep the latest
+	}
+}
+
+// StartRecording records the current call's received audio, plus the
+// microphone when Settings.RecordMic is set, to a WAV file at path
+func (m *MediaManager) StartRecording(path string) error {
+	if m == nil {
+		return ErrUnavailable
+	}
+	m.mutex.Lock()
+	active := m.peerConnection != nil
+	m.mutex.Unlock()
+	if !active {
+		return ErrNoCall
+	}
+
+	if err := recorder.start(path, Settings.RecordMic); err != nil {
+		return err
+	}
+	m.showRecording(true)
+	return nil
+}
+
+// StopRecording finishes the recording and returns the file it was saved to
+func (m *MediaManager) StopRecording() (string, error) {
+	if m == nil {
+		return "", ErrUnavailable
+	}
+	path, err := recorder.stop()
+	if !errors.Is(err, ErrNotRecording) {
+		m.showRecording(false)
+	}
+	return path, err
+}
+
+// showRecording toggles the recording indicator in the call window
+func (m *MediaManager) showRecording(on bool) {
+	m.mutex.Lock()
+	controls := m.controls
+	m.mutex.Unlock()
+	if controls == nil {
+		return
+	}
+	fyne.Do(func() {
+		if on {
+			controls.recording.Show()
+		} else {
+			controls.recording.Hide()
+		}
+	})
+}
+
+// finishRecording stops a recording left running when a call ends
+func finishRecording() {
+	if path, err := recorder.stop(); err == nil {
+		logger.Infof("Call ended, recording saved to %s", path)
+	} else if !errors.Is(err, ErrNotRecording) {
+		logger.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package media
+
+import (
+	"encoding/json"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/logger"
+)
+
+// incomingCall is an offer waiting for the user to accept or decline it
+type incomingCall struct {
+	from       string
+	offer      SignalMessage
+	candidates []SignalMessage // ICE candidates that arrived while ringing
+	window     fyne.Window     // only touched on the UI goroutine
+}
+
+// ring asks the user whether to take a call. Must be called with m.mutex held.
+func (m *MediaManager) ring(from string, offer SignalMessage) {
+	call := &incomingCall{from: from, offer: offer}
+	m.ringing = call
+	if m.OnRing != nil {
+		go m.OnRing(from)
+	}
+
+	fyne.Do(func() {
+		window := m.app.NewWindow("Incoming call")
+		window.SetOnClosed(func() {
+			m.declineCall(call)
+		})
+
+		label := widget.NewLabel(from + " is calling")
+		label.Alignment = fyne.TextAlignCenter
+		accept := widget.NewButton("Accept", func() {
+			m.acceptCall(call)
+		})
+		accept.Importance = widget.HighImportance
+		decline := widget.NewButton("Decline", func() {
+			m.declineCall(call)
+		})
+
+		window.SetContent(container.NewVBox(label, container.NewGridWithColumns(2, decline, accept)))
+		call.window = window
+		window.Show()
+	})
+}
+
+// acceptCall answers a ringing call, unless the caller has given up meanwhile
+func (m *MediaManager) acceptCall(call *incomingCall) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	call.dismiss()
+	if m.ringing != call {
+		return
+	}
+	m.ringing = nil
+
+	if m.peerConnection != nil {
+		m.stop(true) // a call started while this one rang; take the new one
+	}
+	m.currentTarget = call.from
+	if err := m.createPeerConnection(); err != nil {
+		logger.Errorf("Error creating PC: %v", err)
+		return
+	}
+	m.mediaWindow, m.controls = m.newCallWindow("Call with "+call.from, "Call from "+call.from)
+	m.mediaWindow.Show()
+
+	m.answer(call.from, call.offer)
+	for _, candidate := range call.candidates {
+		m.addCandidate(candidate)
+	}
+}
+
+// declineCall turns down a ringing call and tells the caller
+func (m *MediaManager) declineCall(call *incomingCall) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	call.dismiss()
+	if m.ringing != call {
+		return
+	}
+	m.ringing = nil
+
+	data, _ := json.Marshal(SignalMessage{Type: "decline"})
+	m.sendSignal(call.from, string(data))
+}
+
+// dismiss closes the ringing window
+func (c *incomingCall) dismiss() {
+	fyne.Do(func() {
+		if c.window != nil {
+			c.window.SetOnClosed(nil)
+			c.window.Close()
+			c.window = nil
+		}
+	})
+}
@@ -2,19 +2,57 @@ package media
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
 	"image/jpeg"
+	"sync/atomic"
 	"time"
 
 	"github.com/kbinani/screenshot"
 	"github.com/nfnt/resize"
 	"github.com/pion/webrtc/v3"
+
+	"cabinchat/logger"
+)
+
+const (
+	// chunkSize keeps each DataChannel message well under the ~64KB limit
+	chunkSize = 16 * 1024
+	// chunkHeader is frame ID (4 bytes), chunk index and chunk count (2 bytes each)
+	chunkHeader = 8
+	// congestedBuffer is how much unsent data means the link can't keep up
+	congestedBuffer = 1 << 20
+	// maxCaptureFailures is how many captures in a row may fail before
+	// sharing stops, about two seconds at the default frame rate
+	maxCaptureFailures = 20
+	// shareFailedPrefix starts the text message telling the viewer that
+	// sharing stopped; the reason follows
+	shareFailedPrefix = "share failed: "
 )
 
-// StartScreenShare captures screen and sends JPEG frames over DataChannel
-func StartScreenShare(dc *webrtc.DataChannel) {
+// ErrNoDisplay is returned when there is no display to capture, e.g. on a
+// headless machine
+var ErrNoDisplay = errors.New("no display to capture")
+
+// StartScreenShare captures the display held in display and sends JPEG frames
+// over DataChannel. A non-empty region, relative to the display's top-left
+// corner, limits capture to that rectangle. Both can be changed while sharing;
+// if the display goes away the primary display is captured instead. If
+// capturing keeps failing, sharing stops, the viewer is told why and onFail
+// is called.
+func StartScreenShare(dc *webrtc.DataChannel, display *atomic.Int32, region *atomic.Pointer[image.Rectangle], onFail func(err error)) {
+	q := Settings.ScreenShare
+	if q.FPS <= 0 {
+		q.FPS = 10
+	}
+
 	go func() {
-		ticker := time.NewTicker(100 * time.Millisecond) // 10 FPS
+		fps := q.FPS
+		var frameID uint32
+		failures := 0
+		ticker := time.NewTicker(time.Second / time.Duration(fps))
 		defer ticker.Stop()
 
 		for range ticker.C {
@@ -22,43 +60,159 @@ func StartScreenShare(dc *webrtc.DataChannel) {
 				return
 			}
 
-			// Capture primary display
-			bounds := screenshot.GetDisplayBounds(0)
-			img, err := screenshot.CaptureRect(bounds)
-			if err != nil {
-				fmt.Printf("Capture error: %v\n", err)
+			// Back off while the peer is still draining earlier frames,
+			// creep back up to the target once it catches up
+			if dc.BufferedAmount() > congestedBuffer {
+				if fps > 1 {
+					fps /= 2
+					ticker.Reset(time.Second / time.Duration(fps))
+					logger.Debugf("Screen share congested, dropping to %d FPS", fps)
+				}
 				continue
+			} else if fps < q.FPS && dc.BufferedAmount() == 0 {
+				fps++
+				ticker.Reset(time.Second / time.Duration(fps))
 			}
 
-			// Resize to reasonable size (e.g., width 800) to reduce bandwidth
-			// Maintain aspect ratio
-			resized := resize.Resize(800, 0, img, resize.Lanczos3)
+			img, err := captureDisplay(display, region)
+			if err != nil {
+				failures++
+				if failures < maxCaptureFailures {
+					logger.Debugf("Capture error: %v", err)
+					continue
+				}
+				logger.Errorf("Screen capture keeps failing, stopping the share: %v", err)
+				dc.SendText(shareFailedPrefix + err.Error())
+				if onFail != nil {
+					onFail(err)
+				}
+				return
+			}
+			failures = 0
 
-			// Encode to JPEG
-			var buf bytes.Buffer
-			if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 70}); err != nil {
-				fmt.Printf("JPEG Encode error: %v\n", err)
+			data, err := encodeFrame(img, q)
+			if err != nil {
+				logger.Errorf("JPEG Encode error: %v", err)
 				continue
 			}
 
-			// Send over DataChannel
-			// Note: DataChannels have a max message size (typ 64KB or 256KB depending on impl)
-			// JPEGs might be larger. We might need to chunk.
-			// For 800px width ~70 quality, it should be < 64KB usually.
-			// Let's implement simple chunking if needed or rely on Pion handling it (Pion DC supports larger messages by chunking internally? No, usually you handled it)
-			// For simplicity we try to send as one unless error.
-
-			data := buf.Bytes()
-			if len(data) > 60000 {
-				// Too big for single message safety zone?
-				// Just skip frame or assume Pion handles fragmentation (SCTP layer does).
-				// Pion SCTP supports fragmentation. Open returns a DetachedDataChannel which is a ReadWriteCloser.
-				// But here we have *webrtc.DataChannel.
+			frameID++
+			for _, chunk := range splitFrame(frameID, data) {
+				if err := dc.Send(chunk); err != nil {
+					logger.Debugf("Screen frame send error: %v", err)
+					break
+				}
 			}
+		}
+	}()
+}
 
-			if err := dc.Send(data); err != nil {
-				// fmt.Printf("Send error: %v\n", err)
-			}
+// captureDisplay captures the display held in display, or its region. Some
+// platforms (headless, some Wayland sessions) panic rather than fail, which
+// is returned as an error too.
+func captureDisplay(display *atomic.Int32, region *atomic.Pointer[image.Rectangle]) (img *image.RGBA, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("capture failed: %v", r)
 		}
 	}()
+
+	count := screenshot.NumActiveDisplays()
+	if count == 0 {
+		return nil, ErrNoDisplay
+	}
+	index := int(display.Load())
+	if index < 0 || index >= count {
+		logger.Warnf("Display %d disconnected, sharing primary display", index+1)
+		index = 0
+		display.Store(0)
+	}
+	bounds := screenshot.GetDisplayBounds(index)
+	if r := region.Load(); r != nil {
+		bounds = clipRegion(*r, bounds)
+	}
+	return screenshot.CaptureRect(bounds)
+}
+
+// clipRegion places a display-relative region on the display, falling back to
+// the whole display when the region doesn't overlap it
+func clipRegion(region image.Rectangle, display image.Rectangle) image.Rectangle {
+	clipped := region.Add(display.Min).Intersect(display)
+	if clipped.Empty() {
+		return display
+	}
+	return clipped
+}
+
+// encodeFrame scales img to q.MaxWidth and encodes it as JPEG
+func encodeFrame(img image.Image, q ScreenShareQuality) ([]byte, error) {
+	if q.MaxWidth > 0 && img.Bounds().Dx() > q.MaxWidth {
+		img = resize.Resize(uint(q.MaxWidth), 0, img, resize.Lanczos3) // keeps aspect ratio
+	}
+
+	quality := q.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = 70
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// splitFrame cuts an encoded frame into DataChannel-sized chunks, each
+// prefixed with the frame ID, its index and the chunk count
+func splitFrame(id uint32, data []byte) [][]byte {
+	count := (len(data) + chunkSize - 1) / chunkSize
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*chunkSize, len(data))
+		chunk := make([]byte, chunkHeader, chunkHeader+end-i*chunkSize)
+		binary.BigEndian.PutUint32(chunk[0:4], id)
+		binary.BigEndian.PutUint16(chunk[4:6], uint16(i))
+		binary.BigEndian.PutUint16(chunk[6:8], uint16(count))
+		chunks = append(chunks, append(chunk, data[i*chunkSize:end]...))
+	}
+	return chunks
+}
+
+// frameAssembler rebuilds frames from chunks produced by splitFrame. A chunk
+// of a newer frame discards whatever is left of an incomplete older one.
+type frameAssembler struct {
+	id       uint32
+	chunks   [][]byte
+	received int
+}
+
+// add stores a chunk and returns the whole frame once its last chunk arrives
+func (f *frameAssembler) add(msg []byte) []byte {
+	if len(msg) < chunkHeader {
+		return nil
+	}
+	id := binary.BigEndian.Uint32(msg[0:4])
+	index := int(binary.BigEndian.Uint16(msg[4:6]))
+	count := int(binary.BigEndian.Uint16(msg[6:8]))
+	if count == 0 || index >= count {
+		return nil
+	}
+
+	if f.chunks == nil || id > f.id {
+		f.id = id
+		f.chunks = make([][]byte, count)
+		f.received = 0
+	} else if id < f.id || count != len(f.chunks) {
+		return nil // stale chunk
+	}
+	if f.chunks[index] == nil {
+		f.chunks[index] = msg[chunkHeader:]
+		f.received++
+	}
+	if f.received < count {
+		return nil
+	}
+
+	frame := bytes.Join(f.chunks, nil)
+	f.chunks = nil
+	return frame
 }
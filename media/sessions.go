@@ -0,0 +1,36 @@
+package media
+
+import "sync"
+
+// openSessions counts the calls open across every MediaManager: someone in
+// several rooms has a manager for each, and Settings.MaxSessions caps them all
+var openSessions struct {
+	sync.Mutex
+	n int
+}
+
+// acquireSession takes a place for a call, reporting false when
+// Settings.MaxSessions calls are already open
+func acquireSession() bool {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	if Settings.MaxSessions > 0 && openSessions.n >= Settings.MaxSessions {
+		return false
+	}
+	openSessions.n++
+	return true
+}
+
+// releaseSession gives back a place taken by acquireSession
+func releaseSession() {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	openSessions.n--
+}
+
+// atSessionLimit reports whether another call would exceed Settings.MaxSessions
+func atSessionLimit() bool {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	return Settings.MaxSessions > 0 && openSessions.n >= Settings.MaxSessions
+}
@@ -0,0 +1,32 @@
+package media
+
+// ScreenShareQuality controls the frames sent while sharing a screen
+type ScreenShareQuality struct {
+	FPS         int // Target frame rate; lowered automatically on a congested link
+	MaxWidth    int // Frames wider than this are scaled down
+	JPEGQuality int // 1-100
+}
+
+// ICEServer is a STUN or TURN server used to find a route between call peers
+type ICEServer struct {
+	URLs       []string // e.g. "stun:host:3478" or "turn:host:3478"
+	Username   string   // TURN only
+	Credential string   // TURN only
+}
+
+// Settings holds user-configurable media options
+var Settings = struct {
+	MaxSessions   int // Calls open at once across all rooms, 0 = unlimited
+	ScreenShare   ScreenShareQuality
+	RecordMic     bool        // Mix the microphone into /record recordings
+	ReplaySeconds int         // Received call audio kept for /replay, 0 = don't record
+	ICEServers    []ICEServer // Empty = LAN host candidates only, works offline
+	Volume        int         // Call playback volume in percent, 0-150; set with SetVolume
+	NoiseGate     int         // Mic frames with an RMS level below this aren't sent, 0 = off
+}{
+	MaxSessions: 1,
+	Volume:      100,
+	NoiseGate:   300, // about -40dBFS: room hiss, not speech
+	ScreenShare: ScreenShareQuality{FPS: 10, MaxWidth: 800, JPEGQuality: 70},
+	ICEServers:  []ICEServer{{URLs: []string{"stun:stun.l.google.com:19302"}}},
+}
@@ -0,0 +1,97 @@
+package media
+
+import (
+	"embed"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// Sound identifies a notification sound
+type Sound int
+
+const (
+	SoundJoin Sound = iota
+	SoundLeave
+	SoundMessage
+	SoundCall
+	SoundMention
+)
+
+//go:embed sounds/*.wav
+var soundFiles embed.FS
+
+// soundNames maps each Sound to its file in sounds/, all 48kHz mono 16-bit WAV
+var soundNames = map[Sound]string{
+	SoundJoin:    "sounds/join.wav",
+	SoundLeave:   "sounds/leave.wav",
+	SoundMessage: "sounds/message.wav",
+	SoundCall:    "sounds/call.wav",
+	SoundMention: "sounds/mention.wav",
+}
+
+// PlaySound plays a notification sound through the default output device,
+// returning once playback has started
+func PlaySound(kind Sound) error {
+	data, err := soundFiles.ReadFile(soundNames[kind])
+	if err != nil {
+		return err
+	}
+	if len(data) > wavHeaderSize {
+		data = data[wavHeaderSize:]
+	}
+	return playPCM(data)
+}
+
+// playPCM plays 48kHz mono 16-bit samples through the default output device,
+// returning once playback has started
+func playPCM(data []byte) error {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
+	})
+	if err != nil {
+		return err
+	}
+
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
+	deviceConfig.Playback.Format = malgo.FormatS16
+	deviceConfig.Playback.Channels = 1
+	deviceConfig.SampleRate = sampleRate
+
+	done := make(chan struct{})
+	pos := 0
+	onSend := func(pOutputSample, pInputSample []byte, framecount uint32) {
+		n := copy(pOutputSample, data[pos:])
+		clear(pOutputSample[n:])
+		pos += n
+		if pos == len(data) && n == 0 {
+			select {
+			case <-done:
+			default:
+				close(done) // a whole silent period has gone out after the sound
+			}
+		}
+	}
+
+	device, err := malgo.InitDevice(ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: onSend,
+	})
+	if err != nil {
+		ctx.Free()
+		return err
+	}
+	if err := device.Start(); err != nil {
+		device.Uninit()
+		ctx.Free()
+		return err
+	}
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second): // device stalled
+		}
+		device.Uninit()
+		ctx.Free()
+	}()
+	return nil
+}
@@ -0,0 +1,84 @@
+package media
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/logger"
+)
+
+// Playback volume range in percent; above 100 boosts quiet callers
+const (
+	minVolume = 0
+	maxVolume = 150
+)
+
+// prefVolume is the preference key the last call volume is kept under
+const prefVolume = "volume"
+
+// volume is the playback gain in percent, read from the audio callback
+var volume atomic.Int32
+
+func init() {
+	volume.Store(int32(Settings.Volume))
+}
+
+// SetVolume sets the call playback volume in percent, clamped to 0-150%
+func SetVolume(percent int) {
+	percent = max(minVolume, min(maxVolume, percent))
+	Settings.Volume = percent
+	volume.Store(int32(percent))
+}
+
+// applyGain scales a sample by the playback volume, clipping instead of wrapping around
+func applyGain(sample int16) int16 {
+	v := int32(sample) * volume.Load() / 100
+	return int16(max(math.MinInt16, min(math.MaxInt16, v)))
+}
+
+// testToneSeconds is how long the speaker test plays
+const testToneSeconds = 0.6
+
+// PlayTestTone plays a short 440Hz tone at the playback volume, to check
+// the speakers before a call
+func PlayTestTone() error {
+	n := int(testToneSeconds * sampleRate)
+	data := make([]byte, 2*n)
+	for i := range n {
+		// Fade in and out over 20ms so the tone doesn't click
+		fade := min(1, float64(i)/(0.02*sampleRate), float64(n-i)/(0.02*sampleRate))
+		s := int16(0.3 * fade * math.MaxInt16 * math.Sin(2*math.Pi*440*float64(i)/sampleRate))
+		s = applyGain(s)
+		data[2*i] = byte(s)
+		data[2*i+1] = byte(uint16(s) >> 8)
+	}
+	return playPCM(data)
+}
+
+// volumeControls is the call window's volume slider and speaker test
+func (m *MediaManager) volumeControls() fyne.CanvasObject {
+	label := widget.NewLabel("")
+	slider := widget.NewSlider(minVolume, maxVolume)
+	slider.Step = 5
+	slider.Value = float64(volume.Load())
+	label.SetText(fmt.Sprintf("Volume %d%%", int(slider.Value)))
+	slider.OnChanged = func(v float64) {
+		SetVolume(int(v))
+		label.SetText(fmt.Sprintf("Volume %d%%", int(v)))
+	}
+	slider.OnChangeEnded = func(v float64) {
+		m.app.Preferences().SetInt(prefVolume, int(v))
+	}
+
+	test := widget.NewButton("Test speaker", func() {
+		if err := PlayTestTone(); err != nil {
+			logger.Warnf("Speaker test failed: %v", err)
+		}
+	})
+	return container.NewBorder(nil, nil, label, test, slider)
+}
@@ -0,0 +1,42 @@
+package media
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyGain(t *testing.T) {
+	old := Settings.Volume
+	defer SetVolume(old)
+
+	tests := []struct {
+		volume       int
+		sample, want int16
+	}{
+		{100, 1234, 1234},
+		{50, -1000, -500},
+		{0, math.MaxInt16, 0},
+		{150, 1000, 1500},
+		{150, 30000, math.MaxInt16},  // clipped, not wrapped to negative
+		{150, -30000, math.MinInt16}, // clipped, not wrapped to positive
+		{150, math.MinInt16, math.MinInt16},
+	}
+	for _, tt := range tests {
+		SetVolume(tt.volume)
+		if got := applyGain(tt.sample); got != tt.want {
+			t.Errorf("applyGain(%d) at %d%% = %d, want %d", tt.sample, tt.volume, got, tt.want)
+		}
+	}
+}
+
+func TestSetVolumeClamps(t *testing.T) {
+	old := Settings.Volume
+	defer SetVolume(old)
+
+	for in, want := range map[int]int{-20: minVolume, 80: 80, 400: maxVolume} {
+		SetVolume(in)
+		if Settings.Volume != want || volume.Load() != int32(want) {
+			t.Errorf("SetVolume(%d) set %d (gain %d), want %d", in, Settings.Volume, volume.Load(), want)
+		}
+	}
+}
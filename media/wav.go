@@ -0,0 +1,32 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// sampleRate is the rate used for all call audio
+const sampleRate = 48000
+
+// wavHeaderSize is the size of the canonical 16-bit PCM WAV header
+const wavHeaderSize = 44
+
+// writeWAVHeader writes a mono 16-bit PCM WAV header for dataLen bytes of samples
+func writeWAVHeader(w io.Writer, dataLen int) error {
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataLen))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:22], 1)  // PCM
+	binary.LittleEndian.PutUint16(header[22:24], 1)  // mono
+	binary.LittleEndian.PutUint32(header[24:28], sampleRate)
+	binary.LittleEndian.PutUint32(header[28:32], sampleRate*2) // byte rate
+	binary.LittleEndian.PutUint16(header[32:34], 2)            // block align
+	binary.LittleEndian.PutUint16(header[34:36], 16)           // bits per sample
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataLen))
+	_, err := w.Write(header)
+	return err
+}
@@ -1,16 +1,31 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
 	"cabinchat/core"
+	"cabinchat/i18n"
+	"cabinchat/logger"
+)
+
+// Preference keys
+const (
+	prefAutoAcceptFrom   = "autoAcceptFrom"
+	prefSkipLeaveConfirm = "skipLeaveConfirm" // "Don't ask again" when leaving a room
+	prefBans             = "bans"             // "ip nick" per banned address
+	prefAliases          = "aliases"          // "nick<TAB>color<TAB>name" per /alias
+	prefDownloadDir      = "downloadDir"      // set by /savedir
 )
 
 // App manages the Fyne application state
@@ -18,6 +33,11 @@ type App struct {
 	FyneApp    fyne.App
 	Window     fyne.Window
 	CurrentLoc string
+	Notifier   *Notifier
+	unread     *unreadCounter
+	presence   *core.Presence  // advertised while on the welcome screen, for /invite
+	idle       *core.IdleTimer // marks the user away when they stop typing
+	rooms      *core.RoomRegistry
 
 	// Active Session
 	Host   *core.Host
@@ -27,22 +47,115 @@ type App struct {
 // NewApp creates a new UI application
 func NewApp() *App {
 	a := &App{
-		FyneApp: app.New(),
+		FyneApp: app.NewWithID("com.cabinchat.app"),
+		rooms:   core.NewRoomRegistry(),
 	}
-	a.Window = a.FyneApp.NewWindow("CabinChat")
+	core.Settings.AutoAcceptFrom = a.FyneApp.Preferences().StringList(prefAutoAcceptFrom)
+	core.Settings.Bans = loadBans(a.FyneApp.Preferences().StringList(prefBans))
+	core.Settings.Aliases = loadAliases(a.FyneApp.Preferences().StringList(prefAliases))
+	core.Settings.DownloadDir = a.FyneApp.Preferences().String(prefDownloadDir)
+	applyTheme(a.FyneApp)
+	a.Notifier = NewNotifier(a.FyneApp)
+	a.Window = a.FyneApp.NewWindow(windowTitle)
 	a.Window.Resize(fyne.NewSize(800, 600))
+	a.Window.SetCloseIntercept(a.onCloseWindow)
+	a.unread = newUnreadCounter(a.Window)
+	a.FyneApp.Lifecycle().SetOnEnteredForeground(func() {
+		a.Notifier.SetFocused(true)
+		a.unread.SetFocused(true)
+	})
+	a.FyneApp.Lifecycle().SetOnExitedForeground(func() {
+		a.Notifier.SetFocused(false)
+		a.unread.SetFocused(false)
+	})
 	return a
 }
 
-// Run starts the application loop
+// Run starts the application loop, joining core.Settings.Join straight away if set
 func (a *App) Run() {
 	a.ShowWelcome()
+	if core.Settings.Join != "" {
+		a.connectTo(core.Settings.Join, a.defaultNick())
+	}
 	a.Window.ShowAndRun()
 }
 
+// loadBans parses the ban list stored under prefBans
+func loadBans(entries []string) []core.Ban {
+	var bans []core.Ban
+	for _, entry := range entries {
+		ip, nick, _ := strings.Cut(entry, " ")
+		bans = append(bans, core.Ban{IP: ip, Nick: nick})
+	}
+	return bans
+}
+
+// saveBans formats a ban list for prefBans
+func saveBans(bans []core.Ban) []string {
+	entries := make([]string, len(bans))
+	for i, ban := range bans {
+		entries[i] = ban.IP + " " + ban.Nick
+	}
+	return entries
+}
+
+// loadAliases parses the aliases stored under prefAliases
+func loadAliases(entries []string) []core.Alias {
+	var aliases []core.Alias
+	for _, entry := range entries {
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		aliases = append(aliases, core.Alias{Nick: fields[0], Color: fields[1], Name: fields[2]})
+	}
+	return aliases
+}
+
+// formatAliases formats aliases for prefAliases
+func formatAliases(aliases []core.Alias) []string {
+	entries := make([]string, len(aliases))
+	for i, alias := range aliases {
+		entries[i] = alias.Nick + "\t" + alias.Color + "\t" + alias.Name
+	}
+	return entries
+}
+
+// saveAliases keeps /alias changes across restarts
+func (a *App) saveAliases(aliases []core.Alias) {
+	a.FyneApp.Preferences().SetStringList(prefAliases, formatAliases(aliases))
+}
+
+// saveDownloadDir keeps the /savedir choice across restarts
+func (a *App) saveDownloadDir(dir string) {
+	a.FyneApp.Preferences().SetString(prefDownloadDir, dir)
+}
+
+// defaultNick is the nickname offered before the user picks one
+func (a *App) defaultNick() string {
+	if core.Settings.Nick != "" {
+		return core.Settings.Nick
+	}
+	return "Traveler"
+}
+
+// connectTo joins a room by cabinchat:// link or address
+func (a *App) connectTo(address string, nick string) {
+	room, err := core.ParseJoinAddress(address)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	a.JoinRoom(room, nick)
+}
+
 // ShowWelcome displays the initial welcome screen with auto-discovery
 func (a *App) ShowWelcome() {
 	a.CurrentLoc = "welcome"
+	if a.idle != nil {
+		a.idle.Stop()
+		a.idle = nil
+	}
 
 	// 1. Header
 	title := widget.NewLabel("🏔️ CabinChat")
@@ -50,41 +163,45 @@ func (a *App) ShowWelcome() {
 	title.Alignment = fyne.TextAlignCenter
 
 	// 2. Room List
-	listTitle := widget.NewLabel("Discovered Rooms:")
+	listTitle := widget.NewLabel(i18n.T("welcome.rooms"))
 	listTitle.TextStyle = fyne.TextStyle{Bold: true}
 
-	roomData := []core.DiscoveredRoom{}
+	roomData := a.rooms.Rooms()
 	list := widget.NewList(
 		func() int { return len(roomData) },
 		func() fyne.CanvasObject { return widget.NewLabel("Room Name (IP)") },
 		func(i widget.ListItemID, o fyne.CanvasObject) {
 			r := roomData[i]
-			o.(*widget.Label).SetText(fmt.Sprintf("%s (%s:%d)", "CabinRoom", r.Host, r.Port)) // Name is not in struct yet, using placeholder
+			text := fmt.Sprintf("%s (%s:%d)", r.Name, r.Host, r.Port)
+			if r.Stale {
+				text += i18n.T("welcome.lastSeen", time.Since(r.LastSeen).Round(time.Second))
+			}
+			o.(*widget.Label).SetText(text)
 		},
 	)
 
 	// Handle Join
 	nickEntry := widget.NewEntry()
-	nickEntry.SetPlaceHolder("Enter Nickname")
-	nickEntry.Text = "Traveler"
+	nickEntry.SetPlaceHolder(i18n.T("welcome.nickPlaceholder"))
+	nickEntry.Text = a.defaultNick()
 
 	list.OnSelected = func(i widget.ListItemID) {
 		if nickEntry.Text == "" {
-			dialog.ShowError(fmt.Errorf("Please enter a nickname first"), a.Window)
+			dialog.ShowError(errors.New(i18n.T("welcome.needNick")), a.Window)
 			list.Unselect(i)
 			return
 		}
-		a.JoinRoom(roomData[i].Host, roomData[i].Port, nickEntry.Text)
+		a.JoinRoom(roomData[i], nickEntry.Text)
 	}
 
 	// 3. Status
-	status := widget.NewLabel("Scanning network...")
+	status := widget.NewLabel(i18n.T("welcome.scanning"))
 	status.Alignment = fyne.TextAlignCenter
 
 	// 4. Host Controls
-	hostBtn := widget.NewButton("Start New Room", func() {
+	hostBtn := widget.NewButton(i18n.T("welcome.host"), func() {
 		if nickEntry.Text == "" {
-			dialog.ShowError(fmt.Errorf("Please enter a nickname"), a.Window)
+			dialog.ShowError(errors.New(i18n.T("welcome.needNick")), a.Window)
 			return
 		}
 		a.StartHost(nickEntry.Text)
@@ -95,10 +212,30 @@ func (a *App) ShowWelcome() {
 	// Center: List
 	// Bottom: Controls
 
+	// 5. Manual connect, for when discovery can't see the room
+	addressEntry := widget.NewEntry()
+	addressEntry.SetPlaceHolder(i18n.T("welcome.addressPlaceholder"))
+	connect := func(address string) {
+		if nickEntry.Text == "" {
+			dialog.ShowError(errors.New(i18n.T("welcome.needNick")), a.Window)
+			return
+		}
+		a.connectTo(address, nickEntry.Text)
+	}
+	addressEntry.OnSubmitted = connect
+	connectBtn := widget.NewButton(i18n.T("welcome.connect"), func() {
+		connect(addressEntry.Text)
+	})
+
+	diagnoseBtn := widget.NewButton(i18n.T("welcome.diagnose"), a.showDiagnostics)
+	diagnoseBtn.Importance = widget.LowImportance
+
 	bottomPanel := container.NewVBox(
 		status,
 		nickEntry,
 		hostBtn,
+		container.NewBorder(nil, nil, nil, connectBtn, addressEntry),
+		diagnoseBtn,
 	)
 
 	content := container.NewBorder(
@@ -109,6 +246,13 @@ func (a *App) ShowWelcome() {
 	)
 
 	a.Window.SetContent(content)
+	a.advertisePresence(func(link string) {
+		nick := nickEntry.Text
+		if nick == "" {
+			nick = a.defaultNick()
+		}
+		a.connectTo(link, nick)
+	})
 
 	// Start Scanning in background
 	go func() {
@@ -118,16 +262,16 @@ func (a *App) ShowWelcome() {
 				return
 			}
 
-			rooms := core.FindRooms(7777)
+			rooms := a.rooms.Merge(core.FindRooms(7777))
 
 			// Update UI on main thread using fyne.Do
 			fyne.Do(func() {
 				roomData = rooms
 
 				if len(rooms) == 0 {
-					status.SetText("No rooms found. Be the first to host! (Scanning...)")
+					status.SetText(i18n.T("welcome.noRooms"))
 				} else {
-					status.SetText(fmt.Sprintf("Found %d rooms", len(rooms)))
+					status.SetText(i18n.T("welcome.found", len(rooms)))
 				}
 				list.Refresh()
 			})
@@ -135,13 +279,69 @@ func (a *App) ShowWelcome() {
 	}()
 }
 
+// showDiagnostics runs the network self-check and shows the results
+func (a *App) showDiagnostics() {
+	progress := dialog.NewCustomWithoutButtons(i18n.T("diagnose.checking"), widget.NewProgressBarInfinite(), a.Window)
+	progress.Show()
+	go func() {
+		report := core.RunDiagnostics().String()
+		fyne.Do(func() {
+			progress.Hide()
+			text := widget.NewLabel(report)
+			copyBtn := widget.NewButtonWithIcon(i18n.T("copy"), theme.ContentCopyIcon(), func() {
+				a.FyneApp.Clipboard().SetContent(report)
+			})
+			dialog.ShowCustom(i18n.T("diagnose.title"), i18n.T("close"), container.NewVBox(text, copyBtn), a.Window)
+		})
+	}()
+}
+
+// advertisePresence lets others /invite this idle instance, calling join
+// with the room link of an accepted invite
+func (a *App) advertisePresence(join func(link string)) {
+	a.stopPresence()
+	presence, err := core.StartPresence(a.defaultNick(), func(invite core.Invite) {
+		fyne.Do(func() {
+			if a.CurrentLoc != "welcome" {
+				return
+			}
+			question := i18n.T("invite.question", invite.From)
+			dialog.ShowConfirm(i18n.T("invite.title"), question, func(ok bool) {
+				if ok && a.CurrentLoc == "welcome" {
+					join(invite.Link)
+				}
+			}, a.Window)
+		})
+	})
+	if err != nil {
+		logger.Warnf("Not accepting invites: %v", err)
+		return
+	}
+	a.presence = presence
+}
+
+// stopPresence stops advertising once the user is in a room
+func (a *App) stopPresence() {
+	if a.presence != nil {
+		a.presence.Stop()
+		a.presence = nil
+	}
+}
+
 // StartHost starts the host and switches to chat view
 func (a *App) StartHost(nick string) {
+	a.CurrentLoc = "chat"
+	a.stopPresence()
 	// 1. Create UI callbacks
 	var chatScreen *ChatScreen
 	callbacks := core.HostCallbacks{
 		OnMessageReceived: func(msg core.Message) {
-			chatScreen.AppendMessage(msg.Nick, msg.Text, false)
+			isMe := msg.Nick == chatScreen.Nick
+			chatScreen.AppendMessage(msg, isMe)
+			if !isMe {
+				a.Notifier.Message(core.DisplayNick(msg.Nick), msg.Text, chatScreen.Nick)
+				a.unread.Message()
+			}
 		},
 		OnSystemMessage: func(text string) {
 			chatScreen.AppendSystemMessage(text)
@@ -149,50 +349,95 @@ func (a *App) StartHost(nick string) {
 		OnUserList: func(users []string) {
 			chatScreen.UpdateUserList(users)
 		},
+		OnPresence: func(nick string, joined bool) {
+			a.Notifier.Presence(joined)
+		},
+		OnPoll: func(poll core.Poll) {
+			chatScreen.AppendPoll(poll)
+		},
+		OnReaction: func(id string, emoji string, count int) {
+			chatScreen.UpdateReaction(id, emoji, count)
+		},
+		OnClear: func() {
+			chatScreen.ClearHistory()
+		},
+		OnExport: func(path string) {
+			a.exportTranscript(chatScreen, path)
+		},
+		OnNickChanged: func(newNick string) {
+			chatScreen.SetNick(newNick)
+		},
+		OnTopic: func(topic string) {
+			chatScreen.SetTopic(topic)
+		},
+		OnMessageEdited: func(id string, text string) {
+			chatScreen.EditMessage(id, text)
+		},
+		OnMessageDeleted: func(id string) {
+			chatScreen.DeleteMessage(id)
+		},
+		OnBansChanged: func(bans []core.Ban) {
+			a.FyneApp.Preferences().SetStringList(prefBans, saveBans(bans))
+		},
+		OnAliasesChanged: a.saveAliases,
+		OnSaveDirChanged: a.saveDownloadDir,
+		OnClosed: func(reason string) {
+			fyne.Do(func() {
+				a.Host = nil
+				a.ShowWelcome()
+				dialog.ShowInformation(i18n.T("room.closedTitle"), reason, a.Window)
+			})
+		},
+		OnAddressChanged: func(addr string) {
+			chatScreen.SetHostAddress(addr)
+		},
 		OnFileOffer: func(offer core.PendingOffer) {
-			dialog.ShowConfirm("File Offer", fmt.Sprintf("%s wants to send %s. Accept?", offer.SenderNick, offer.Filename), func(b bool) {
+			a.confirmFileOffer(offer.SenderNick, offer.SenderAddr, i18n.T("file.offer", offer.SenderNick, offer.Filename), func(b bool) {
 				if b {
-					a.Host.SendText("/accept") // Host accepts via command
+					a.Host.SendText("/accept " + offer.SenderNick) // Host accepts via command
 				} else {
-					a.Host.SendText("/reject")
+					a.Host.SendText("/reject " + offer.SenderNick)
 				}
-			}, a.Window)
+			})
 		},
-		OnFileReceived: func(filename, data, sender string) {
-			// Trigger save dialog or auto-save
-			chatScreen.AppendSystemMessage(fmt.Sprintf("Received file: %s", filename))
+		OnFileReceived: func(filename string, data []byte, sender string, err error) {
+			a.saveReceivedFile(chatScreen, filename, data, sender, err)
 		},
 	}
 
 	// 2. Create Host
-	a.Host = core.NewHost(nick, a.FyneApp, callbacks)
+	a.Host = core.NewHost(nick, a.FyneApp, callbacks, nil)
 
 	// 3. Create Chat Screen
 	chatScreen = NewChatScreen(a, nick, true, func(text string) {
+		if core.IsQuitCommand(text) {
+			a.leaveSession(false)
+			return
+		}
 		output, err := a.Host.SendText(text)
 		if err != nil {
-			chatScreen.AppendSystemMessage(fmt.Sprintf("Error: %v", err))
+			chatScreen.AppendSystemMessage(i18n.T("error", err))
 		}
 		if output != "" {
 			chatScreen.AppendSystemMessage(output)
 		}
-		// Echo own message is handled by Sync/Broadcast logic via OnMessageReceived in Host?
-		// Actually Host logic broadcasts to all clients. Host UI is local.
-		// Host.SendText writes to clients.
-		// Does Host.SendText show up in OnMessageReceived?
-		// hostInputLoop printed locally.
-		// SendText calls ProcessCommand or Broadcast.
-		// If Broadcast, it sends to clients. It does NOT call OnMessageReceived for self?
-		// We should verify. For now, add it manually if it wasn't a command.
-		if !strings.HasPrefix(text, "/") {
-			chatScreen.AppendMessage(nick, text, true)
-		}
+		// Own messages are echoed back through OnMessageReceived once they have an ID
 	})
+	chatScreen.OnReact = a.Host.React
+	chatScreen.OnEdit = a.Host.EditMessage
+	chatScreen.OnDelete = a.Host.DeleteMessage
+	chatScreen.OnReply = a.Host.Reply
+	a.watchIdle(a.Host, chatScreen)
+	chatScreen.OnPasteImage = func(filename string, data []byte) {
+		a.Host.OfferBytes(filename, data, "")
+	}
 
 	// 4. Start Host logic
 	err := a.Host.Start()
 	if err != nil {
-		dialog.ShowError(err, a.Window)
+		a.Host = nil
+		a.ShowWelcome()
+		a.hostFailed(nick, err)
 		return
 	}
 
@@ -203,16 +448,61 @@ func (a *App) StartHost(nick string) {
 	// But NewChatScreen returns *ChatScreen.
 }
 
+// takeOverRoom starts hosting when the host hands the room over to us,
+// returning the link everyone reconnects to. The old connection ends once
+// the host has the link.
+func (a *App) takeOverRoom(nick string) (string, error) {
+	client := a.Client
+	a.Client = nil
+	if a.idle != nil {
+		a.idle.Stop()
+	}
+	// Rather than fail if this machine already hosts on the port
+	autoPort := core.Settings.AutoPort
+	core.Settings.AutoPort = true
+	defer func() { core.Settings.AutoPort = autoPort }()
+
+	a.StartHost(nick)
+	if a.Host == nil {
+		a.Client = client
+		return "", errors.New("couldn't start hosting")
+	}
+	return a.Host.JoinURL(), nil
+}
+
+// hostFailed explains why hosting didn't start. When the port is taken it
+// offers to retry on one the OS picks.
+func (a *App) hostFailed(nick string, err error) {
+	if !errors.Is(err, core.ErrPortInUse) {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+	question := i18n.T("host.portInUse", core.Settings.Port)
+	dialog.ShowConfirm(i18n.T("host.portInUseTitle"), question, func(ok bool) {
+		if ok {
+			core.Settings.Port = 0 // Start stores the port it gets
+			a.StartHost(nick)
+		}
+	}, a.Window)
+}
+
 // JoinRoom connects to a room
-func (a *App) JoinRoom(ip string, port int, nick string) {
-	status := widget.NewLabel("Connecting...")
+func (a *App) JoinRoom(room core.DiscoveredRoom, nick string) {
+	a.CurrentLoc = "chat"
+	a.stopPresence()
+	status := widget.NewLabel(i18n.T("client.connecting"))
 	a.Window.SetContent(container.NewCenter(status))
 
 	// 1. Create Callbacks
 	var chatScreen *ChatScreen
 	callbacks := core.ClientCallbacks{
 		OnMessageReceived: func(msg core.Message) {
-			chatScreen.AppendMessage(msg.Nick, msg.Text, msg.Nick == nick)
+			isMe := msg.Nick == chatScreen.Nick
+			chatScreen.AppendMessage(msg, isMe)
+			if !isMe {
+				a.Notifier.Message(core.DisplayNick(msg.Nick), msg.Text, chatScreen.Nick)
+				a.unread.Message()
+			}
 		},
 		OnSystemMessage: func(text string) {
 			chatScreen.AppendSystemMessage(text)
@@ -220,33 +510,79 @@ func (a *App) JoinRoom(ip string, port int, nick string) {
 		OnUserList: func(users []string) {
 			chatScreen.UpdateUserList(users)
 		},
+		OnPresence: func(nick string, joined bool) {
+			a.Notifier.Presence(joined)
+		},
+		OnPoll: func(poll core.Poll) {
+			chatScreen.AppendPoll(poll)
+		},
+		OnReaction: func(id string, emoji string, count int) {
+			chatScreen.UpdateReaction(id, emoji, count)
+		},
+		OnClear: func() {
+			chatScreen.ClearHistory()
+		},
+		OnExport: func(path string) {
+			a.exportTranscript(chatScreen, path)
+		},
+		OnNickChanged: func(newNick string) {
+			chatScreen.SetNick(newNick)
+		},
+		OnTopic: func(topic string) {
+			chatScreen.SetTopic(topic)
+		},
+		OnMessageEdited: func(id string, text string) {
+			chatScreen.EditMessage(id, text)
+		},
+		OnMessageDeleted: func(id string) {
+			chatScreen.DeleteMessage(id)
+		},
+		OnReconnecting: func(room string) {
+			chatScreen.AppendSystemMessage(i18n.T("client.reconnecting", room))
+		},
+		OnReconnected: func(addr string) {
+			chatScreen.AppendSystemMessage(i18n.T("client.reconnected", addr))
+		},
+		OnHostHandoff: func(from string) (string, error) {
+			var link string
+			var err error
+			fyne.DoAndWait(func() {
+				link, err = a.takeOverRoom(chatScreen.Nick)
+			})
+			return link, err
+		},
+		OnAliasesChanged: a.saveAliases,
+		OnSaveDirChanged: a.saveDownloadDir,
 		OnConnectionLost: func() {
-			dialog.ShowInformation("Disconnected", "Connection lost", a.Window)
+			if !a.inSession() {
+				return // we left on purpose
+			}
+			dialog.ShowInformation(i18n.T("client.disconnectedTitle"), i18n.T("client.disconnected"), a.Window)
 			a.ShowWelcome()
 		},
 		OnFileOffer: func(offer core.PendingFile) {
-			dialog.ShowConfirm("File Offer", fmt.Sprintf("%s wants to send %s (%s). Accept?", offer.From, offer.Filename, offer.Size), func(b bool) {
+			a.confirmFileOffer(offer.From, offer.Addr, i18n.T("file.offerSized", offer.From, offer.Filename, offer.Size), func(b bool) {
 				if b {
 					a.Client.SendText("/accept")
 				} else {
 					a.Client.SendText("/reject")
 				}
-			}, a.Window)
+			})
 		},
-		OnFileReceived: func(filename, data, sender string) {
-			chatScreen.AppendSystemMessage(fmt.Sprintf("Received file: %s", filename))
+		OnFileReceived: func(filename string, data []byte, sender string, err error) {
+			a.saveReceivedFile(chatScreen, filename, data, sender, err)
 		},
 		OnFileAccepted: func(sender string) {
-			chatScreen.AppendSystemMessage(fmt.Sprintf("File accepted by %s, sending...", sender))
+			chatScreen.AppendSystemMessage(i18n.T("file.accepted", sender))
 		},
 		OnFileRejected: func(sender string) {
-			chatScreen.AppendSystemMessage(fmt.Sprintf("File rejected by %s", sender))
+			chatScreen.AppendSystemMessage(i18n.T("file.rejected", sender))
 		},
 	}
 
 	// 2. Connect Async
 	go func() {
-		client, err := core.NewChatClient(ip, port, nick, a.FyneApp, callbacks)
+		client, err := core.NewChatClient(room, nick, a.FyneApp, callbacks, nil)
 		if err != nil {
 			dialog.ShowError(err, a.Window)
 			a.ShowWelcome()
@@ -256,15 +592,27 @@ func (a *App) JoinRoom(ip string, port int, nick string) {
 
 		// 3. Create Chat Screen
 		chatScreen = NewChatScreen(a, nick, false, func(text string) {
+			if core.IsQuitCommand(text) {
+				a.leaveSession(false)
+				return
+			}
 			output, err := a.Client.SendText(text)
 			if err != nil {
-				chatScreen.AppendSystemMessage(fmt.Sprintf("Error: %v", err))
+				chatScreen.AppendSystemMessage(i18n.T("error", err))
 			}
 			if output != "" {
 				chatScreen.AppendSystemMessage(output)
 			}
 			// Client relies on server echo for regular messages to avoid duplicates
 		})
+		chatScreen.OnReact = a.Client.React
+		chatScreen.OnEdit = a.Client.EditMessage
+		chatScreen.OnDelete = a.Client.DeleteMessage
+		chatScreen.OnReply = a.Client.Reply
+		a.watchIdle(a.Client, chatScreen)
+		chatScreen.OnPasteImage = func(filename string, data []byte) {
+			a.Client.OfferBytes(filename, data, "")
+		}
 
 		fyne.Do(func() {
 			a.Window.SetContent(chatScreen.Container)
@@ -273,3 +621,109 @@ func (a *App) JoinRoom(ip string, port int, nick string) {
 		client.Start()
 	}()
 }
+
+// watchIdle marks the user away after core.Settings.IdleAway without typing
+func (a *App) watchIdle(session core.AwayStatus, chatScreen *ChatScreen) {
+	a.idle = core.NewIdleTimer(session)
+	idle := a.idle
+	onChanged := chatScreen.Input.OnChanged
+	chatScreen.Input.OnChanged = func(text string) {
+		onChanged(text)
+		idle.Touch()
+	}
+}
+
+// confirmFileOffer asks whether to accept a file offer, with the option to
+// always accept files from the sender, at the address they sent from, from
+// now on. Without an address (older hosts) there is no such option.
+func (a *App) confirmFileOffer(from string, addr string, question string, respond func(accept bool)) {
+	always := widget.NewCheck(i18n.T("file.alwaysAccept", from, addr), nil)
+	content := container.NewVBox(widget.NewLabel(question))
+	if addr != "" {
+		content.Add(always)
+	}
+
+	dialog.ShowCustomConfirm(i18n.T("file.offerTitle"), i18n.T("file.accept"), i18n.T("file.reject"), content, func(accept bool) {
+		if accept && always.Checked {
+			core.TrustSender(from, addr)
+			a.FyneApp.Preferences().SetStringList(prefAutoAcceptFrom, core.Settings.AutoAcceptFrom)
+		}
+		respond(accept)
+	}, a.Window)
+}
+
+// exportTranscript saves the chat history to path, asking where when path is empty
+func (a *App) exportTranscript(chatScreen *ChatScreen, path string) {
+	entries := chatScreen.entries
+	if path != "" {
+		saved, err := core.ExportTranscript(path, entries)
+		if err != nil {
+			chatScreen.AppendSystemMessage(i18n.T("error", err))
+			return
+		}
+		chatScreen.AppendSystemMessage(i18n.T("export.saved", saved))
+		return
+	}
+
+	fyne.Do(func() {
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				chatScreen.AppendSystemMessage(i18n.T("export.error", err))
+				return
+			}
+			if writer == nil {
+				return
+			}
+			defer writer.Close()
+
+			markdown := strings.EqualFold(writer.URI().Extension(), ".md")
+			if _, err := writer.Write([]byte(core.FormatTranscript(entries, markdown))); err != nil {
+				chatScreen.AppendSystemMessage(i18n.T("export.error", err))
+				return
+			}
+			chatScreen.AppendSystemMessage(i18n.T("export.saved", writer.URI().Path()))
+		}, a.Window)
+		save.SetFileName(core.DefaultTranscriptName())
+		save.Show()
+	})
+}
+
+// saveReceivedFile saves a received file into the /savedir directory, or
+// asks where to save it if none is set, discarding it on cancel
+func (a *App) saveReceivedFile(chatScreen *ChatScreen, filename string, data []byte, sender string, err error) {
+	if err != nil {
+		chatScreen.AppendSystemMessage(i18n.T("file.failed", filename, sender, err))
+		return
+	}
+	if core.Settings.DownloadDir != "" {
+		path, err := core.SaveFile(filename, data)
+		if err != nil {
+			chatScreen.AppendSystemMessage(i18n.T("file.saveError", filename, err))
+			return
+		}
+		chatScreen.AppendSystemMessage(i18n.T("file.saved", filename, sender, path))
+		return
+	}
+
+	fyne.Do(func() {
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				chatScreen.AppendSystemMessage(i18n.T("file.saveError", filename, err))
+				return
+			}
+			if writer == nil {
+				chatScreen.AppendSystemMessage(i18n.T("file.discarded", filename, sender))
+				return
+			}
+			defer writer.Close()
+
+			if _, err := writer.Write(data); err != nil {
+				chatScreen.AppendSystemMessage(i18n.T("file.saveError", filename, err))
+				return
+			}
+			chatScreen.AppendSystemMessage(i18n.T("file.saved", filename, sender, writer.URI().Path()))
+		}, a.Window)
+		save.SetFileName(filepath.Base(filename))
+		save.Show()
+	})
+}
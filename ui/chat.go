@@ -3,13 +3,22 @@ package ui
 import (
 	"fmt"
 	"image/color"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/core"
+	"cabinchat/i18n"
 )
 
 // ChatScreen represents the main chat interface
@@ -22,12 +31,42 @@ type ChatScreen struct {
 	Container  *fyne.Container
 	HistoryBox *fyne.Container
 	Scroll     *container.Scroll
-	Input      *widget.Entry
+	Input      *chatEntry
 	UserList   *widget.Label
+	users      []string // nicks in the room, for completing @mentions
 	Status     *widget.Label
+	Address    *widget.Label // host only: where clients can connect
+	hostAddr   string        // shown in Address, for the copy button
+	Topic      *widget.Label
+
+	// Shown instead of auto-scrolling while the user reads back through history
+	newMessages *widget.Button
+
+	// Structured history for /export
+	entries []core.TranscriptEntry
+
+	// Reactions shown under each message, by message ID
+	reactionLabels map[string]*widget.Label
+	reactionCounts map[string]map[string]int
+
+	// Messages that can still be edited, deleted or scrolled to, by message ID
+	messages map[string]*messageView
+
+	// The message the next one sent will reply to, shown above the input
+	replyingTo core.Message
+	replyBar   *fyne.Container
+	replyLabel *widget.Label
+
+	// Shown as the input nears core.Settings.MaxMessageLen
+	lengthLabel *widget.Label
 
 	// Actions
-	OnSend func(text string)
+	OnSend       func(text string)
+	OnReact      func(id string, emoji string)
+	OnEdit       func(id string, text string) error
+	OnDelete     func(id string) error
+	OnReply      func(to core.Message, text string) error
+	OnPasteImage func(filename string, data []byte) // Offer a pasted image as a file
 }
 
 // NewChatScreen creates the chat UI layout
@@ -37,107 +76,519 @@ func NewChatScreen(app *App, nick string, isHost bool, onSend func(string)) *Cha
 		Nick:   nick,
 		IsHost: isHost,
 		OnSend: onSend,
+
+		reactionLabels: make(map[string]*widget.Label),
+		reactionCounts: make(map[string]map[string]int),
+		messages:       make(map[string]*messageView),
 	}
 
 	// 1. Sidebar (User List)
-	cs.UserList = widget.NewLabel("Online:\n(Connecting...)")
+	cs.UserList = widget.NewLabel(i18n.T("chat.usersConnecting"))
 	sidebar := container.NewVBox(
-		widget.NewLabelWithStyle("Room Users", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(i18n.T("chat.users"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		cs.UserList,
 	)
 
 	// 2. Chat History Area
 	cs.HistoryBox = container.NewVBox()
 	cs.Scroll = container.NewScroll(cs.HistoryBox)
+	cs.newMessages = widget.NewButton(i18n.T("chat.newMessages"), func() {
+		cs.Scroll.ScrollToBottom()
+		cs.newMessages.Hide()
+	})
+	cs.newMessages.Importance = widget.HighImportance
+	cs.newMessages.Hide()
+	cs.Scroll.OnScrolled = func(fyne.Position) {
+		if cs.atBottom() {
+			cs.newMessages.Hide()
+		}
+	}
+	history := container.NewStack(cs.Scroll, container.NewVBox(
+		layout.NewSpacer(),
+		container.NewHBox(layout.NewSpacer(), cs.newMessages),
+	))
 
 	// 3. Input Area
-	cs.Input = widget.NewEntry()
-	cs.Input.SetPlaceHolder("Type a message...")
+	cs.Input = newChatEntry()
+	cs.Input.SetPlaceHolder(i18n.T("chat.inputPlaceholder"))
+	cs.Input.OnPasteImage = func(data []byte) {
+		if cs.OnPasteImage != nil {
+			cs.OnPasteImage(fmt.Sprintf("pasted-%s.png", time.Now().Format("20060102-150405")), data)
+		}
+	}
+	cs.Input.OnChanged = cs.inputChanged
+	cs.Input.CompleteMention = cs.completeMention
 	cs.Input.OnSubmitted = func(text string) {
 		if text == "" {
 			return
 		}
 		cs.Input.SetText("")
+		if cs.replyingTo.ID != "" && !strings.HasPrefix(text, "/") && cs.OnReply != nil {
+			if err := cs.OnReply(cs.replyingTo, text); err != nil {
+				cs.AppendSystemMessage(i18n.T("error", err))
+			}
+			cs.CancelReply()
+			return
+		}
 		if cs.OnSend != nil {
 			cs.OnSend(text)
 		}
 	}
 
-	sendBtn := widget.NewButton("Send", func() {
+	sendBtn := widget.NewButton(i18n.T("chat.send"), func() {
 		cs.Input.OnSubmitted(cs.Input.Text)
 	})
 
-	inputBar := container.NewBorder(nil, nil, nil, sendBtn, cs.Input)
+	cs.replyLabel = widget.NewLabel("")
+	cs.replyLabel.Truncation = fyne.TextTruncateEllipsis
+	cs.replyLabel.TextStyle = fyne.TextStyle{Italic: true}
+	cancelReply := widget.NewButtonWithIcon("", theme.CancelIcon(), cs.CancelReply)
+	cancelReply.Importance = widget.LowImportance
+	cs.replyBar = container.NewBorder(nil, nil, nil, cancelReply, cs.replyLabel)
+	cs.replyBar.Hide()
+
+	cs.lengthLabel = widget.NewLabel("")
+	cs.lengthLabel.Hide()
+
+	inputBar := container.NewBorder(cs.replyBar, nil, nil, container.NewHBox(cs.lengthLabel, sendBtn), cs.Input)
 
 	// 4. Header / Media Controls
-	role := "Client"
-	if isHost {
-		role = "Host"
-	}
-	cs.Status = widget.NewLabel(fmt.Sprintf("%s (%s)", nick, role))
+	cs.Status = widget.NewLabel("")
+	cs.SetNick(nick)
 
-	callBtn := widget.NewButton("📞 Call", func() {
+	callBtn := widget.NewButton(i18n.T("chat.call"), func() {
 		// Trigger Call Dialog or Command
 		if cs.OnSend != nil {
 			cs.OnSend("/call") // We'll user slash command shortcuts for now
 		}
 	})
-	screenBtn := widget.NewButton("📺 Share", func() {
+	screenBtn := widget.NewButton(i18n.T("chat.share"), func() {
 		if cs.OnSend != nil {
 			cs.OnSend("/share")
 		}
 	})
 
-	header := container.NewHBox(
-		cs.Status,
-		layout.NewSpacer(),
-		callBtn,
-		screenBtn,
-	)
+	cs.Topic = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	cs.Topic.Hide()
+
+	header := container.NewHBox(cs.Status, cs.Topic)
+	if isHost {
+		cs.Address = widget.NewLabel("")
+		copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			if cs.hostAddr != "" {
+				app.FyneApp.Clipboard().SetContent(cs.hostAddr)
+			}
+		})
+		qrBtn := widget.NewButton("QR", func() {
+			app.showJoinQR()
+		})
+		header.Add(cs.Address)
+		header.Add(copyBtn)
+		header.Add(qrBtn)
+	}
+	exportBtn := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
+		app.exportTranscript(cs, "")
+	})
+
+	header.Add(layout.NewSpacer())
+	header.Add(exportBtn)
+	header.Add(callBtn)
+	header.Add(screenBtn)
 
 	// Assemble layout
 	// Border: Top=Header, Bottom=Input, Left=Sidebar, Center=History
-	content := container.NewBorder(header, inputBar, sidebar, nil, cs.Scroll)
+	content := container.NewBorder(header, inputBar, sidebar, nil, history)
 
 	cs.Container = content
 
 	return cs
 }
 
-// AppendMessage adds a message bubble to the history
-func (cs *ChatScreen) AppendMessage(nick, text string, isMe bool) {
-	label := widget.NewLabel(text)
-	label.Wrapping = fyne.TextWrapWord
-	label.TextStyle = fyne.TextStyle{Monospace: true}
+// inputChanged shows how much room is left once a message nears the length
+// limit; the host cuts anything longer
+func (cs *ChatScreen) inputChanged(text string) {
+	limit := core.Settings.MaxMessageLen
+	n := utf8.RuneCountInString(text)
+	if limit <= 0 || n < limit*9/10 {
+		cs.lengthLabel.Hide()
+		return
+	}
+	cs.lengthLabel.Importance = widget.MediumImportance
+	if n > limit {
+		cs.lengthLabel.Importance = widget.DangerImportance
+	}
+	cs.lengthLabel.SetText(fmt.Sprintf("%d/%d", n, limit))
+	cs.lengthLabel.Show()
+}
+
+// AppendMessage adds a message bubble to the history. Like the other
+// methods fed by room callbacks, it may be called from any goroutine: the
+// host delivers each client's messages from that client's own one.
+func (cs *ChatScreen) AppendMessage(msg core.Message, isMe bool) {
+	fyne.Do(func() {
+		cs.appendMessage(msg, isMe)
+	})
+}
 
+// appendMessage builds the bubble for AppendMessage, on the main goroutine
+func (cs *ChatScreen) appendMessage(msg core.Message, isMe bool) {
 	// Simple styling
-	var content fyne.CanvasObject
+	align := fyne.TextAlignLeading
 	if isMe {
 		// Align right
-		label.Alignment = fyne.TextAlignTrailing
-		content = label
-	} else {
+		align = fyne.TextAlignTrailing
+	}
+	label := widget.NewRichText()
+	label.Segments = messageSegments(label, msg.Text, align)
+	label.Wrapping = fyne.TextWrapWord
+
+	var content fyne.CanvasObject = label
+	if msg.ReplyTo != "" {
+		content = container.NewVBox(cs.replyPreview(msg, align), label)
+	}
+	if !isMe {
 		// Align left with nick
-		nickLabel := canvas.NewText(nick, color.RGBA{R: 100, G: 100, B: 255, A: 255})
+		from := core.DisplayNick(msg.Nick)
+		if msg.Type == core.MsgTypePrivate {
+			from += " (private)"
+		}
+		nickLabel := canvas.NewText(from, cs.nickColorFor(msg.Nick))
 		nickLabel.TextSize = 10
-		content = container.NewVBox(nickLabel, label)
+		content = container.NewVBox(nickLabel, content)
+		if core.IsMention(msg.Text, cs.Nick) {
+			nickLabel.TextStyle.Bold = true
+			highlight := canvas.NewRectangle(theme.Color(theme.ColorNameSelection))
+			highlight.CornerRadius = theme.InputRadiusSize()
+			content = container.NewStack(highlight, content)
+		}
+	}
+	copyText := newCopyable(content, msg.Text, cs.App.FyneApp.Clipboard())
+	content = copyText
+
+	if msg.ID != "" {
+		content = container.NewVBox(content, cs.reactionBar(msg.ID, isMe))
+		cs.messages[msg.ID] = &messageView{obj: content, text: label, copy: copyText, align: align}
+		copyText.actions = []*fyne.MenuItem{fyne.NewMenuItem(i18n.T("chat.reply"), func() {
+			cs.StartReply(msg)
+		})}
+		if isMe {
+			copyText.actions = append(copyText.actions, cs.ownMessageActions(msg.ID)...)
+		}
+	}
+
+	cs.entries = append(cs.entries, core.TranscriptEntry{Time: time.Now(), ID: msg.ID, Nick: msg.Nick, Text: msg.Text})
+	cs.appendToHistory(content)
+}
+
+// messageView is what's needed to edit or delete a message already on screen
+type messageView struct {
+	obj   fyne.CanvasObject
+	text  *widget.RichText
+	copy  *copyable
+	align fyne.TextAlign
+}
+
+// ownMessageActions are the Edit and Delete menu items for one of our messages
+func (cs *ChatScreen) ownMessageActions(id string) []*fyne.MenuItem {
+	edit := fyne.NewMenuItem(i18n.T("chat.edit"), func() {
+		view, ok := cs.messages[id]
+		if !ok || cs.OnEdit == nil {
+			return
+		}
+		entry := widget.NewMultiLineEntry()
+		entry.SetText(view.copy.text)
+		dialog.ShowForm(i18n.T("chat.editTitle"), i18n.T("chat.save"), i18n.T("cancel"), []*widget.FormItem{
+			widget.NewFormItem("", entry),
+		}, func(ok bool) {
+			if !ok || entry.Text == view.copy.text {
+				return
+			}
+			if err := cs.OnEdit(id, entry.Text); err != nil {
+				dialog.ShowError(err, cs.App.Window)
+			}
+		}, cs.App.Window)
+	})
+	del := fyne.NewMenuItem(i18n.T("chat.delete"), func() {
+		if cs.OnDelete == nil {
+			return
+		}
+		dialog.ShowConfirm(i18n.T("chat.deleteTitle"), i18n.T("chat.deleteQuestion"), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := cs.OnDelete(id); err != nil {
+				dialog.ShowError(err, cs.App.Window)
+			}
+		}, cs.App.Window)
+	})
+	return []*fyne.MenuItem{edit, del}
+}
+
+// replyPreview shows the message a reply quotes. Tapping it scrolls back to
+// the original while that is still in the history.
+func (cs *ChatScreen) replyPreview(msg core.Message, align fyne.TextAlign) fyne.CanvasObject {
+	quote := widget.NewHyperlink(fmt.Sprintf("↪ %s: %s", msg.ReplyNick, msg.ReplyText), nil)
+	quote.Alignment = align
+	quote.Truncation = fyne.TextTruncateEllipsis
+	quote.OnTapped = func() {
+		if !cs.ScrollToMessage(msg.ReplyTo) {
+			quote.SetText(i18n.T("chat.quoteGone", msg.ReplyNick, msg.ReplyText))
+		}
 	}
+	return quote
+}
 
-	cs.HistoryBox.Add(content)
-	cs.Scroll.ScrollToBottom()
+// ScrollToMessage scrolls the history so message id is at the top,
+// reporting false if it's no longer there
+func (cs *ChatScreen) ScrollToMessage(id string) bool {
+	view, ok := cs.messages[id]
+	if !ok {
+		return false
+	}
+	cs.Scroll.ScrollToOffset(fyne.NewPos(0, view.obj.Position().Y))
+	if !cs.atBottom() {
+		cs.newMessages.Show()
+	}
+	return true
+}
+
+// StartReply makes the next message sent a reply to msg
+func (cs *ChatScreen) StartReply(msg core.Message) {
+	cs.replyingTo = msg
+	cs.replyLabel.SetText(i18n.T("chat.replyingTo", msg.Nick, core.ReplySnippet(msg.Text)))
+	cs.replyBar.Show()
+	cs.App.Window.Canvas().Focus(cs.Input)
+}
+
+// CancelReply goes back to sending plain messages
+func (cs *ChatScreen) CancelReply() {
+	cs.replyingTo = core.Message{}
+	cs.replyBar.Hide()
+}
+
+// EditMessage shows the new text of an edited message, marked as edited
+func (cs *ChatScreen) EditMessage(id string, text string) {
+	fyne.Do(func() {
+		core.EditEntry(cs.entries, id, text)
+		view, ok := cs.messages[id]
+		if !ok {
+			return
+		}
+		edited := &widget.TextSegment{
+			Text:  "(edited)",
+			Style: widget.RichTextStyle{Alignment: view.align, SizeName: theme.SizeNameCaptionText, TextStyle: fyne.TextStyle{Italic: true}},
+		}
+		view.text.Segments = append(messageSegments(view.text, text, view.align), edited)
+		view.text.Refresh()
+		view.copy.text = text
+	})
+}
+
+// DeleteMessage removes a deleted message from the history
+func (cs *ChatScreen) DeleteMessage(id string) {
+	fyne.Do(func() {
+		cs.entries = core.DeleteEntry(cs.entries, id)
+		view, ok := cs.messages[id]
+		if !ok {
+			return
+		}
+		delete(cs.messages, id)
+		delete(cs.reactionLabels, id)
+		delete(cs.reactionCounts, id)
+		cs.HistoryBox.Remove(view.obj)
+	})
+}
+
+// scrollSlack is how far from the bottom still counts as following the chat
+const scrollSlack = 40
+
+// atBottom reports whether the history is scrolled to (or near) the latest message
+func (cs *ChatScreen) atBottom() bool {
+	return cs.Scroll.Offset.Y+cs.Scroll.Size().Height >= cs.HistoryBox.MinSize().Height-scrollSlack
+}
+
+// appendToHistory adds an entry, following it only if the user was already
+// at the bottom; otherwise the new messages button is shown
+func (cs *ChatScreen) appendToHistory(obj fyne.CanvasObject) {
+	follow := cs.atBottom()
+	cs.HistoryBox.Add(obj)
+	if follow {
+		cs.Scroll.ScrollToBottom()
+	} else {
+		cs.newMessages.Show()
+	}
+}
+
+// reactionBar shows a message's reaction counts and a button to add one
+func (cs *ChatScreen) reactionBar(id string, isMe bool) fyne.CanvasObject {
+	counts := widget.NewLabel("")
+	cs.reactionLabels[id] = counts
+
+	react := widget.NewButton("👍", func() {
+		if cs.OnReact != nil {
+			cs.OnReact(id, "👍")
+		}
+	})
+	react.Importance = widget.LowImportance
+
+	if isMe {
+		return container.NewHBox(layout.NewSpacer(), counts, react)
+	}
+	return container.NewHBox(react, counts)
+}
+
+// UpdateReaction sets the count for an emoji under a message
+func (cs *ChatScreen) UpdateReaction(id string, emoji string, count int) {
+	fyne.Do(func() {
+		label, ok := cs.reactionLabels[id]
+		if !ok {
+			return
+		}
+		if cs.reactionCounts[id] == nil {
+			cs.reactionCounts[id] = make(map[string]int)
+		}
+		cs.reactionCounts[id][emoji] = count
+
+		var parts []string
+		for e, n := range cs.reactionCounts[id] {
+			parts = append(parts, fmt.Sprintf("%s %d", e, n))
+		}
+		sort.Strings(parts)
+		label.SetText(strings.Join(parts, "  "))
+	})
+}
+
+// nickColor returns the nick label color, kept readable on light and dark backgrounds
+func (cs *ChatScreen) nickColor() color.Color {
+	if currentVariant(cs.App.FyneApp) == theme.VariantDark {
+		return color.RGBA{R: 140, G: 140, B: 255, A: 255}
+	}
+	return color.RGBA{R: 60, G: 60, B: 200, A: 255}
+}
+
+// nickColorFor returns the color nick's messages are labelled in: its alias
+// color if one is set, otherwise nickColor
+func (cs *ChatScreen) nickColorFor(nick string) color.Color {
+	if alias, ok := core.AliasFor(nick); ok && alias.Color != "" {
+		if c, err := core.ParseColor(alias.Color); err == nil {
+			return c
+		}
+	}
+	return cs.nickColor()
 }
 
 // AppendSystemMessage adds a system notice
 func (cs *ChatScreen) AppendSystemMessage(text string) {
-	label := widget.NewLabel(text)
-	label.Alignment = fyne.TextAlignCenter
-	label.TextStyle = fyne.TextStyle{Italic: true}
+	fyne.Do(func() {
+		label := widget.NewLabel(text)
+		label.Alignment = fyne.TextAlignCenter
+		label.TextStyle = fyne.TextStyle{Italic: true}
 
-	cs.HistoryBox.Add(label)
-	cs.Scroll.ScrollToBottom()
+		cs.entries = append(cs.entries, core.TranscriptEntry{Time: time.Now(), Text: text})
+		cs.appendToHistory(newCopyable(label, text, cs.App.FyneApp.Clipboard()))
+	})
+}
+
+// AppendPoll shows a poll with a vote button per option, or its results once closed
+func (cs *ChatScreen) AppendPoll(poll core.Poll) {
+	fyne.Do(func() {
+		title := "📊 Poll: " + poll.Question
+		if poll.Closed {
+			title = "📊 Results: " + poll.Question
+		}
+		box := container.NewVBox(widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+
+		for i, opt := range poll.Options {
+			if poll.Closed {
+				box.Add(widget.NewLabel(fmt.Sprintf("%s: %d", opt, poll.Votes[i])))
+				continue
+			}
+			vote := fmt.Sprintf("/vote %d", i+1)
+			box.Add(widget.NewButton(fmt.Sprintf("%d. %s", i+1, opt), func() {
+				if cs.OnSend != nil {
+					cs.OnSend(vote)
+				}
+			}))
+		}
+
+		cs.entries = append(cs.entries, core.TranscriptEntry{Time: time.Now(), Text: title + " (" + strings.Join(poll.Options, " | ") + ")"})
+		cs.appendToHistory(box)
+	})
+}
+
+// SetNick updates the local user's nick, used to tell own messages apart
+func (cs *ChatScreen) SetNick(nick string) {
+	role := i18n.T("chat.roleClient")
+	if cs.IsHost {
+		role = i18n.T("chat.roleHost")
+	}
+	cs.Nick = nick
+	cs.Status.SetText(fmt.Sprintf("%s (%s)", nick, role))
+}
+
+// SetHostAddress shows where clients can connect to the hosted room
+func (cs *ChatScreen) SetHostAddress(addr string) {
+	if cs.Address == nil {
+		return
+	}
+	fyne.Do(func() {
+		cs.hostAddr = addr
+		cs.Address.SetText(i18n.T("chat.hosting", addr))
+	})
+}
+
+// SetTopic shows the room topic in the header, hiding it when empty
+func (cs *ChatScreen) SetTopic(topic string) {
+	fyne.Do(func() {
+		cs.Topic.SetText(topic)
+		if topic == "" {
+			cs.Topic.Hide()
+		} else {
+			cs.Topic.Show()
+		}
+	})
+}
+
+// ClearHistory removes all messages from the chat history
+func (cs *ChatScreen) ClearHistory() {
+	fyne.Do(func() {
+		cs.HistoryBox.RemoveAll()
+		cs.entries = nil
+		cs.reactionLabels = make(map[string]*widget.Label)
+		cs.reactionCounts = make(map[string]map[string]int)
+		cs.messages = make(map[string]*messageView)
+		cs.HistoryBox.Refresh()
+		cs.newMessages.Hide()
+	})
 }
 
 // UpdateUserList updates the sidebar
 func (cs *ChatScreen) UpdateUserList(users []string) {
-	cs.UserList.SetText(strings.Join(users, "\n"))
+	fyne.Do(func() {
+		shown := make([]string, len(users))
+		cs.users = make([]string, len(users))
+		for i, user := range users {
+			// Entries are a nick, possibly followed by " (host)" or " (away)"
+			nick, status, found := strings.Cut(user, " (")
+			cs.users[i] = nick
+			shown[i] = core.DisplayNick(nick)
+			if found {
+				shown[i] += " (" + status
+			}
+		}
+		cs.UserList.SetText(strings.Join(shown, "\n"))
+	})
+}
+
+// completeMention finishes an @mention: the first nick in the room, other
+// than our own, that starts with prefix
+func (cs *ChatScreen) completeMention(prefix string) (string, bool) {
+	nicks := slices.Clone(cs.users)
+	slices.SortFunc(nicks, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	for _, nick := range nicks {
+		if nick != cs.Nick && strings.HasPrefix(strings.ToLower(nick), strings.ToLower(prefix)) {
+			return nick, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/png"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+)
+
+// TypedShortcut intercepts paste when the clipboard holds an image
+func (e *chatEntry) TypedShortcut(shortcut fyne.Shortcut) {
+	if paste, ok := shortcut.(*fyne.ShortcutPaste); ok && e.OnPasteImage != nil {
+		// Text wins, so copying from a web page still pastes its words
+		if paste.Clipboard == nil || paste.Clipboard.Content() == "" {
+			if data, ok := clipboardImage(); ok {
+				e.OnPasteImage(data)
+				return
+			}
+		}
+	}
+	e.Entry.TypedShortcut(shortcut)
+	e.fitRows()
+}
+
+// clipboardImage returns the clipboard's image encoded as PNG, if it holds one.
+// Fyne's clipboard is text only, so this asks the platform's own tools.
+func clipboardImage() ([]byte, bool) {
+	raw, err := readClipboardImage()
+	if err != nil || len(raw) == 0 {
+		return nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// readClipboardImage fetches raw image bytes from the system clipboard
+func readClipboardImage() ([]byte, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// Prints «data PNGf89504E47...»
+		out, err := exec.Command("osascript", "-e", "the clipboard as «class PNGf»").Output()
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(string(out))
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "«data PNGf"), "»")
+		return hex.DecodeString(text)
+
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms;` +
+			`$img = [Windows.Forms.Clipboard]::GetImage();` +
+			`if ($img) { $ms = New-Object IO.MemoryStream;` +
+			`$img.Save($ms, [Drawing.Imaging.ImageFormat]::Png);` +
+			`[Convert]::ToBase64String($ms.ToArray()) }`
+		out, err := exec.Command("powershell", "-NoProfile", "-STA", "-Command", script).Output()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+
+	default:
+		if out, err := exec.Command("wl-paste", "--no-newline", "--type", "image/png").Output(); err == nil {
+			return out, nil
+		}
+		if out, err := exec.Command("xclip", "-selection", "clipboard", "-t", "image/png", "-o").Output(); err == nil {
+			return out, nil
+		}
+		return nil, errors.New("no clipboard image tool available")
+	}
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/i18n"
+)
+
+// copyable wraps a message so right-click (or long-press) offers to copy its
+// text, plus any extra actions such as editing your own messages
+type copyable struct {
+	widget.BaseWidget
+	content   fyne.CanvasObject
+	text      string
+	clipboard fyne.Clipboard
+	actions   []*fyne.MenuItem
+}
+
+func newCopyable(content fyne.CanvasObject, text string, clipboard fyne.Clipboard) *copyable {
+	c := &copyable{content: content, text: text, clipboard: clipboard}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// CreateRenderer draws the wrapped content unchanged
+func (c *copyable) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(c.content)
+}
+
+// TappedSecondary shows the copy menu
+func (c *copyable) TappedSecondary(ev *fyne.PointEvent) {
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(c)
+	if canvas == nil {
+		return
+	}
+	items := []*fyne.MenuItem{fyne.NewMenuItem(i18n.T("copy"), func() {
+		c.clipboard.SetContent(c.text)
+	})}
+	menu := fyne.NewMenu("", append(items, c.actions...)...)
+	widget.ShowPopUpMenuAtPosition(menu, canvas, ev.AbsolutePosition)
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxInputRows is how tall the message input grows before it scrolls
+const maxInputRows = 5
+
+// chatEntry is the message input. Enter sends and Shift+Enter starts a new
+// line; pasting an image offers it as a file instead of inserting text. Tab
+// after "@" and the start of a nick completes the nick.
+type chatEntry struct {
+	widget.Entry
+	OnPasteImage    func(png []byte)
+	CompleteMention func(prefix string) (nick string, ok bool)
+
+	shiftDown bool
+	rows      int
+}
+
+func newChatEntry() *chatEntry {
+	e := &chatEntry{rows: 1}
+	e.MultiLine = true
+	e.Wrapping = fyne.TextWrapWord
+	e.ExtendBaseWidget(e)
+	e.SetMinRowsVisible(e.rows)
+	return e
+}
+
+// KeyDown tracks Shift for Shift+Enter
+func (e *chatEntry) KeyDown(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftDown = true
+	}
+	e.Entry.KeyDown(key)
+}
+
+// KeyUp tracks Shift for Shift+Enter
+func (e *chatEntry) KeyUp(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftDown = false
+	}
+	e.Entry.KeyUp(key)
+}
+
+// TypedKey sends on Enter and inserts a newline on Shift+Enter, the reverse
+// of a multi-line Entry
+func (e *chatEntry) TypedKey(key *fyne.KeyEvent) {
+	if key.Name == fyne.KeyTab && e.completeMention() {
+		return
+	}
+	if key.Name != fyne.KeyReturn && key.Name != fyne.KeyEnter {
+		e.Entry.TypedKey(key)
+		e.fitRows()
+		return
+	}
+	if !e.shiftDown {
+		if e.OnSubmitted != nil {
+			e.OnSubmitted(e.Text)
+		}
+		return
+	}
+	// With OnSubmitted set, the Entry would send on Shift+Enter instead
+	submit := e.OnSubmitted
+	e.OnSubmitted = nil
+	e.Entry.TypedKey(key)
+	e.OnSubmitted = submit
+	e.fitRows()
+}
+
+// completeMention replaces a trailing "@prefix" with the nick it completes
+// to, reporting whether it did
+func (e *chatEntry) completeMention() bool {
+	lines := strings.Split(e.Text, "\n")
+	last := []rune(lines[len(lines)-1])
+	if e.CompleteMention == nil || e.CursorRow != len(lines)-1 || e.CursorColumn != len(last) {
+		return false // only at the end of the input
+	}
+	start := strings.LastIndexAny(e.Text, " \n") + 1
+	prefix, ok := strings.CutPrefix(e.Text[start:], "@")
+	if !ok || prefix == "" {
+		return false
+	}
+	nick, ok := e.CompleteMention(prefix)
+	if !ok {
+		return false
+	}
+	e.SetText(e.Text[:start] + "@" + nick + " ")
+	lines = strings.Split(e.Text, "\n")
+	e.CursorRow, e.CursorColumn = len(lines)-1, len([]rune(lines[len(lines)-1]))
+	e.Refresh()
+	return true
+}
+
+// SetText replaces the input, resizing it to fit
+func (e *chatEntry) SetText(text string) {
+	e.Entry.SetText(text)
+	e.fitRows()
+}
+
+// fitRows grows the input with each line typed, up to maxInputRows
+func (e *chatEntry) fitRows() {
+	rows := min(strings.Count(e.Text, "\n")+1, maxInputRows)
+	if rows != e.rows {
+		e.rows = rows
+		e.SetMinRowsVisible(rows)
+	}
+}
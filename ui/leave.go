@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/core"
+	"cabinchat/i18n"
+)
+
+// inSession reports whether the user is hosting or in a room
+func (a *App) inSession() bool {
+	return a.CurrentLoc == "chat" && (a.Host != nil || a.Client != nil)
+}
+
+// onCloseWindow asks before closing the window mid-session
+func (a *App) onCloseWindow() {
+	if !a.inSession() {
+		a.Window.Close()
+		return
+	}
+	a.leaveSession(true)
+}
+
+// leaveSession confirms leaving, unless the user said not to ask again, then
+// ends the session and shows its summary before closing the window or going
+// back to the welcome screen
+func (a *App) leaveSession(closing bool) {
+	prefs := a.FyneApp.Preferences()
+	if prefs.Bool(prefSkipLeaveConfirm) {
+		stats := a.endSession()
+		if closing {
+			a.Window.Close()
+			return
+		}
+		a.ShowWelcome()
+		dialog.ShowInformation(i18n.T("leave.summaryTitle"), stats.String(), a.Window)
+		return
+	}
+
+	question := i18n.T("leave.question")
+	if a.Host != nil {
+		question = i18n.T("leave.questionHost")
+	}
+	dontAsk := widget.NewCheck(i18n.T("leave.dontAsk"), nil)
+	content := container.NewVBox(widget.NewLabel(question), dontAsk)
+	dialog.ShowCustomConfirm(i18n.T("leave.leave"), i18n.T("leave.leave"), i18n.T("leave.stay"), content, func(leave bool) {
+		if !leave {
+			return
+		}
+		prefs.SetBool(prefSkipLeaveConfirm, dontAsk.Checked)
+		stats := a.endSession()
+		if !closing {
+			a.ShowWelcome()
+		}
+		summary := dialog.NewInformation(i18n.T("leave.summaryTitle"), stats.String(), a.Window)
+		summary.SetOnClosed(func() {
+			if closing {
+				a.Window.Close()
+			}
+		})
+		summary.Show()
+	}, a.Window)
+}
+
+// endSession stops hosting or leaves the room, returning what happened in it
+func (a *App) endSession() core.SessionStats {
+	var stats core.SessionStats
+	if a.Host != nil {
+		stats = a.Host.Stats()
+		a.Host.Shutdown()
+		a.Host = nil
+	}
+	if a.Client != nil {
+		stats = a.Client.Stats()
+		a.Client.Close()
+		a.Client = nil
+	}
+	return stats
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/core"
+)
+
+// markdownMarkers are the supported inline markers, longest first so that
+// ** is matched before *
+var markdownMarkers = []string{"`", "**", "*"}
+
+// markdownSegments splits a message into rich text segments, rendering
+// **bold**, *italic* and `code`. Markers are not nested: text between a pair
+// is shown as-is, and a marker without a closing partner stays literal.
+// Segments are plain text, so nothing in a message is ever interpreted as
+// anything but text.
+func markdownSegments(text string, align fyne.TextAlign) []widget.RichTextSegment {
+	base := widget.RichTextStyleInline
+	base.Alignment = align
+	base.TextStyle = fyne.TextStyle{Monospace: true}
+
+	if !core.Settings.Markdown {
+		return []widget.RichTextSegment{&widget.TextSegment{Text: text, Style: base}}
+	}
+
+	var segments []widget.RichTextSegment
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			segments = append(segments, &widget.TextSegment{Text: plain.String(), Style: base})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		marker, inner := matchMarker(text[i:])
+		if marker == "" {
+			// An unclosed ** stays literal as a whole rather than opening italics
+			n := 1
+			if strings.HasPrefix(text[i:], "**") {
+				n = 2
+			}
+			plain.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+
+		style := base
+		switch marker {
+		case "`":
+			style = widget.RichTextStyleCodeInline
+			style.Alignment = align
+		case "**":
+			style.TextStyle.Bold = true
+		case "*":
+			style.TextStyle.Italic = true
+		}
+		flush()
+		segments = append(segments, &widget.TextSegment{Text: inner, Style: style})
+		i += len(inner) + 2*len(marker)
+	}
+	flush()
+
+	if len(segments) == 0 {
+		segments = append(segments, &widget.TextSegment{Text: "", Style: base})
+	}
+	return segments
+}
+
+// matchMarker checks whether s opens a formatted span, returning the marker
+// and the text it encloses, or "" if s doesn't start a closed, non-empty span
+func matchMarker(s string) (string, string) {
+	for _, marker := range markdownMarkers {
+		if !strings.HasPrefix(s, marker) {
+			continue
+		}
+		end := strings.Index(s[len(marker):], marker)
+		if end <= 0 {
+			return "", ""
+		}
+		return marker, s[len(marker) : len(marker)+end]
+	}
+	return "", ""
+}
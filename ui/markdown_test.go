@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"cabinchat/core"
+)
+
+// describeSegments renders segments as "style:text" for comparison. Code
+// looks like plain text, as messages are already monospaced.
+func describeSegments(segments []widget.RichTextSegment) []string {
+	var out []string
+	for _, s := range segments {
+		seg := s.(*widget.TextSegment)
+		style := "plain"
+		switch {
+		case seg.Style.TextStyle.Bold:
+			style = "bold"
+		case seg.Style.TextStyle.Italic:
+			style = "italic"
+		}
+		out = append(out, fmt.Sprintf("%s:%s", style, seg.Text))
+	}
+	return out
+}
+
+func TestMarkdownSegments(t *testing.T) {
+	old := core.Settings.Markdown
+	defer func() { core.Settings.Markdown = old }()
+	core.Settings.Markdown = true
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{"plain:"}},
+		{"hello", []string{"plain:hello"}},
+		{"a **bold** b", []string{"plain:a ", "bold:bold", "plain: b"}},
+		{"*it* and `x*y*`", []string{"italic:it", "plain: and ", "plain:x*y*"}},
+		{"**unclosed *it*", []string{"plain:**unclosed ", "italic:it"}},
+		{"2 * 3 = 6", []string{"plain:2 * 3 = 6"}},
+		{"empty ** pair", []string{"plain:empty ** pair"}},
+		{"**not *nested***", []string{"bold:not *nested", "plain:*"}},
+	}
+	for _, tt := range tests {
+		if got := describeSegments(markdownSegments(tt.text, fyne.TextAlignLeading)); !slices.Equal(got, tt.want) {
+			t.Errorf("markdownSegments(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownOff(t *testing.T) {
+	old := core.Settings.Markdown
+	defer func() { core.Settings.Markdown = old }()
+	core.Settings.Markdown = false
+
+	got := describeSegments(markdownSegments("a **bold** b", fyne.TextAlignTrailing))
+	if want := []string{"plain:a **bold** b"}; !slices.Equal(got, want) {
+		t.Errorf("segments = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"cabinchat/core"
+	"cabinchat/i18n"
+	"cabinchat/media"
+)
+
+// Notifier sends desktop notifications while the window is unfocused,
+// coalescing bursts of messages into a single summary
+type Notifier struct {
+	app     fyne.App
+	mutex   sync.Mutex
+	focused bool
+	senders []string // sender of each message in the current burst
+	last    string   // text of the most recent message in the burst
+	timer   *time.Timer
+}
+
+// NewNotifier creates a notifier; the app reports focus changes through SetFocused
+func NewNotifier(app fyne.App) *Notifier {
+	return &Notifier{app: app, focused: true}
+}
+
+// SetFocused records whether the window is in the foreground
+func (n *Notifier) SetFocused(focused bool) {
+	n.mutex.Lock()
+	n.focused = focused
+	n.mutex.Unlock()
+}
+
+// Message plays the message sound and queues a notification for an incoming
+// chat message. Mentions of nick get their own sound and are always
+// delivered on their own; with Settings.MentionsOnly nothing else is.
+func (n *Notifier) Message(sender, text, nick string) {
+	mention := core.IsMention(text, nick)
+	switch {
+	case mention:
+		go core.PlaySound(media.SoundMention)
+	case !core.Settings.MentionsOnly:
+		go core.PlaySound(media.SoundMessage)
+	}
+	if !core.Settings.Notify {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.focused {
+		return
+	}
+	text = hideSpoilers(text)
+	if mention {
+		n.app.SendNotification(fyne.NewNotification(sender+" mentioned you", text))
+		return
+	}
+	if core.Settings.MentionsOnly {
+		return
+	}
+
+	n.senders = append(n.senders, sender)
+	n.last = text
+	if n.timer == nil {
+		n.timer = time.AfterFunc(core.Settings.NotifyWindow, n.flush)
+	}
+}
+
+// Presence plays the join or leave sound when someone arrives or departs
+func (n *Notifier) Presence(joined bool) {
+	if joined {
+		go core.PlaySound(media.SoundJoin)
+	} else {
+		go core.PlaySound(media.SoundLeave)
+	}
+}
+
+// flush sends the summary for the current burst
+func (n *Notifier) flush() {
+	n.mutex.Lock()
+	title, content := summarize(n.senders, n.last)
+	n.senders = nil
+	n.last = ""
+	n.timer = nil
+	n.mutex.Unlock()
+
+	if title != "" {
+		n.app.SendNotification(fyne.NewNotification(title, content))
+	}
+}
+
+// summarize builds the notification for a burst of messages: a single
+// message is shown as-is, anything more as a count of messages and people
+func summarize(senders []string, last string) (string, string) {
+	switch len(senders) {
+	case 0:
+		return "", ""
+	case 1:
+		return senders[0], last
+	}
+
+	people := make(map[string]bool)
+	for _, s := range senders {
+		people[s] = true
+	}
+	if len(people) == 1 {
+		return "CabinChat", i18n.T("notify.fromOne", len(senders), senders[0])
+	}
+	return "CabinChat", i18n.T("notify.fromMany", len(senders), len(people))
+}
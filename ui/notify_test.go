@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+
+	"cabinchat/core"
+	"cabinchat/i18n"
+)
+
+// notifyingApp records the notifications sent through it
+type notifyingApp struct {
+	fyne.App
+	sent chan *fyne.Notification
+}
+
+func (a *notifyingApp) SendNotification(n *fyne.Notification) {
+	a.sent <- n
+}
+
+// quiet turns off sounds and turns on notifications for one test. Sound
+// stays off afterwards, as the goroutines playing sounds may still read it.
+func quiet(t *testing.T) {
+	old := core.Settings
+	t.Cleanup(func() {
+		core.Settings.Notify = old.Notify
+		core.Settings.MentionsOnly = old.MentionsOnly
+		core.Settings.NotifyWindow = old.NotifyWindow
+	})
+	if core.Settings.Sound {
+		core.Settings.Sound = false
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		senders     []string
+		title, body string
+	}{
+		{nil, "", ""},
+		{[]string{"alice"}, "alice", "hi"},
+		{[]string{"alice", "alice", "alice"}, "CabinChat", i18n.T("notify.fromOne", 3, "alice")},
+		{[]string{"alice", "bob", "alice"}, "CabinChat", i18n.T("notify.fromMany", 3, 2)},
+	}
+	for _, tt := range tests {
+		title, body := summarize(tt.senders, "hi")
+		if title != tt.title || body != tt.body {
+			t.Errorf("summarize(%v) = %q, %q; want %q, %q", tt.senders, title, body, tt.title, tt.body)
+		}
+	}
+}
+
+func TestBurstIsCoalesced(t *testing.T) {
+	app := &notifyingApp{App: test.NewApp(), sent: make(chan *fyne.Notification, 10)}
+	defer app.Quit()
+	quiet(t)
+	core.Settings.Notify = true
+	core.Settings.MentionsOnly = false
+	core.Settings.NotifyWindow = 50 * time.Millisecond
+
+	n := NewNotifier(app)
+	n.SetFocused(false)
+	n.Message("alice", "one", "me")
+	n.Message("bob", "two", "me")
+	n.Message("alice", "hey @me", "me")
+	n.Message("alice", "three", "me")
+
+	want := []string{"alice mentioned you", "CabinChat"}
+	for _, title := range want {
+		select {
+		case got := <-app.sent:
+			if got.Title != title {
+				t.Errorf("notification %q, want %q", got.Title, title)
+			}
+			if title == "CabinChat" && got.Content != i18n.T("notify.fromMany", 3, 2) {
+				t.Errorf("summary %q", got.Content)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %q notification", title)
+		}
+	}
+	select {
+	case got := <-app.sent:
+		t.Errorf("extra notification %q: %q", got.Title, got.Content)
+	case <-time.After(2 * core.Settings.NotifyWindow):
+	}
+}
+
+func TestNoNotificationsWhileFocused(t *testing.T) {
+	app := &notifyingApp{App: test.NewApp(), sent: make(chan *fyne.Notification, 10)}
+	defer app.Quit()
+	quiet(t)
+	core.Settings.Notify = true
+	core.Settings.NotifyWindow = 10 * time.Millisecond
+
+	n := NewNotifier(app)
+	n.Message("alice", "hey @me", "me")
+	n.Message("alice", "one", "me")
+	select {
+	case got := <-app.sent:
+		t.Errorf("notification %q while focused", got.Title)
+	case <-time.After(5 * core.Settings.NotifyWindow):
+	}
+}
@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	qrcode "github.com/skip2/go-qrcode"
+
+	"cabinchat/i18n"
+)
+
+// showJoinQR shows a QR code of the hosted room's cabinchat:// link
+func (a *App) showJoinQR() {
+	if a.Host == nil {
+		return
+	}
+	link := a.Host.JoinURL()
+	qr, err := qrcode.New(link, qrcode.Medium)
+	if err != nil {
+		dialog.ShowError(err, a.Window)
+		return
+	}
+
+	img := canvas.NewImageFromImage(qr.Image(256))
+	img.FillMode = canvas.ImageFillContain
+	img.ScaleMode = canvas.ImageScalePixels
+	img.SetMinSize(fyne.NewSize(256, 256))
+
+	linkEntry := widget.NewEntry()
+	linkEntry.SetText(link)
+	copyBtn := widget.NewButton(i18n.T("qr.copyLink"), func() {
+		a.FyneApp.Clipboard().SetContent(link)
+	})
+
+	content := container.NewVBox(img, container.NewBorder(nil, nil, nil, copyBtn, linkEntry))
+	dialog.ShowCustom(i18n.T("qr.title"), i18n.T("close"), content, a.Window)
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// spoilerMarker encloses text that stays hidden until tapped, e.g.
+// ||the butler did it||. On the wire it's plain text.
+const spoilerMarker = "||"
+
+// spoilerCover is shown in place of a spoiler's text
+const spoilerCover = "▒▒▒ spoiler ▒▒▒"
+
+// splitSpoilers splits text into alternating plain and spoiler parts, starting
+// with plain, so spoilers are at odd indices. A marker without a closing
+// partner, or an empty pair, stays literal.
+func splitSpoilers(text string) []string {
+	var parts []string
+	var plain strings.Builder
+	for {
+		start := strings.Index(text, spoilerMarker)
+		if start < 0 {
+			break
+		}
+		rest := text[start+len(spoilerMarker):]
+		end := strings.Index(rest, spoilerMarker)
+		if end < 0 {
+			break
+		}
+		if end == 0 {
+			plain.WriteString(text[:start+2*len(spoilerMarker)])
+			text = rest[len(spoilerMarker):]
+			continue
+		}
+		plain.WriteString(text[:start])
+		parts = append(parts, plain.String(), rest[:end])
+		plain.Reset()
+		text = rest[end+len(spoilerMarker):]
+	}
+	plain.WriteString(text)
+	return append(parts, plain.String())
+}
+
+// hideSpoilers covers a message's spoilers, for places that can't reveal
+// them on tap such as notifications
+func hideSpoilers(text string) string {
+	parts := splitSpoilers(text)
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = spoilerCover
+	}
+	return strings.Join(parts, "")
+}
+
+// messageSegments renders a message for label: formatting as in
+// markdownSegments, with each spoiler covered by a link that reveals it
+func messageSegments(label *widget.RichText, text string, align fyne.TextAlign) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	for i, part := range splitSpoilers(text) {
+		if i%2 == 1 {
+			segments = append(segments, spoilerSegment(label, part, align))
+		} else if part != "" {
+			segments = append(segments, markdownSegments(part, align)...)
+		}
+	}
+	if len(segments) == 0 {
+		return markdownSegments("", align)
+	}
+	return segments
+}
+
+// spoilerSegment covers hidden text until it's tapped
+func spoilerSegment(label *widget.RichText, hidden string, align fyne.TextAlign) widget.RichTextSegment {
+	cover := &widget.HyperlinkSegment{Alignment: align, Text: spoilerCover}
+	cover.OnTapped = func() {
+		i := slices.Index(label.Segments, widget.RichTextSegment(cover))
+		if i < 0 {
+			return // edited since
+		}
+		label.Segments = slices.Concat(label.Segments[:i:i], markdownSegments(hidden, align), label.Segments[i+1:])
+		label.Refresh()
+	}
+	return cover
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+
+	"cabinchat/core"
+)
+
+// fixedVariantTheme is the default theme pinned to light or dark
+type fixedVariantTheme struct {
+	fyne.Theme
+	variant fyne.ThemeVariant
+}
+
+// Color returns the default theme color for the pinned variant
+func (t *fixedVariantTheme) Color(name fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
+	return t.Theme.Color(name, t.variant)
+}
+
+// applyTheme sets the app theme from Settings.Theme. "auto" uses the
+// default theme, which follows the OS appearance and updates live.
+func applyTheme(app fyne.App) {
+	switch core.Settings.Theme {
+	case "light":
+		app.Settings().SetTheme(&fixedVariantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantLight})
+	case "dark":
+		app.Settings().SetTheme(&fixedVariantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantDark})
+	default:
+		app.Settings().SetTheme(theme.DefaultTheme())
+	}
+}
+
+// currentVariant returns the variant in effect, resolving "auto" to the OS setting
+func currentVariant(app fyne.App) fyne.ThemeVariant {
+	if t, ok := app.Settings().Theme().(*fixedVariantTheme); ok {
+		return t.variant
+	}
+	return app.Settings().ThemeVariant()
+}
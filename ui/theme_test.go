@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/theme"
+
+	"cabinchat/core"
+)
+
+func TestApplyTheme(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+	old := core.Settings.Theme
+	defer func() { core.Settings.Theme = old }()
+
+	tests := []struct {
+		setting string
+		want    fyne.ThemeVariant
+	}{
+		{"light", theme.VariantLight},
+		{"dark", theme.VariantDark},
+		{"auto", app.Settings().ThemeVariant()}, // whatever the OS uses
+	}
+	for _, tt := range tests {
+		core.Settings.Theme = tt.setting
+		applyTheme(app)
+		if got := currentVariant(app); got != tt.want {
+			t.Errorf("theme %q resolves to variant %v, want %v", tt.setting, got, tt.want)
+		}
+	}
+}
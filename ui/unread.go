@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"fmt"
+	"sync"
+
+	"fyne.io/fyne/v2"
+)
+
+// windowTitle is the main window's title when nothing is unread
+const windowTitle = "CabinChat"
+
+// unreadCounter counts messages that arrive while the window is unfocused
+// and shows the count in the window title
+type unreadCounter struct {
+	window  fyne.Window
+	mutex   sync.Mutex
+	focused bool
+	count   int
+}
+
+func newUnreadCounter(window fyne.Window) *unreadCounter {
+	return &unreadCounter{window: window, focused: true}
+}
+
+// SetFocused records focus changes; regaining focus marks everything read
+func (u *unreadCounter) SetFocused(focused bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.focused = focused
+	if focused && u.count > 0 {
+		u.count = 0
+		u.showCount()
+	}
+}
+
+// Message counts an incoming message if the window is in the background
+func (u *unreadCounter) Message() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.focused {
+		return
+	}
+	u.count++
+	u.showCount()
+}
+
+// showCount updates the title; must be called with u.mutex held
+func (u *unreadCounter) showCount() {
+	title := windowTitle
+	if u.count > 0 {
+		title = fmt.Sprintf("%s (%d)", windowTitle, u.count)
+	}
+	fyne.Do(func() {
+		u.window.SetTitle(title)
+	})
+}